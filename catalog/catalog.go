@@ -0,0 +1,128 @@
+package catalog
+
+import (
+	"HwyDB/index"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 所有表以及索引的目录，索引名在所有的表中唯一
+type Catalog struct {
+	mu      sync.RWMutex
+	m       int // 新建表（以及索引）的B+树的阶数
+	tables  map[string]*Table
+	indexes map[string]*Index
+}
+
+func New(m int) *Catalog {
+	return &Catalog{m: m, tables: make(map[string]*Table), indexes: make(map[string]*Index)}
+}
+
+// 按表结构新建一个空表
+func (c *Catalog) Create(s *Schema) (*Table, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.tables[s.Name]; ok {
+		return nil, fmt.Errorf("%s: %w", s.Name, ErrTableExist)
+	}
+	t := &Table{Schema: s, bt: index.New(c.m)}
+	c.tables[s.Name] = t
+	return t, nil
+}
+
+// 删除表以及其中所有的数据和索引
+func (c *Catalog) Drop(name string) error {
+	name = strings.ToLower(name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.tables[name]
+	if !ok {
+		return fmt.Errorf("%s: %w", name, ErrTableNotExist)
+	}
+	for _, ix := range t.Indexes() {
+		delete(c.indexes, ix.Name)
+	}
+	delete(c.tables, name)
+	return nil
+}
+
+// 在表的一列上新建索引，并加入表中已有的行
+func (c *Catalog) CreateIndex(name, table, column string) (*Index, error) {
+	name = strings.ToLower(name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.indexes[name]; ok {
+		return nil, fmt.Errorf("%s: %w", name, ErrIndexExist)
+	}
+	t, ok := c.tables[strings.ToLower(table)]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", strings.ToLower(table), ErrTableNotExist)
+	}
+	col, ok := t.Column(column)
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown column %s", t.Name, strings.ToLower(column))
+	}
+	ix := &Index{Name: name, Column: col, t: t, bt: index.New(c.m)}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var ierr error
+	err := t.Scan(index.ScanRange{}, func(row Row) bool {
+		ierr = ix.insert(row)
+		return ierr == nil
+	})
+	if err == nil {
+		err = ierr
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.indexes = append(t.indexes, ix)
+	c.indexes[name] = ix
+	return ix, nil
+}
+
+func (c *Catalog) DropIndex(name string) error {
+	name = strings.ToLower(name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ix, ok := c.indexes[name]
+	if !ok {
+		return fmt.Errorf("%s: %w", name, ErrIndexNotExist)
+	}
+	t := ix.t
+	t.mu.Lock()
+	for i, other := range t.indexes {
+		if other == ix {
+			t.indexes = append(t.indexes[:i:i], t.indexes[i+1:]...)
+			break
+		}
+	}
+	t.mu.Unlock()
+	delete(c.indexes, name)
+	return nil
+}
+
+func (c *Catalog) Table(name string) (*Table, error) {
+	name = strings.ToLower(name)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	t, ok := c.tables[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, ErrTableNotExist)
+	}
+	return t, nil
+}
+
+// 所有的表，按表名排序
+func (c *Catalog) Tables() []*Table {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	tables := make([]*Table, 0, len(c.tables))
+	for _, t := range c.tables {
+		tables = append(tables, t)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+	return tables
+}
@@ -0,0 +1,302 @@
+package catalog
+
+import (
+	"HwyDB/index"
+	"errors"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func usersSchema(t *testing.T) *Schema {
+	s, err := NewSchema("Users", []Column{{"id", Int}, {"Name", String}, {"age", Int}, {"score", Float}, {"vip", Bool}}, "ID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNewSchema(t *testing.T) {
+	s := usersSchema(t)
+	if s.Name != "users" || s.PK != 0 || s.Columns[1].Name != "name" {
+		t.Fatalf("schema: %+v", s)
+	}
+	if got := s.String(); got != "users (id int primary key, name string, age int, score float, vip bool)" {
+		t.Fatal(got)
+	}
+	cases := []struct {
+		name    string
+		columns []Column
+		pk      string
+	}{
+		{"", []Column{{"id", Int}}, "id"},
+		{"t", nil, "id"},
+		{"t", []Column{{"id", Int}, {"ID", String}}, "id"},
+		{"t", []Column{{"id", Int}}, "name"},
+		{"t", []Column{{"id", Int}}, ""},
+		{"t", []Column{{"id", Type(10)}}, "id"},
+		{"t", []Column{{"ok", Bool}, {"id", Int}}, "ok"},
+	}
+	for _, c := range cases {
+		if _, err := NewSchema(c.name, c.columns, c.pk); err == nil {
+			t.Fatalf("%+v should fail", c)
+		}
+	}
+	if ty, ok := ParseType("TEXT"); !ok || ty != String {
+		t.Fatal("text should be string")
+	}
+	if _, ok := ParseType("blob"); ok {
+		t.Fatal("blob is not supported")
+	}
+}
+
+func TestSchema_Validate(t *testing.T) {
+	s := usersSchema(t)
+	row, err := s.Validate(Row{1, "hwy", int32(29), 3, true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(row, Row{int64(1), "hwy", int64(29), 3.0, true}) {
+		t.Fatalf("got %#v", row)
+	}
+	if _, err := s.Validate(Row{1, nil, nil, nil, nil}); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []Row{
+		{1, "hwy", 29, 1.5},
+		{nil, "hwy", 29, 1.5, true},
+		{1, 2, 29, 1.5, true},
+		{1, "hwy", 29.5, 1.5, true},
+		{1, "hwy", 29, "1.5", true},
+		{1, "hwy", 29, 1.5, 1},
+		{1, "hwy", 29, 1.5, true, 0},
+	} {
+		if _, err := s.Validate(row); err == nil {
+			t.Fatalf("%v should fail", row)
+		}
+	}
+}
+
+func TestRowEncoding(t *testing.T) {
+	rows := []Row{
+		{},
+		{nil},
+		{int64(0), int64(-1), int64(math.MaxInt64), int64(math.MinInt64)},
+		{1.5, math.Inf(-1), "", "何惟禹", true, false, nil},
+	}
+	for _, row := range rows {
+		buf, err := EncodeRow(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := DecodeRow(buf)
+		if err != nil || !reflect.DeepEqual(got, row) {
+			t.Fatalf("%v: got %v, %v", row, got, err)
+		}
+		for i := 0; i < len(buf); i++ { // 截断的数据
+			if _, err := DecodeRow(buf[:i]); err == nil {
+				t.Fatalf("%v: truncated at %d should fail", row, i)
+			}
+		}
+	}
+	if _, err := EncodeRow(Row{1}); err == nil {
+		t.Fatal("int is not encoded directly")
+	}
+	if _, err := DecodeRow([]byte{1, 9}); err == nil {
+		t.Fatal("unknown tag should fail")
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	c := New(3)
+	users, err := c.Create(usersSchema(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Create(usersSchema(t)); !errors.Is(err, ErrTableExist) {
+		t.Fatalf("create twice: %v", err)
+	}
+	for i := 10; i > 0; i-- {
+		if err := users.Insert(Row{i, strings.Repeat("x", i), 20 + i, nil, i%2 == 0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := users.Insert(Row{1, "dup", 1, nil, nil}); !errors.Is(err, index.ErrKeyExist) {
+		t.Fatalf("duplicate primary key: %v", err)
+	}
+	if err := users.Insert(Row{11, 1, 1, nil, nil}); err == nil {
+		t.Fatal("invalid row should fail")
+	}
+	row, ok, err := users.Get(3)
+	if err != nil || !ok || !reflect.DeepEqual(row, Row{int64(3), "xxx", int64(23), nil, false}) {
+		t.Fatalf("get: %v, %v, %v", row, ok, err)
+	}
+	if err := users.Update(Row{3, "three", 33, 3.3, true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Update(Row{30, "x", 1, nil, nil}); !errors.Is(err, index.ErrKeyNotExist) {
+		t.Fatalf("update missing row: %v", err)
+	}
+	if err := users.Delete(10); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Delete(10); !errors.Is(err, index.ErrKeyNotExist) {
+		t.Fatalf("delete twice: %v", err)
+	}
+	if n := users.Len(); n != 9 {
+		t.Fatalf("len: %d", n)
+	}
+	var ids []interface{}
+	users.Scan(index.ScanRange{Lo: 2, Hi: 5, Desc: true}, func(row Row) bool {
+		ids = append(ids, row[0])
+		return true
+	})
+	if !reflect.DeepEqual(ids, []interface{}{int64(5), int64(4), int64(3), int64(2)}) {
+		t.Fatalf("scan: %v", ids)
+	}
+	c.Create(&Schema{Name: "a", Columns: []Column{{"k", String}}})
+	if tables := c.Tables(); len(tables) != 2 || tables[0].Name != "a" {
+		t.Fatalf("tables: %v", tables)
+	}
+	if err := c.Drop("USERS"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Table("users"); !errors.Is(err, ErrTableNotExist) {
+		t.Fatalf("dropped table: %v", err)
+	}
+	if err := c.Drop("users"); !errors.Is(err, ErrTableNotExist) {
+		t.Fatalf("drop twice: %v", err)
+	}
+}
+
+func TestKeyEncoding(t *testing.T) {
+	// 按顺序排列的值
+	values := []interface{}{
+		nil, false, true,
+		int64(math.MinInt64), int64(-2), int64(0), int64(3), int64(math.MaxInt64),
+		math.Inf(-1), -1.5, -0.0, 0.25, 1e300, math.Inf(1),
+		"", "\x00", "\x00\x00", "\x00\x01", "a", "a\x00", "ab", "何",
+	}
+	for i, v := range values {
+		key := appendKey(nil, v)
+		got, rest, err := decodeKey(append(key, 7))
+		if err != nil || got != v || !reflect.DeepEqual(rest, []byte{7}) {
+			t.Fatalf("%#v: got %#v, %v, %v", v, got, rest, err)
+		}
+		for j := i + 1; j < len(values); j++ {
+			// 后面跟着主键时顺序也不变
+			a := string(appendKey(key, int64(math.MaxInt64)))
+			b := string(appendKey(appendKey(nil, values[j]), int64(math.MinInt64)))
+			if a >= b {
+				t.Fatalf("%#v should be less than %#v", v, values[j])
+			}
+		}
+		for n := 0; n < len(key); n++ {
+			if _, _, err := decodeKey(key[:n]); err == nil {
+				t.Fatalf("%#v: truncated at %d should fail", v, n)
+			}
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	c := New(3)
+	users, _ := c.Create(usersSchema(t))
+	for i := 1; i <= 20; i++ {
+		users.Insert(Row{i, "x", 20 + i%5, nil, nil})
+	}
+	users.Insert(Row{21, "x", nil, nil, nil})
+	ix, err := c.CreateIndex("Idx_Age", "users", "AGE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ix.String() != "idx_age on users (age)" || users.IndexOn(2) != ix || users.IndexOn(1) != nil {
+		t.Fatalf("index: %v", ix)
+	}
+	if _, err := c.CreateIndex("idx_age", "users", "name"); !errors.Is(err, ErrIndexExist) {
+		t.Fatalf("create twice: %v", err)
+	}
+	if _, err := c.CreateIndex("idx_x", "users", "x"); err == nil {
+		t.Fatal("unknown column should fail")
+	}
+	if _, err := c.CreateIndex("idx_x", "t", "x"); !errors.Is(err, ErrTableNotExist) {
+		t.Fatalf("unknown table: %v", err)
+	}
+	// 修改表时同时修改索引
+	users.Insert(Row{22, "x", 23, nil, nil})
+	users.Update(Row{3, "x", 40, nil, nil})
+	users.Update(Row{4, "y", 24, nil, nil})
+	users.Delete(8)
+	// 和逐行比较的结果相同
+	check := func(r index.ScanRange) {
+		t.Helper()
+		var got, want []interface{}
+		if err := ix.Scan(r, func(pk interface{}) bool {
+			got = append(got, pk)
+			return true
+		}); err != nil {
+			t.Fatal(err)
+		}
+		var rows []Row
+		users.Scan(index.ScanRange{}, func(row Row) bool {
+			age, ok := row[2].(int64)
+			lo, hi := int64(math.MinInt64), int64(math.MaxInt64)
+			if r.Lo != nil {
+				lo = r.Lo.(int64)
+			}
+			if r.Hi != nil {
+				hi = r.Hi.(int64)
+			}
+			if ok && age >= lo && age <= hi && !(r.LoOpen && age == lo) && !(r.HiOpen && age == hi) {
+				rows = append(rows, row)
+			}
+			return true
+		})
+		sort.SliceStable(rows, func(i, j int) bool {
+			if r.Desc {
+				i, j = j, i
+			}
+			return rows[i][2].(int64) < rows[j][2].(int64) || rows[i][2] == rows[j][2] && rows[i][0].(int64) < rows[j][0].(int64)
+		})
+		for _, row := range rows {
+			want = append(want, row[0])
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%+v: got %v, want %v", r, got, want)
+		}
+	}
+	check(index.ScanRange{})
+	check(index.ScanRange{Lo: int64(23), Hi: int64(23)})
+	check(index.ScanRange{Lo: int64(22), LoOpen: true})
+	check(index.ScanRange{Hi: int64(23), HiOpen: true, Desc: true})
+	check(index.ScanRange{Lo: int64(21), Hi: int64(40), LoOpen: true, HiOpen: true, Desc: true})
+	if err := ix.Scan(index.ScanRange{Lo: "a"}, func(interface{}) bool { return true }); err == nil {
+		t.Fatal("string bound on int column should fail")
+	}
+	// 索引和表不一致时返回错误
+	ix.bt.Delete(ix.key(Row{int64(5), nil, int64(20)}))
+	if err := users.Delete(5); !errors.Is(err, index.ErrKeyNotExist) {
+		t.Fatalf("delete with a corrupt index: %v", err)
+	}
+	ix.bt.Insert(ix.key(Row{int64(30), nil, int64(30)}), nil)
+	n := users.Len()
+	if err := users.Insert(Row{30, "z", 30, nil, nil}); !errors.Is(err, index.ErrKeyExist) {
+		t.Fatalf("insert with a corrupt index: %v", err)
+	}
+	if _, ok, _ := users.Get(30); ok || users.Len() != n {
+		t.Fatalf("failed insert is not undone: len %d, want %d", users.Len(), n)
+	}
+	if err := c.DropIndex("idx_age"); err != nil || len(users.Indexes()) != 0 {
+		t.Fatalf("drop index: %v", err)
+	}
+	if err := c.DropIndex("idx_age"); !errors.Is(err, ErrIndexNotExist) {
+		t.Fatalf("drop twice: %v", err)
+	}
+	c.CreateIndex("idx_age", "users", "age")
+	c.Drop("users")
+	if _, err := c.CreateIndex("idx_age", "a", "k"); !errors.Is(err, ErrTableNotExist) {
+		t.Fatalf("index should be dropped with the table: %v", err)
+	}
+}
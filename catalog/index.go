@@ -0,0 +1,115 @@
+package catalog
+
+import (
+	"HwyDB/index"
+	"fmt"
+)
+
+// 扫描索引时每批读取的关键字数
+const scanBatch = 256
+
+// 二级索引：一棵以 (列的值, 主键) 编码之后的字符串为关键字、value为nil的B+树，
+// 按列的值排序，值相同时按主键排序
+type Index struct {
+	Name   string
+	Column int // 索引的列
+	t      *Table
+	bt     index.BT
+}
+
+func (ix *Index) Table() *Table {
+	return ix.t
+}
+
+// 形如 idx_age on users (age)
+func (ix *Index) String() string {
+	return fmt.Sprintf("%s on %s (%s)", ix.Name, ix.t.Name, ix.t.Columns[ix.Column].Name)
+}
+
+// 一行数据在索引中的关键字
+func (ix *Index) key(row Row) string {
+	return string(appendKey(appendKey(nil, row[ix.Column]), row[ix.t.PK]))
+}
+
+// 在索引中加入一行，关键字已经存在说明索引和表不一致
+func (ix *Index) insert(row Row) error {
+	if err := ix.bt.Insert(ix.key(row), nil); err != nil {
+		return fmt.Errorf("index %s is corrupt: %w", ix.Name, err)
+	}
+	return nil
+}
+
+// 从索引中删除一行，关键字不存在说明索引和表不一致
+func (ix *Index) delete(row Row) error {
+	if err := ix.bt.Delete(ix.key(row)); err != nil {
+		return fmt.Errorf("index %s is corrupt: %w", ix.Name, err)
+	}
+	return nil
+}
+
+// 按列的值的顺序遍历r范围内的行的主键，fn返回false时停止
+// r中的Lo、Hi是列的值（需要能转换成列的类型），为nil时这一端不限；值为null的行不在结果中
+func (ix *Index) Scan(r index.ScanRange, fn func(pk interface{}) bool) error {
+	kr := index.ScanRange{Lo: string([]byte{keyNull + 1}), Desc: r.Desc}
+	if r.Lo != nil {
+		lo, err := ix.bound(r.Lo)
+		if err != nil {
+			return err
+		}
+		kr.Lo = lo
+		if r.LoOpen { // 跳过值等于Lo的所有关键字
+			kr.Lo = index.PrefixRange(lo).Hi
+		}
+	}
+	if r.Hi != nil {
+		hi, err := ix.bound(r.Hi)
+		if err != nil {
+			return err
+		}
+		kr.Hi, kr.HiOpen = hi, true
+		if !r.HiOpen {
+			kr.Hi = index.PrefixRange(hi).Hi
+		}
+	}
+	// 每次在锁内读取一批关键字，释放锁之后再交给fn，fn中可以读写这个表
+	keys := make([]string, 0, scanBatch)
+	for {
+		keys = keys[:0]
+		ix.bt.Scan(kr, func(key, _ interface{}) bool {
+			keys = append(keys, key.(string))
+			return len(keys) < scanBatch
+		})
+		for _, key := range keys {
+			_, rest, err := decodeKey([]byte(key))
+			if err != nil {
+				return err
+			}
+			pk, _, err := decodeKey(rest)
+			if err != nil {
+				return err
+			}
+			if !fn(pk) {
+				return nil
+			}
+		}
+		if len(keys) < scanBatch {
+			return nil
+		}
+		// 从这一批的最后一个关键字之后继续
+		if kr.Desc {
+			kr.Hi, kr.HiOpen = keys[len(keys)-1], true
+		} else {
+			kr.Lo, kr.LoOpen = keys[len(keys)-1], true
+		}
+	}
+}
+
+// 把范围的端点转换成列的类型之后编码
+func (ix *Index) bound(v interface{}) (string, error) {
+	c := ix.t.Columns[ix.Column]
+	cv, ok := convert(c.Type, v)
+	if !ok {
+		return "", fmt.Errorf("index %s: column %s is %v, got %T", ix.Name, c.Name, c.Type, v)
+	}
+	return string(appendKey(nil, cv)), nil
+}
@@ -0,0 +1,109 @@
+package catalog
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var errBadKey = errors.New("catalog: bad index key")
+
+// 索引关键字中每个值的类型标记，不同类型之间 null < bool < int < float < string
+const (
+	keyNull uint8 = iota
+	keyBool
+	keyInt
+	keyFloat
+	keyString
+)
+
+// 把值编码成保持顺序的字节：按字节比较编码的结果和按值比较的结果相同，
+// 所以几个值编码之后拼在一起可以作为B+树的字符串关键字
+//
+//	int64    符号位取反之后的8字节大端序
+//	float64  正数符号位取反，负数所有位取反，之后是8字节大端序
+//	string   内容中的0x00换成0x00 0xff，以0x00 0x01结束，保证字符串比以它为前缀的更长的字符串小
+func appendKey(buf []byte, v interface{}) []byte {
+	var tmp [8]byte
+	switch v := v.(type) {
+	case nil:
+		return append(buf, keyNull)
+	case bool:
+		if v {
+			return append(buf, keyBool, 1)
+		}
+		return append(buf, keyBool, 0)
+	case int64:
+		binary.BigEndian.PutUint64(tmp[:], uint64(v)^1<<63)
+		return append(append(buf, keyInt), tmp[:]...)
+	case float64:
+		bits := math.Float64bits(v)
+		if bits&(1<<63) != 0 {
+			bits = ^bits
+		} else {
+			bits |= 1 << 63
+		}
+		binary.BigEndian.PutUint64(tmp[:], bits)
+		return append(append(buf, keyFloat), tmp[:]...)
+	case string:
+		buf = append(buf, keyString)
+		for i := 0; i < len(v); i++ {
+			if v[i] == 0 {
+				buf = append(buf, 0, 0xff)
+			} else {
+				buf = append(buf, v[i])
+			}
+		}
+		return append(buf, 0, 1)
+	}
+	panic("catalog: unsupported key type")
+}
+
+// 解码buf开头的一个值，返回剩下的部分
+func decodeKey(buf []byte) (interface{}, []byte, error) {
+	if len(buf) == 0 {
+		return nil, nil, errBadKey
+	}
+	tag, buf := buf[0], buf[1:]
+	switch tag {
+	case keyNull:
+		return nil, buf, nil
+	case keyBool:
+		if len(buf) < 1 {
+			return nil, nil, errBadKey
+		}
+		return buf[0] == 1, buf[1:], nil
+	case keyInt, keyFloat:
+		if len(buf) < 8 {
+			return nil, nil, errBadKey
+		}
+		bits := binary.BigEndian.Uint64(buf)
+		if tag == keyInt {
+			return int64(bits ^ 1<<63), buf[8:], nil
+		}
+		if bits&(1<<63) != 0 {
+			bits &^= 1 << 63
+		} else {
+			bits = ^bits
+		}
+		return math.Float64frombits(bits), buf[8:], nil
+	case keyString:
+		s := make([]byte, 0, len(buf))
+		for i := 0; i+1 < len(buf); i++ {
+			if buf[i] != 0 {
+				s = append(s, buf[i])
+				continue
+			}
+			switch buf[i+1] {
+			case 1:
+				return string(s), buf[i+2:], nil
+			case 0xff:
+				s = append(s, 0)
+				i++
+			default:
+				return nil, nil, errBadKey
+			}
+		}
+	}
+	return nil, nil, errBadKey
+}
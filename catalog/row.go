@@ -0,0 +1,101 @@
+package catalog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// 一行数据，按表结构中列的顺序
+type Row []interface{}
+
+var errBadRow = errors.New("catalog: bad row encoding")
+
+// 每个值的类型标记
+const (
+	tagNull uint8 = iota
+	tagInt
+	tagFloat
+	tagString
+	tagFalse
+	tagTrue
+)
+
+// 把一行数据编码成字节：列数(uvarint)，之后每列是 类型标记 + 值
+// int64是varint，float64是8字节小端序，string是 长度(uvarint) + 内容
+func EncodeRow(row Row) ([]byte, error) {
+	buf := make([]byte, 0, 16*len(row))
+	var tmp [binary.MaxVarintLen64]byte
+	buf = append(buf, tmp[:binary.PutUvarint(tmp[:], uint64(len(row)))]...)
+	for i, v := range row {
+		switch v := v.(type) {
+		case nil:
+			buf = append(buf, tagNull)
+		case int64:
+			buf = append(buf, tagInt)
+			buf = append(buf, tmp[:binary.PutVarint(tmp[:], v)]...)
+		case float64:
+			binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(v))
+			buf = append(buf, tagFloat)
+			buf = append(buf, tmp[:8]...)
+		case string:
+			buf = append(buf, tagString)
+			buf = append(buf, tmp[:binary.PutUvarint(tmp[:], uint64(len(v)))]...)
+			buf = append(buf, v...)
+		case bool:
+			if v {
+				buf = append(buf, tagTrue)
+			} else {
+				buf = append(buf, tagFalse)
+			}
+		default:
+			return nil, fmt.Errorf("catalog: column %d has unsupported type %T", i, v)
+		}
+	}
+	return buf, nil
+}
+
+func DecodeRow(buf []byte) (Row, error) {
+	n, w := binary.Uvarint(buf)
+	if w <= 0 || n > uint64(len(buf)) {
+		return nil, errBadRow
+	}
+	buf = buf[w:]
+	row := make(Row, n)
+	for i := range row {
+		if len(buf) == 0 {
+			return nil, errBadRow
+		}
+		tag := buf[0]
+		buf = buf[1:]
+		switch tag {
+		case tagNull:
+		case tagInt:
+			v, w := binary.Varint(buf)
+			if w <= 0 {
+				return nil, errBadRow
+			}
+			row[i], buf = v, buf[w:]
+		case tagFloat:
+			if len(buf) < 8 {
+				return nil, errBadRow
+			}
+			row[i], buf = math.Float64frombits(binary.LittleEndian.Uint64(buf)), buf[8:]
+		case tagString:
+			l, w := binary.Uvarint(buf)
+			if w <= 0 || l > uint64(len(buf)-w) {
+				return nil, errBadRow
+			}
+			row[i], buf = string(buf[w:w+int(l)]), buf[w+int(l):]
+		case tagFalse, tagTrue:
+			row[i] = tag == tagTrue
+		default:
+			return nil, errBadRow
+		}
+	}
+	if len(buf) != 0 {
+		return nil, errBadRow
+	}
+	return row, nil
+}
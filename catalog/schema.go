@@ -0,0 +1,170 @@
+// catalog 管理关系表：表结构（列及其类型）以及每个表对应的B+树
+// 每个表是一棵以主键为关键字的index.BT，value是按列编码的一行数据
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrTableExist    = errors.New("table already exists")
+	ErrTableNotExist = errors.New("table does not exist")
+	ErrIndexExist    = errors.New("index already exists")
+	ErrIndexNotExist = errors.New("index does not exist")
+)
+
+// 列的类型
+type Type int
+
+const (
+	Int    Type = iota // int64
+	Float              // float64
+	String             // string
+	Bool               // bool
+)
+
+var typeNames = map[Type]string{
+	Int:    "int",
+	Float:  "float",
+	String: "string",
+	Bool:   "bool",
+}
+
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("Type(%d)", int(t))
+}
+
+// 根据类型名（不区分大小写）获取类型
+func ParseType(name string) (Type, bool) {
+	switch strings.ToLower(name) {
+	case "int", "integer":
+		return Int, true
+	case "float", "double":
+		return Float, true
+	case "string", "text":
+		return String, true
+	case "bool", "boolean":
+		return Bool, true
+	}
+	return 0, false
+}
+
+type Column struct {
+	Name string
+	Type Type
+}
+
+// 表结构
+type Schema struct {
+	Name    string
+	Columns []Column
+	PK      int // 主键所在的列
+}
+
+// 检查表名、列名（统一成小写）以及主键
+func NewSchema(name string, columns []Column, pk string) (*Schema, error) {
+	s := &Schema{Name: strings.ToLower(name), PK: -1}
+	if s.Name == "" {
+		return nil, errors.New("table name is empty")
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s has no columns", s.Name)
+	}
+	for _, c := range columns {
+		c.Name = strings.ToLower(c.Name)
+		if _, ok := s.Column(c.Name); ok {
+			return nil, fmt.Errorf("table %s: duplicate column %s", s.Name, c.Name)
+		}
+		if _, ok := typeNames[c.Type]; !ok {
+			return nil, fmt.Errorf("table %s: column %s has unknown type %v", s.Name, c.Name, c.Type)
+		}
+		s.Columns = append(s.Columns, c)
+	}
+	if s.PK, _ = s.Column(pk); pk == "" || s.PK < 0 {
+		return nil, fmt.Errorf("table %s: primary key %q is not a column", s.Name, pk)
+	}
+	// index.BT的关键字只能是数字和字符串
+	if s.Columns[s.PK].Type == Bool {
+		return nil, fmt.Errorf("table %s: primary key %s can not be bool", s.Name, s.Columns[s.PK].Name)
+	}
+	return s, nil
+}
+
+// 列名对应的序号，不存在时返回-1
+func (s *Schema) Column(name string) (int, bool) {
+	name = strings.ToLower(name)
+	for i, c := range s.Columns {
+		if c.Name == name {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// 检查一行数据是否符合表结构，返回转换成列类型之后的数据（整数转换成int64，整数可以存入float列）
+// 除主键之外的列可以是null
+func (s *Schema) Validate(row Row) (Row, error) {
+	if len(row) != len(s.Columns) {
+		return nil, fmt.Errorf("table %s has %d columns, got %d values", s.Name, len(s.Columns), len(row))
+	}
+	out := make(Row, len(row))
+	for i, v := range row {
+		c := s.Columns[i]
+		if v == nil {
+			if i == s.PK {
+				return nil, fmt.Errorf("primary key %s can not be null", c.Name)
+			}
+			continue
+		}
+		cv, ok := convert(c.Type, v)
+		if !ok {
+			return nil, fmt.Errorf("column %s: want %v, got %T", c.Name, c.Type, v)
+		}
+		out[i] = cv
+	}
+	return out, nil
+}
+
+func convert(t Type, v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case int:
+		return convert(t, int64(v))
+	case int8:
+		return convert(t, int64(v))
+	case int16:
+		return convert(t, int64(v))
+	case int32:
+		return convert(t, int64(v))
+	case float32:
+		return convert(t, float64(v))
+	case int64:
+		if t == Float {
+			return float64(v), true
+		}
+		return v, t == Int
+	case float64:
+		return v, t == Float
+	case string:
+		return v, t == String
+	case bool:
+		return v, t == Bool
+	}
+	return nil, false
+}
+
+// 形如 users (id int primary key, name string)
+func (s *Schema) String() string {
+	cols := make([]string, len(s.Columns))
+	for i, c := range s.Columns {
+		cols[i] = c.Name + " " + c.Type.String()
+		if i == s.PK {
+			cols[i] += " primary key"
+		}
+	}
+	return fmt.Sprintf("%s (%s)", s.Name, strings.Join(cols, ", "))
+}
@@ -0,0 +1,160 @@
+package catalog
+
+import (
+	"HwyDB/index"
+	"fmt"
+	"sync"
+)
+
+// 表：以主键为关键字、编码之后的行为value的B+树，以及表上的二级索引
+// 修改表时同时修改所有的索引，mu保证修改表和索引的过程不会交错
+type Table struct {
+	*Schema
+	bt      index.BT
+	mu      sync.RWMutex
+	indexes []*Index
+	rows    int // 行数，用来估计查询的代价
+}
+
+// 插入一行，主键已经存在时返回index.ErrKeyExist
+func (t *Table) Insert(row Row) error {
+	row, buf, err := t.encode(row)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.bt.Insert(row[t.PK], buf); err != nil {
+		return fmt.Errorf("%s: %w", t.Name, err)
+	}
+	for i, ix := range t.indexes {
+		if err := ix.insert(row); err != nil {
+			// 撤销已经做的修改，表和其它索引保持原样
+			for _, done := range t.indexes[:i] {
+				done.delete(row)
+			}
+			t.bt.Delete(row[t.PK])
+			return err
+		}
+	}
+	t.rows++
+	return nil
+}
+
+// 用row替换主键相同的那一行，不存在时返回index.ErrKeyNotExist
+func (t *Table) Update(row Row) error {
+	row, buf, err := t.encode(row)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	old, _, err := t.Get(row[t.PK])
+	if err != nil {
+		return err
+	}
+	if err := t.bt.Update(row[t.PK], buf); err != nil {
+		return fmt.Errorf("%s: %w", t.Name, err)
+	}
+	for _, ix := range t.indexes {
+		if old[ix.Column] == row[ix.Column] {
+			continue
+		}
+		if err := ix.delete(old); err != nil {
+			return err
+		}
+		if err := ix.insert(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Table) Delete(pk interface{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	old, _, err := t.Get(pk)
+	if err != nil {
+		return err
+	}
+	if err := t.bt.Delete(pk); err != nil {
+		return fmt.Errorf("%s: %w", t.Name, err)
+	}
+	t.rows--
+	for _, ix := range t.indexes {
+		if err := ix.delete(old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 表中的行数
+func (t *Table) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.rows
+}
+
+// 表上所有的索引
+func (t *Table) Indexes() []*Index {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return append([]*Index(nil), t.indexes...)
+}
+
+// 列上的索引，没有时返回nil
+func (t *Table) IndexOn(column int) *Index {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, ix := range t.indexes {
+		if ix.Column == column {
+			return ix
+		}
+	}
+	return nil
+}
+
+// 按主键查找一行
+func (t *Table) Get(pk interface{}) (Row, bool, error) {
+	v, ok := t.bt.Get(pk)
+	if !ok {
+		return nil, false, nil
+	}
+	row, err := t.decode(v)
+	return row, err == nil, err
+}
+
+// 按主键的顺序遍历r范围内的行，fn返回false时停止
+func (t *Table) Scan(r index.ScanRange, fn func(row Row) bool) error {
+	var err error
+	t.bt.Scan(r, func(key, value interface{}) bool {
+		var row Row
+		if row, err = t.decode(value); err != nil {
+			return false
+		}
+		return fn(row)
+	})
+	return err
+}
+
+func (t *Table) encode(row Row) (Row, []byte, error) {
+	row, err := t.Validate(row)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", t.Name, err)
+	}
+	buf, err := EncodeRow(row)
+	return row, buf, err
+}
+
+func (t *Table) decode(v interface{}) (Row, error) {
+	buf, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%s: bad row type %T", t.Name, v)
+	}
+	row, err := DecodeRow(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", t.Name, err)
+	}
+	return row, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxHistory = 1000
+
+var errInterrupt = errors.New("interrupt") // Ctrl-C
+
+// 行编辑器：终端中支持光标移动、历史记录；不是终端时按行读取
+type lineReader struct {
+	in      *bufio.Reader
+	out     io.Writer
+	fd      int // 终端的文件描述符，-1表示不是终端
+	history []string
+}
+
+func newLineReader(in *os.File, out io.Writer) *lineReader {
+	lr := &lineReader{in: bufio.NewReader(in), out: out, fd: -1}
+	if isTerminal(int(in.Fd())) {
+		lr.fd = int(in.Fd())
+	}
+	return lr
+}
+
+func (lr *lineReader) isTerminal() bool {
+	return lr.fd >= 0
+}
+
+// 读取一行（不包括换行符）
+func (lr *lineReader) readLine(prompt string) (string, error) {
+	if lr.fd < 0 {
+		return lr.readPlain()
+	}
+	restore, err := makeRaw(lr.fd)
+	if err != nil {
+		fmt.Fprint(lr.out, prompt)
+		return lr.readPlain()
+	}
+	defer restore()
+	return lr.edit(prompt)
+}
+
+func (lr *lineReader) readPlain() (string, error) {
+	line, err := lr.in.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// 终端中的行编辑
+func (lr *lineReader) edit(prompt string) (string, error) {
+	line := make([]rune, 0)
+	cursor := 0
+	hidx := len(lr.history) // 正在浏览的历史记录，len(history)表示当前输入
+	saved := ""             // 浏览历史记录之前的输入
+	redraw := func() {
+		fmt.Fprintf(lr.out, "\r%s%s\x1b[K", prompt, string(line))
+		if back := width(line[cursor:]); back > 0 {
+			fmt.Fprintf(lr.out, "\x1b[%dD", back)
+		}
+	}
+	setLine := func(s string) {
+		line = []rune(s)
+		cursor = len(line)
+	}
+	redraw()
+	for {
+		r, _, err := lr.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(lr.out, "\r\n")
+			return string(line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(lr.out, "^C\r\n")
+			return "", errInterrupt
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(lr.out, "\r\n")
+				return "", io.EOF
+			}
+			if cursor < len(line) {
+				line = append(line[:cursor], line[cursor+1:]...)
+			}
+		case 127, 8: // Backspace
+			if cursor > 0 {
+				line = append(line[:cursor-1], line[cursor:]...)
+				cursor--
+			}
+		case 1: // Ctrl-A
+			cursor = 0
+		case 5: // Ctrl-E
+			cursor = len(line)
+		case 2: // Ctrl-B
+			if cursor > 0 {
+				cursor--
+			}
+		case 6: // Ctrl-F
+			if cursor < len(line) {
+				cursor++
+			}
+		case 21: // Ctrl-U
+			line = line[:0]
+			cursor = 0
+		case 27: // 转义序列：方向键等
+			seq := lr.readEscape()
+			switch seq {
+			case "[A", "OA": // 上
+				if hidx > 0 {
+					if hidx == len(lr.history) {
+						saved = string(line)
+					}
+					hidx--
+					setLine(lr.history[hidx])
+				}
+			case "[B", "OB": // 下
+				if hidx < len(lr.history) {
+					hidx++
+					if hidx == len(lr.history) {
+						setLine(saved)
+					} else {
+						setLine(lr.history[hidx])
+					}
+				}
+			case "[C", "OC": // 右
+				if cursor < len(line) {
+					cursor++
+				}
+			case "[D", "OD": // 左
+				if cursor > 0 {
+					cursor--
+				}
+			case "[H", "OH", "[1~":
+				cursor = 0
+			case "[F", "OF", "[4~":
+				cursor = len(line)
+			case "[3~": // Delete
+				if cursor < len(line) {
+					line = append(line[:cursor], line[cursor+1:]...)
+				}
+			}
+		default:
+			if r < 32 {
+				continue
+			}
+			line = append(line[:cursor], append([]rune{r}, line[cursor:]...)...)
+			cursor++
+		}
+		redraw()
+	}
+}
+
+// 读取ESC之后的转义序列，如 [A、[3~
+func (lr *lineReader) readEscape() string {
+	var seq []rune
+	for {
+		r, _, err := lr.in.ReadRune()
+		if err != nil {
+			return string(seq)
+		}
+		seq = append(seq, r)
+		if len(seq) == 1 && r != '[' && r != 'O' {
+			return string(seq)
+		}
+		if len(seq) > 1 && (r == '~' || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z')) {
+			return string(seq)
+		}
+	}
+}
+
+// 字符在终端中占的列数（中文等宽字符占两列）
+func width(rs []rune) int {
+	w := 0
+	for _, r := range rs {
+		if r >= 0x1100 && utf8.RuneLen(r) > 2 {
+			w += 2
+		} else {
+			w++
+		}
+	}
+	return w
+}
+
+// 添加历史记录，和上一条相同时不重复添加
+func (lr *lineReader) addHistory(line string) {
+	if line == "" || (len(lr.history) > 0 && lr.history[len(lr.history)-1] == line) {
+		return
+	}
+	lr.history = append(lr.history, line)
+	if len(lr.history) > maxHistory {
+		lr.history = lr.history[len(lr.history)-maxHistory:]
+	}
+}
+
+func (lr *lineReader) loadHistory(path string) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		lr.addHistory(strings.TrimSpace(line))
+	}
+}
+
+func (lr *lineReader) saveHistory(path string) {
+	if len(lr.history) == 0 {
+		return
+	}
+	ioutil.WriteFile(path, []byte(strings.Join(lr.history, "\n")+"\n"), 0600)
+}
@@ -0,0 +1,353 @@
+// hwydb 交互式命令行：输入语句（以;结束，可以跨多行），在内存中的B+树上执行
+//
+//	hwydb [-m 5] [seed.hql ...]
+//	hwydb -fmt [file.hql ...]
+//
+// 参数中的脚本文件会在进入交互模式之前依次执行
+// -fmt 把文件（没有文件时读标准输入）中的语句格式化之后输出，不执行
+package main
+
+import (
+	"HwyDB/index"
+	"HwyDB/sql"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	prompt     = "hwydb> "
+	txPrompt   = "hwydb*> " // 在事务中
+	contPrompt = "   ...> "
+)
+
+const helpText = `语句以;结束，可以跨多行输入：
+  insert <key> <value>;   插入
+  find <key>;             查找
+  find between <a> and <b> | > <a> | >= <a> | < <a> | <= <a> | prefix '<p>'
+       [order by key asc|desc] [limit <n>];  范围查询
+  update <key> <value>;   更新
+  delete <key>;           删除
+  incr <key> [n];         原子地加n（默认1），decr同理
+  append <key> <value>;   原子地在字符串之后追加
+  value可以是表达式：+ - * / %、括号、len upper lower concat now abs，
+  表达式中的标识符表示这个关键字的value，如 update counter counter + 1;
+  create table <t> (<列> <类型> [primary key], ...);  建表，类型：int float string bool
+  drop table <t>;         删除表
+  create index <i> on <t> (<列>);  在列上建索引，drop index <i>; 删除索引
+  insert into <t> [(<列>, ...)] values (<value>, ...), ...;  向表中插入
+  update <t> set <列> = <表达式>, ... [where <条件>];  修改表中的行
+  delete from <t> [where <条件>];  删除表中的行
+  select <表达式> [as <名字>], ... | * from <t> [[as] <别名>]
+       [[inner | left [outer]] join <t> [[as] <别名>] on <条件> ...] [where <条件>]
+       [group by <表达式>, ...] [having <条件>]
+       [order by <表达式> [asc|desc], ...] [limit <n>];  查询表
+  聚合函数：count(*) count sum avg min max
+  begin; ... commit; | rollback;  事务：之间的修改一起提交或者撤销
+  explain <语句>;         显示语句的语法树和执行计划（访问路径、使用的索引、估计的行数），不执行语句
+  -- 注释、/* 注释 */
+命令：
+  .help                   显示帮助
+  .stats                  显示树的统计信息
+  .dump                   按关键字顺序输出所有数据
+  .tables                 显示所有的表以及索引
+  .timing on|off          是否显示执行时间
+  .exit                   退出（也可以用 Ctrl-D）
+`
+
+type repl struct {
+	db     *sql.DB
+	sess   *sql.Session // 所有的语句在同一个会话中执行
+	tree   index.BT
+	out    io.Writer
+	timing bool
+}
+
+func newRepl(m int, out io.Writer) *repl {
+	tree := index.New(m)
+	db := sql.Open(tree)
+	return &repl{db: db, sess: db.NewSession(), tree: tree, out: out, timing: true}
+}
+
+func main() {
+	os.Exit(realMain())
+}
+
+// 解析参数并运行，返回退出码；出错时返回而不是直接os.Exit，defer的保存历史记录才会执行
+func realMain() int {
+	m := flag.Int("m", 5, "B+树的阶数")
+	historyFile := flag.String("history", defaultHistoryFile(), "历史记录文件，为空时不保存")
+	fmtMode := flag.Bool("fmt", false, "格式化语句之后输出，不执行")
+	flag.Parse()
+
+	if *fmtMode {
+		if err := formatFiles(flag.Args(), os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	r := newRepl(*m, os.Stdout)
+	for _, file := range flag.Args() {
+		if err := r.execFile(file); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	lr := newLineReader(os.Stdin, os.Stdout)
+	if *historyFile != "" {
+		lr.loadHistory(*historyFile)
+		defer lr.saveHistory(*historyFile)
+	}
+	if lr.isTerminal() {
+		fmt.Fprintln(os.Stdout, "HwyDB, 输入 .help 查看帮助")
+	}
+	err := r.run(lr)
+	r.close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// 格式化文件中的语句，没有文件时格式化in
+func formatFiles(files []string, in io.Reader, out io.Writer) error {
+	if len(files) == 0 {
+		data, err := ioutil.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		return formatText(string(data), out)
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := formatText(string(data), out); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func formatText(text string, out io.Writer) error {
+	s, err := sql.Format(text)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, s)
+	return err
+}
+
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".hwydb_history")
+}
+
+// 读取输入直到EOF或者.exit
+func (r *repl) run(lr *lineReader) error {
+	var buf strings.Builder // 还没结束的语句
+	for {
+		p := prompt
+		if r.sess.InTransaction() {
+			p = txPrompt
+		}
+		if buf.Len() > 0 {
+			p = contPrompt
+		}
+		line, err := lr.readLine(p)
+		if err == errInterrupt { // Ctrl-C 丢弃正在输入的语句
+			buf.Reset()
+			continue
+		}
+		if err == io.EOF {
+			if buf.Len() > 0 {
+				r.execAll(buf.String())
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		trimmed := strings.TrimSpace(line)
+		if buf.Len() == 0 && strings.HasPrefix(trimmed, ".") {
+			lr.addHistory(trimmed)
+			if r.command(trimmed) {
+				return nil
+			}
+			continue
+		}
+		if trimmed == "" && buf.Len() == 0 {
+			continue
+		}
+		buf.WriteString(line)
+		buf.WriteString("\n")
+		if sql.Complete(buf.String()) { // ;在引号、注释中时语句还没有结束
+			stmt := buf.String()
+			buf.Reset()
+			lr.addHistory(strings.Join(strings.Fields(stmt), " "))
+			r.execAll(stmt)
+		}
+	}
+}
+
+// 退出时回滚没有提交的事务
+func (r *repl) close() {
+	if r.sess.InTransaction() {
+		if err := r.sess.Close(); err != nil {
+			fmt.Fprintln(r.out, "error:", err)
+			return
+		}
+		fmt.Fprintln(r.out, "transaction rolled back")
+	}
+}
+
+// 执行以;分隔的多条语句
+func (r *repl) execAll(input string) {
+	r.execScript(strings.NewReader(input))
+}
+
+// 执行脚本文件
+func (r *repl) execFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := r.execScript(f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// 逐条执行语句并输出结果
+func (r *repl) execScript(in io.Reader) error {
+	start := time.Now()
+	return r.sess.ExecScript(in, func(sr *sql.StmtResult) bool {
+		elapsed := time.Since(start)
+		if sr.Err != nil {
+			fmt.Fprintln(r.out, "error:", sr.Err)
+		} else {
+			r.printResult(sr.Result)
+		}
+		if r.timing {
+			fmt.Fprintf(r.out, "(%v)\n", elapsed.Round(time.Microsecond))
+		}
+		start = time.Now()
+		return true
+	})
+}
+
+func (r *repl) printResult(ret *sql.Result) {
+	if ret.Values != nil { // 范围查询
+		for i, key := range ret.Keys {
+			fmt.Fprintf(r.out, "%v\t%s\n", key, formatValue(ret.Values[i]))
+		}
+		fmt.Fprintf(r.out, "(%d keys)\n", len(ret.Keys))
+		return
+	}
+	if ret.Rows != nil {
+		r.printRows(ret)
+		return
+	}
+	switch ret.Statement {
+	case "create table", "drop table", "create index", "drop index", "begin", "commit", "rollback":
+		fmt.Fprintln(r.out, "OK")
+		return
+	case "insert into", "update set", "delete from":
+		fmt.Fprintf(r.out, "OK, %d row(s) affected\n", len(ret.Keys))
+		return
+	case "incr", "decr", "append":
+		fmt.Fprintln(r.out, formatValue(ret.Value))
+		return
+	case "explain":
+		fmt.Fprint(r.out, ret.Value)
+		return
+	case "find":
+		if ret.Found {
+			fmt.Fprintln(r.out, formatValue(ret.Value))
+		} else {
+			fmt.Fprintln(r.out, "(not found)")
+		}
+		return
+	}
+	fmt.Fprintf(r.out, "OK, %d key(s) affected\n", len(ret.Keys))
+}
+
+// select的结果按列对齐输出
+func (r *repl) printRows(ret *sql.Result) {
+	w := tabwriter.NewWriter(r.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(ret.Columns, "\t"))
+	for _, row := range ret.Rows {
+		fields := make([]string, len(row))
+		for i, v := range row {
+			fields[i] = formatValue(v)
+		}
+		fmt.Fprintln(w, strings.Join(fields, "\t"))
+	}
+	w.Flush()
+	fmt.Fprintf(r.out, "(%d rows)\n", len(ret.Rows))
+}
+
+func formatValue(v interface{}) string {
+	if v == nil {
+		return "null"
+	}
+	return fmt.Sprint(v)
+}
+
+// 执行.开头的命令，返回是否退出
+func (r *repl) command(line string) bool {
+	args := strings.Fields(line)
+	switch args[0] {
+	case ".help":
+		fmt.Fprint(r.out, helpText)
+	case ".exit", ".quit":
+		return true
+	case ".stats":
+		st := r.tree.Stats()
+		fmt.Fprintf(r.out, "order m:        %d\n", st.M)
+		fmt.Fprintf(r.out, "height:         %d\n", st.Height)
+		fmt.Fprintf(r.out, "nodes/level:    %v (leaf -> root)\n", st.Levels)
+		fmt.Fprintf(r.out, "keys:           %d (%d expired)\n", st.Keys, st.Expired)
+		fmt.Fprintf(r.out, "avg leaf fill:  %.1f%%\n", st.AvgLeafFill*100)
+		fmt.Fprintf(r.out, "bytes:          keys %d, index keys %d, values %d\n", st.KeyBytes, st.IndexKeyBytes, st.ValueBytes)
+		fmt.Fprintf(r.out, "splits/merges/borrows: %d/%d/%d\n", st.Splits, st.Merges, st.Borrows)
+	case ".dump":
+		n := 0
+		r.tree.Scan(index.ScanRange{}, func(key, value interface{}) bool {
+			fmt.Fprintf(r.out, "%v\t%v\n", key, value)
+			n++
+			return true
+		})
+		fmt.Fprintf(r.out, "(%d keys)\n", n)
+	case ".tables":
+		for _, t := range r.db.Catalog().Tables() {
+			fmt.Fprintln(r.out, t.Schema)
+			for _, ix := range t.Indexes() {
+				fmt.Fprintln(r.out, "  index", ix)
+			}
+		}
+	case ".timing":
+		if len(args) != 2 || (args[1] != "on" && args[1] != "off") {
+			fmt.Fprintln(r.out, "usage: .timing on|off")
+			break
+		}
+		r.timing = args[1] == "on"
+	default:
+		fmt.Fprintf(r.out, "unknown command %s, see .help\n", args[0])
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRepl_run(t *testing.T) {
+	input := "insert age 29;\ninsert name\n'hwy';\nfind name; find age;\n.timing off\n.dump\nfind sex;\nfind prefix a order by key desc;\n.exit\nfind age;\n"
+	var out bytes.Buffer
+	r := newRepl(3, &out)
+	lr := &lineReader{in: bufio.NewReader(strings.NewReader(input)), out: &out, fd: -1}
+	if err := r.run(lr); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{"hwy\n", "29\n", "age\t29\nname\thwy\n(2 keys)\n", "(not found)\n", "age\t29\n(1 keys)\n"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("output should contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Count(got, "29\n") != 3 { // .exit之后的语句不执行
+		t.Fatalf("output:\n%s", got)
+	}
+	if !reflect.DeepEqual(lr.history, []string{"insert age 29;", "insert name 'hwy';", "find name; find age;", ".timing off", ".dump", "find sex;", "find prefix a order by key desc;", ".exit"}) {
+		t.Fatalf("history: %q", lr.history)
+	}
+}
+
+func TestRepl_transaction(t *testing.T) {
+	input := ".timing off\nbegin;\ninsert a 1;\nfind a;\nrollback;\nfind a;\nbegin; insert b 2;\n"
+	var out bytes.Buffer
+	r := newRepl(3, &out)
+	lr := &lineReader{in: bufio.NewReader(strings.NewReader(input)), out: &out, fd: -1}
+	if err := r.run(lr); err != nil {
+		t.Fatal(err)
+	}
+	r.close()
+	want := "OK\nOK, 1 key(s) affected\n1\nOK\n(not found)\nOK\nOK, 1 key(s) affected\ntransaction rolled back\n"
+	if got := out.String(); got != want {
+		t.Fatalf("got\n%q\nwant\n%q", got, want)
+	}
+	if _, ok := r.tree.Get("b"); ok {
+		t.Fatal("uncommitted insert should be rolled back")
+	}
+}
+
+// 引号、注释中的;不结束语句
+func TestRepl_multiline(t *testing.T) {
+	input := ".timing off\ninsert a 'x;\ny';\nfind a -- note;\n;\ninsert b /* ; */ 2;\nfind b;\n"
+	var out bytes.Buffer
+	r := newRepl(3, &out)
+	lr := &lineReader{in: bufio.NewReader(strings.NewReader(input)), out: &out, fd: -1}
+	if err := r.run(lr); err != nil {
+		t.Fatal(err)
+	}
+	want := "OK, 1 key(s) affected\nx;\ny\nOK, 1 key(s) affected\n2\n"
+	if got := out.String(); got != want {
+		t.Fatalf("got\n%q\nwant\n%q", got, want)
+	}
+	if !reflect.DeepEqual(lr.history, []string{".timing off", "insert a 'x; y';", "find a -- note; ;", "insert b /* ; */ 2;", "find b;"}) {
+		t.Fatalf("history: %q", lr.history)
+	}
+}
+
+func TestRepl_execFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hwydb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "seed.hql")
+	seed := "-- 初始数据\ninsert a 1; insert b 'x;y';\n/* 重复 */ insert a 2;\nfind b; incr a 2;\ncreate table t (id int primary key, v string);\ninsert into t values (1, 'a'), (2, 'b');\ncreate index idx_v on t (v);"
+	if err := ioutil.WriteFile(path, []byte(seed), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	r := newRepl(3, &out)
+	r.timing = false
+	if err := r.execFile(path); err != nil {
+		t.Fatal(err)
+	}
+	want := "OK, 1 key(s) affected\nOK, 1 key(s) affected\nerror: insert a: key is exist\nx;y\n3\nOK\nOK, 2 row(s) affected\nOK\n"
+	if got := out.String(); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+	out.Reset()
+	r.command(".tables")
+	if got := out.String(); got != "t (id int primary key, v string)\n  index idx_v on t (v)\n" {
+		t.Fatalf(".tables: %q", got)
+	}
+	out.Reset()
+	r.execAll("select id, v as value from t where id >= 1 order by id desc")
+	if got := out.String(); got != "id  value\n2   b\n1   a\n(2 rows)\n" {
+		t.Fatalf("select: %q", got)
+	}
+	out.Reset()
+	r.execAll("explain select id from t where v = 'a'")
+	if got := out.String(); !strings.HasPrefix(got, "syntax tree:\n  select\n") ||
+		!strings.HasSuffix(got, "plan:\n  project: id (rows=1)\n    index scan on t using idx_v (v = 'a') (rows=1)\n") {
+		t.Fatalf("explain: %q", got)
+	}
+	if err := r.execFile(filepath.Join(dir, "missing.hql")); err == nil {
+		t.Fatal("missing file should fail")
+	}
+}
+
+func TestLineReader_edit(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain", "find a\r", "find a"},
+		{"backspace", "find ab\x7f\r", "find a"},
+		{"left and insert", "fnd\x1b[D\x1b[Di\r", "find"},
+		{"home and end", "ind\x01f\x05 a\r", "find a"},
+		{"delete", "findx\x1b[D\x1b[3~\r", "find"},
+		{"history up", "\x1b[A\x1b[A\r", "first"},
+		{"history down", "new\x1b[A\x1b[B\r", "new"},
+		{"ctrl-u", "abc\x15find\r", "find"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			lr := &lineReader{in: bufio.NewReader(strings.NewReader(c.input)), out: ioutil.Discard}
+			lr.addHistory("first")
+			lr.addHistory("second")
+			got, err := lr.edit("> ")
+			if err != nil || got != c.want {
+				t.Fatalf("got %q, %v, want %q", got, err, c.want)
+			}
+		})
+	}
+	lr := &lineReader{in: bufio.NewReader(strings.NewReader("\x04")), out: ioutil.Discard}
+	if _, err := lr.edit("> "); err != io.EOF {
+		t.Fatalf("ctrl-d: %v", err)
+	}
+	lr = &lineReader{in: bufio.NewReader(strings.NewReader("abc\x03")), out: ioutil.Discard}
+	if _, err := lr.edit("> "); err != errInterrupt {
+		t.Fatalf("ctrl-c: %v", err)
+	}
+}
+
+func TestFormatFiles(t *testing.T) {
+	var out bytes.Buffer
+	if err := formatFiles(nil, strings.NewReader("INSERT a   1; -- 注释\nselect ID from T where id>1;"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "insert 'a' 1;\nselect ID from t where id > 1;\n" {
+		t.Fatalf("got %q", got)
+	}
+	if err := formatFiles(nil, strings.NewReader("find"), &out); err == nil {
+		t.Fatal("syntax error should fail")
+	}
+	if err := formatFiles([]string{filepath.Join(os.TempDir(), "missing.hql")}, nil, &out); err == nil {
+		t.Fatal("missing file should fail")
+	}
+}
@@ -2,7 +2,8 @@ package index
 
 import (
 	"errors"
-	"log"
+	"sync"
+	"time"
 )
 
 const (
@@ -11,52 +12,125 @@ const (
 	Merge             // merge 合并
 )
 
+var (
+	ErrKeyExist    = errors.New("key is exist")
+	ErrKeyNotExist = errors.New("this key is not exist")
+)
+
 type BT interface {
-	Insert(key interface{}, value interface{})
+	Insert(key interface{}, value interface{}) error
 	Find(key interface{}) (value interface{})
-	Delete(key interface{})
-	Update(key interface{}, value interface{})
+	Get(key interface{}) (value interface{}, ok bool)
+	Delete(key interface{}) error
+	Update(key interface{}, value interface{}) error
+	InsertWithTTL(key interface{}, value interface{}, ttl time.Duration) error
+	UpdateWithTTL(key interface{}, value interface{}, ttl time.Duration) error
+	Scan(r ScanRange, fn func(key, value interface{}) bool)
+	Modify(key interface{}, fn func(old interface{}, ok bool) (interface{}, error)) (interface{}, error)
+	Watch(sel Selector, opts ...WatchOption) <-chan Event
+	Unwatch(ch <-chan Event)
+	StartSweeper(interval time.Duration, batch int) (stop func())
+	Stats() Stats
 }
 
+// 创建阶数为m的B+树，m小于3时按3处理（阶数过小时合并会产生空节点）
 func New(m int) BT {
 	return newBtree(m)
 }
 
+// 树的阶数
+func (bt *Btree) Order() int {
+	return bt.m
+}
+
 type Btree struct {
+	mu sync.RWMutex
 	m int
 	root *BNode
 	sqt *BNode
+	now func() time.Time // 当前时间，用于判断关键字是否过期
+	wmu      sync.Mutex            // 保护watchers
+	watchers map[*watcher]struct{} // 订阅了变更事件的watcher
+	nmu      sync.Mutex            // 保证事件按写操作的顺序分发
+	events   []pendingEvent        // 本次写操作产生的、还没分发的事件
+	// 统计信息（自创建以来）
+	splits  int64 // 节点分裂次数
+	merges  int64 // 节点合并次数
+	borrows int64 // 兄弟节点之间转移关键字的次数
 }
 
-func (bt *Btree) Insert(key interface{}, value interface{}) {
+func (bt *Btree) Insert(key interface{}, value interface{}) error {
 	input := typeToKey(key)
-	bt.insert(input, value)
+	bt.mu.Lock()
+	defer bt.unlock()
+	return bt.insert(input, value, 0)
 }
 
 func (bt *Btree) Find(key interface{}) (value interface{}) {
+	value, _ = bt.Get(key)
+	return value
+}
+
+// 查找关键字，ok表示关键字是否存在（value可以是nil）
+func (bt *Btree) Get(key interface{}) (value interface{}, ok bool) {
 	input := typeToKey(key)
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
 	node := bt.findBySqt(input)
-	if node == nil {
-		return nil
+	if node == nil || node.expired(bt.now()) {
+		return nil, false
 	}
-	return node.value
+	return node.value, true
+}
+
+func (bt *Btree) Delete(key interface{}) error {
+	input := typeToKey(key)
+	bt.mu.Lock()
+	defer bt.unlock()
+	return bt.delete(input)
 }
 
-func (bt *Btree) Delete(key interface{}) {
+// 更新value，保留原来的过期时间
+func (bt *Btree) Update(key interface{}, value interface{}) error {
 	input := typeToKey(key)
-	bt.delete(input)
+	bt.mu.Lock()
+	defer bt.unlock()
+	return bt.update(input, value, -1)
 }
 
-func (bt *Btree) Update(key interface{}, value interface{}) {
+// 在一次查找中读取并修改value，读和写之间不会有其它修改
+// fn的参数是当前的value和关键字是否存在，返回新的value；fn返回错误时不做任何修改
+// 关键字不存在时插入（永不过期），存在时保留原来的过期时间
+func (bt *Btree) Modify(key interface{}, fn func(old interface{}, ok bool) (interface{}, error)) (interface{}, error) {
 	input := typeToKey(key)
-	bt.update(input, value)
+	bt.mu.Lock()
+	defer bt.unlock()
+	node := bt.findByRoot(input)
+	ok := node != nil && !node.expired(bt.now())
+	var old interface{}
+	if ok {
+		old = node.value
+	}
+	value, err := fn(old, ok)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return value, bt.insert(input, value, 0)
+	}
+	node.value = value
+	bt.emit(EventUpdate, input, old, value)
+	return value, nil
 }
 
 //TODO:
 // 1.支持不同类型比较
 // 2.错误处理
 func newBtree(m int) *Btree {
-	bt := &Btree{m: m}
+	if m < 3 { // 阶数过小时合并会产生空节点
+		m = 3
+	}
+	bt := &Btree{m: m, now: time.Now}
 	root := newBNode(true, m, nil, nil, 0)
 	bt.root = root
 	bt.sqt = root
@@ -65,7 +139,7 @@ func newBtree(m int) *Btree {
 // 从根节点开始随机查找，查找到叶子节点才会结束
 func (bt *Btree) findByRoot(key Key) (*SNode) {
 	bn, i := bt.root.findBNode(key)
-	if compare(bn.nodes[i].key, "=", key) {
+	if len(bn.nodes) > 0 && compare(bn.nodes[i].key, "=", key) {
 		return bn.nodes[i]
 	}
 	return nil
@@ -73,7 +147,7 @@ func (bt *Btree) findByRoot(key Key) (*SNode) {
 // 从最小关键字叶子节点开始顺序查找
 func (bt *Btree) findBySqt(key Key) (*SNode) {
 	bn, idx := bt.sqt.findLeafBNode(key)
-	if bn == nil { // 说明sqt不是叶子结点，需要更改
+	if bn == nil || idx < 0 { // 说明sqt不是叶子结点，需要更改(或者key不存在)
 		return nil
 	}
 	if !compare(bn.nodes[idx].key, "=", key) {
@@ -81,18 +155,29 @@ func (bt *Btree) findBySqt(key Key) (*SNode) {
 	}
 	return bn.nodes[idx]
 }
-// 插入关键字
-func (bt *Btree) insert(key Key, value interface{}) {
-	_, err := bt.insertRecursive(key, nil, bt.root, value)
+// 插入关键字，expire为过期时间（0表示永不过期）
+func (bt *Btree) insert(key Key, value interface{}, expire int64) error {
+	// 已经过期（还没被清理）的关键字可以直接覆盖
+	if node := bt.findByRoot(key); node != nil && node.expired(bt.now()) {
+		node.value = value
+		node.expire = expire
+		bt.emit(EventPut, key, nil, value)
+		return nil
+	}
+	sn := newSNode(key, nil, value)
+	sn.expire = expire
+	_, err := bt.insertRecursive(key, nil, bt.root, sn)
 	if err != nil {
-		log.Printf("insert failure %s", err)
+		return err
 	}
+	bt.emit(EventPut, key, nil, value)
+	return nil
 }
 // 递归插入关键字
-func (bt *Btree) insertRecursive(key Key, parent, cur *BNode, value interface{}) (int, error) {
+func (bt *Btree) insertRecursive(key Key, parent, cur *BNode, sn *SNode) (int, error) {
 	idx := cur.binaryFind(key)
 	if cur.isLeaf {
-		isUpdate, err := cur.insertElement(idx, newSNode(key, nil, value))
+		isUpdate, err := cur.insertElement(idx, sn)
 		if err != nil {
 			return Normal, err
 		}
@@ -105,7 +190,7 @@ func (bt *Btree) insertRecursive(key Key, parent, cur *BNode, value interface{})
 		}
 		return Normal, nil
 	}
-	state, err := bt.insertRecursive(key, cur, cur.nodes[idx].childPtr, value)
+	state, err := bt.insertRecursive(key, cur, cur.nodes[idx].childPtr, sn)
 	if err != nil {
 		return Normal, err
 	}
@@ -115,24 +200,34 @@ func (bt *Btree) insertRecursive(key Key, parent, cur *BNode, value interface{})
 	return Normal, nil
 }
 // 删除关键字
-func (bt *Btree) delete(key Key) {
+func (bt *Btree) delete(key Key) error {
+	var old interface{}
+	if node := bt.findByRoot(key); node != nil {
+		old = node.value
+	}
 	_, err := bt.deleteRecursive(key, nil, bt.root)
 	if err != nil {
-		log.Printf("delete failure %s", err)
+		return err
+	}
+	bt.emit(EventDelete, key, old, nil)
+	// root只剩一个孩子时降低树高
+	for !bt.root.isLeaf && len(bt.root.nodes) == 1 {
+		bt.root = bt.root.nodes[0].childPtr
 	}
+	return nil
 }
 // 递归删除关键字
 func (bt *Btree) deleteRecursive(key Key, parent, cur *BNode) (int, error) {
 	idx := cur.binaryFind(key)
 	if cur.isLeaf {
-		if !compare(cur.nodes[idx].key,"=", key) {
-			return -1, errors.New("this key is not exist")
+		if len(cur.nodes) == 0 || !compare(cur.nodes[idx].key,"=", key) {
+			return -1, ErrKeyNotExist
 		}
 		isUpdate, err := cur.deleteElement(idx)
 		if err != nil {
 			return Normal, err
 		}
-		if isUpdate {
+		if isUpdate && len(cur.nodes) > 0 {
 			// 更新索引节点，把久的索引（本次删除的）换成新的（删除后剩下最大关键字）
 			bt.updateIndex(key, cur.nodes[len(cur.nodes)-1].key, cur.degree)
 		}
@@ -151,14 +246,20 @@ func (bt *Btree) deleteRecursive(key Key, parent, cur *BNode) (int, error) {
 	}
 	return Normal, nil
 }
-// 更新操作
-func (bt *Btree) update(key Key, value interface{}) {
+// 更新操作，expire小于0时保留原来的过期时间
+func (bt *Btree) update(key Key, value interface{}, expire int64) error {
 	// 找到叶子节点的关键字，更新值
-	if bt.findBySqt(key) == nil {
-		panic("the key is not exist")
-	}
 	node := bt.findBySqt(key)
+	if node == nil || node.expired(bt.now()) {
+		return ErrKeyNotExist
+	}
+	old := node.value
 	node.value = value
+	if expire >= 0 {
+		node.expire = expire
+	}
+	bt.emit(EventUpdate, key, old, value)
+	return nil
 }
 // 在插入操作时，如果插入的新关键字最为最大（最小）关键字，则需要从root节点开始进行更新索引(指定深度degree)
 // 仅修改 key，不改变指针
@@ -178,6 +279,7 @@ type BNode struct {
 	m int // 阶数
 	nodes []*SNode
 	next *BNode // 叶子节点指向临近节点的指针
+	prev *BNode // 叶子节点指向前一个节点的指针（用于逆序遍历）
 	degree int // 节点所处的树的高度，叶子节点为0，root最高
 }
 
@@ -222,7 +324,7 @@ func (bn *BNode) findLeafBNode(key Key) (*BNode, int) {
 	}
 	for bn != nil {
 		idx := bn.binaryFind(key)
-		if compare(bn.nodes[idx].key, ">=", key) {
+		if len(bn.nodes) > 0 && compare(bn.nodes[idx].key, ">=", key) {
 			return bn, idx;
 		}
 		bn = bn.next
@@ -235,12 +337,12 @@ func (bn *BNode) findLeafBNode(key Key) (*BNode, int) {
 // 插入元素
 // return 是否需要更新索引节点
 func (bn *BNode) insertElement(idx int, newSn *SNode) (bool, error) {
-	if bn.nodes == nil {
-		bn.nodes = []*SNode{newSNode(newSn.key, newSn.childPtr, newSn.value)}
+	if len(bn.nodes) == 0 {
+		bn.nodes = []*SNode{newSn}
 		return false, nil
 	}
 	if compare(newSn.key, "=", bn.nodes[idx].key) {
-		return false, errors.New("key is exist")
+		return false, ErrKeyExist
 	}
 	if compare(newSn.key, ">", bn.nodes[idx].key){
 		bn.nodes = insertNodes(bn.nodes, idx+1, newSn)
@@ -337,8 +439,9 @@ func (bn *BNode) splitBNode(bt *Btree, parent *BNode) (int) {
 	// 2. 没有就分裂
 	ok, brother, brohterIdx := bn.hasFreePos(parent)
 	if ok {
+		bt.borrows++
 		if brohterIdx == 0 { // 右兄弟，给该节点最大的关键字，本节点删除该关键字，更新索引
-			insertNodes(brother.nodes, brohterIdx, bn.nodes[len(bn.nodes) - 1])
+			brother.nodes = insertNodes(brother.nodes, brohterIdx, bn.nodes[len(bn.nodes) - 1])
 			bn.deleteElement(len(bn.nodes) - 1)
 			bt.updateIndex(brother.nodes[0].key, bn.nodes[len(bn.nodes) - 1].key, bn.degree)
 		} else { // 左兄弟，给该节点最小的关键字，本节点删除该关键字，更新索引
@@ -348,14 +451,18 @@ func (bn *BNode) splitBNode(bt *Btree, parent *BNode) (int) {
 		}
 		return Normal
 	}
+	bt.splits++
 	m := bn.m + 1
-	leftNodes := make([]*SNode, 0, m>>1)
-	rightNodes := make([]*SNode, 0, (m + 1)>>1)
-	leftNodes = bn.nodes[:m>>1]
-	rightNodes = bn.nodes[m>>1:]
+	// 左右两部分需要各自的底层数组，否则之后向左节点插入会覆盖右节点
+	leftNodes := append(make([]*SNode, 0, m), bn.nodes[:m>>1]...)
+	rightNodes := append(make([]*SNode, 0, m), bn.nodes[m>>1:]...)
 	newBn := newBNode(bn.isLeaf, bn.m, rightNodes, bn.next, bn.degree)
 	bn.nodes = leftNodes
 	if bn.isLeaf {
+		if bn.next != nil {
+			bn.next.prev = newBn
+		}
+		newBn.prev = bn
 		bn.next = newBn
 	}
 	var newSnL *SNode
@@ -365,7 +472,7 @@ func (bn *BNode) splitBNode(bt *Btree, parent *BNode) (int) {
 		newSnL = newSNode(bn.nodes[len(bn.nodes)-1].key, bn, nil)
 	}
 	if parent == nil { // 生成新的root节点
-		newSnR := newSNode(newBn.nodes[len(bn.nodes)-1].key, newBn, nil)
+		newSnR := newSNode(newBn.nodes[len(newBn.nodes)-1].key, newBn, nil)
 		parent = newBNode(false, bn.m, []*SNode{newSnL, newSnR}, nil, bn.degree+1)
 		bt.root = parent // 更新
 	} else {
@@ -389,6 +496,7 @@ func (bn *BNode) mergeBNode(bt *Btree, parent *BNode) (int) {
 	// 2.如果没有才进行合并
 	ok, brother, brotherIdx := bn.hasFreeKey(parent)
 	if ok {
+		bt.borrows++
 		tmp := brother.nodes[brotherIdx]
 		brother.deleteElement(brotherIdx) // 删除
 		if brotherIdx == 0 { // 右兄弟
@@ -396,7 +504,7 @@ func (bn *BNode) mergeBNode(bt *Btree, parent *BNode) (int) {
 			bt.updateIndex(bn.nodes[len(bn.nodes)-2].key, tmp.key, bn.degree)
 		} else { // 左兄弟
 			bn.nodes = insertNodes(bn.nodes, 0, tmp)
-			bt.updateIndex(tmp.key, brother.nodes[len(bn.nodes)-1].key, brother.degree)
+			bt.updateIndex(tmp.key, brother.nodes[len(brother.nodes)-1].key, brother.degree)
 		}
 		return Normal
 	}
@@ -409,10 +517,17 @@ func (bn *BNode) mergeBNode(bt *Btree, parent *BNode) (int) {
 		left = bn
 		right = brother
 	}
+	bt.merges++
 	oldIdx := len(left.nodes) - 1
-	parent.deleteElement(parent.binaryFind(left.nodes[oldIdx].key)) // 删除left节点的最大关键字索引
+	lidx := parent.binaryFind(left.nodes[oldIdx].key)
+	// right的关键字并入left，父节点中right的索引改为指向left，再删除left原来的索引
 	left.nodes = append(left.nodes, right.nodes...)
-	right.nodes = left.nodes
+	left.next = right.next
+	if right.next != nil {
+		right.next.prev = left
+	}
+	parent.nodes[lidx+1].childPtr = left
+	parent.deleteElement(lidx) // 删除left节点的最大关键字索引
 	return parent.checkBNode(parent == bt.root)
 }
 
@@ -426,6 +541,7 @@ type SNode struct {
 	key      Key
 	childPtr *BNode
 	value    interface{} // 叶子小节点指向value的指针(或者值)
+	expire   int64       // 过期时间(UnixNano)，0表示永不过期
 }
 
 func newSNode(key Key, childPtr *BNode, value interface{}) *SNode {
@@ -1,7 +1,11 @@
 package index
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"sync"
 	"testing"
 )
 
@@ -93,4 +97,165 @@ func walkBtree(root *BNode) {
 			nextN = 0
 		}
 	}
-}
\ No newline at end of file
+}
+// 随机插入、删除，检查查找结果与叶子链表的有序性
+func TestBtree_Random(t *testing.T) {
+	for _, m := range []int{3, 4, 5, 8} {
+		r := rand.New(rand.NewSource(int64(m)))
+		bt := newBtree(m)
+		ref := make(map[int64]bool)
+		for i := 0; i < 2000; i++ {
+			key := int64(r.Intn(300))
+			if ref[key] && r.Intn(2) == 0 {
+				bt.Delete(key)
+				delete(ref, key)
+			} else if !ref[key] {
+				bt.Insert(key, key)
+				ref[key] = true
+			}
+		}
+		for key := range ref {
+			if bt.Find(key) != key {
+				t.Fatalf("m=%d, key:%v is lost", m, key)
+			}
+		}
+		count := 0
+		var last Key
+		for bn := bt.sqt; bn != nil; bn = bn.next {
+			for _, sn := range bn.nodes {
+				if last != nil && !last.Less(sn.key) {
+					t.Fatalf("m=%d, leaf chain is not ordered: %v, %v", m, last, sn.key)
+				}
+				last = sn.key
+				count++
+			}
+		}
+		if count != len(ref) {
+			t.Fatalf("m=%d, leaf chain has %d keys, want %d", m, count, len(ref))
+		}
+	}
+}
+
+func TestBtree_Errors(t *testing.T) {
+	bt := buildTree()
+	if err := bt.Insert(int64(6), int64(6)); err != ErrKeyExist {
+		t.Fatalf("insert exist key: %v", err)
+	}
+	if err := bt.Update(int64(4), int64(4)); err != ErrKeyNotExist {
+		t.Fatalf("update not exist key: %v", err)
+	}
+	if err := bt.Delete(int64(4)); err != ErrKeyNotExist {
+		t.Fatalf("delete not exist key: %v", err)
+	}
+	if err := bt.Delete(int64(6)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 不同类型的关键字：数字之间按数值比较，数字小于字符串
+func TestBtree_MixedKey(t *testing.T) {
+	bt := newBtree(3)
+	keys := []interface{}{"b", int64(3), 2.5, int32(-1), "a", 10, float32(0.5)}
+	for _, key := range keys {
+		if err := bt.Insert(key, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := make([]interface{}, 0)
+	bt.Ascend(func(key, value interface{}) bool {
+		got = append(got, key)
+		return true
+	})
+	want := []interface{}{int32(-1), float32(0.5), 2.5, int64(3), 10, "a", "b"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if bt.Find(3.0) != int64(3) || bt.Find(int8(10)) != 10 {
+		t.Fatal("numeric keys with different types should be equal")
+	}
+	if err := bt.Insert(int64(10), nil); err != ErrKeyExist {
+		t.Fatalf("insert equal numeric key: %v", err)
+	}
+}
+
+// 超过2^53的整数和浮点数比较时不能丢失精度
+func TestBtree_MixedKeyPrecision(t *testing.T) {
+	bt := newBtree(3)
+	if err := bt.Insert(float64(1<<53), "float"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bt.Insert(int64(1<<53)+1, "int"); err != nil {
+		t.Fatalf("different keys should not collide: %v", err)
+	}
+	if v, ok := bt.Get(int64(1<<53) + 1); !ok || v != "int" {
+		t.Fatalf("got %v, %v", v, ok)
+	}
+	if v, _ := bt.Get(int64(1 << 53)); v != "float" {
+		t.Fatalf("equal int and float keys: got %v", v)
+	}
+	cases := []struct {
+		i    int64
+		f    float64
+		want int
+	}{
+		{1<<53 + 1, 1 << 53, 1},
+		{1 << 53, 1 << 53, 0},
+		{math.MaxInt64, 1 << 63, -1},
+		{math.MinInt64, -1 << 63, 0},
+		{math.MinInt64, -1e19, 1},
+		{2, 2.5, -1},
+		{3, 2.5, 1},
+		{-2, -2.5, 1},
+		{-3, -2.5, -1},
+		{0, math.NaN(), 1},
+		{0, math.Inf(1), -1},
+		{0, math.Inf(-1), 1},
+	}
+	for _, c := range cases {
+		if got := cmpIntFloat(c.i, c.f); got != c.want {
+			t.Fatalf("cmpIntFloat(%d, %v) = %d, want %d", c.i, c.f, got, c.want)
+		}
+	}
+}
+
+func TestBtree_Modify(t *testing.T) {
+	bt := newBtree(3)
+	incr := func(old interface{}, ok bool) (interface{}, error) {
+		if !ok {
+			return 1, nil
+		}
+		return old.(int) + 1, nil
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				bt.Modify("counter", incr)
+				bt.Modify(i, incr)
+			}
+		}()
+	}
+	wg.Wait()
+	if v := bt.Find("counter"); v != 4000 {
+		t.Fatalf("counter: %v, want 4000", v)
+	}
+	if v := bt.Find(499); v != 8 {
+		t.Fatalf("499: %v, want 8", v)
+	}
+	errStop := errors.New("stop")
+	if _, err := bt.Modify("counter", func(old interface{}, ok bool) (interface{}, error) {
+		return nil, errStop
+	}); err != errStop || bt.Find("counter") != 4000 {
+		t.Fatalf("failed modify should not change the value: %v", err)
+	}
+	if _, err := bt.Modify("new", func(old interface{}, ok bool) (interface{}, error) {
+		return nil, errStop
+	}); err != errStop {
+		t.Fatal(err)
+	}
+	if _, ok := bt.Get("new"); ok {
+		t.Fatal("failed modify should not insert the key")
+	}
+}
@@ -0,0 +1,79 @@
+package index
+
+import (
+	"time"
+)
+
+// 叶子链表上的游标，跳过已经过期的关键字
+type cursor struct {
+	bn   *BNode
+	idx  int
+	now  time.Time
+	desc bool // 从大到小移动
+}
+
+// 从最小关键字开始的游标
+func (bt *Btree) first() *cursor {
+	c := &cursor{bn: bt.sqt, idx: 0, now: bt.now()}
+	c.skip()
+	return c
+}
+
+// 当前的叶子小节点，游标走完时返回nil
+func (c *cursor) node() *SNode {
+	if c.bn == nil {
+		return nil
+	}
+	return c.bn.nodes[c.idx]
+}
+
+// 移动到下一个关键字
+func (c *cursor) next() {
+	if c.bn == nil {
+		return
+	}
+	c.step()
+	c.skip()
+}
+
+func (c *cursor) step() {
+	if c.desc {
+		c.idx--
+	} else {
+		c.idx++
+	}
+}
+
+// 跳过走完的叶子节点以及过期的关键字
+func (c *cursor) skip() {
+	for c.bn != nil {
+		if c.idx >= len(c.bn.nodes) && !c.desc {
+			c.bn = c.bn.next
+			c.idx = 0
+			continue
+		}
+		if c.idx < 0 && c.desc {
+			if c.bn = c.bn.prev; c.bn != nil {
+				c.idx = len(c.bn.nodes) - 1
+			}
+			continue
+		}
+		if !c.bn.nodes[c.idx].expired(c.now) {
+			return
+		}
+		c.step()
+	}
+}
+
+// 按关键字从小到大遍历，fn返回false时停止
+// fn中不能修改这棵树
+func (bt *Btree) Ascend(fn func(key, value interface{}) bool) {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+	for c := bt.first(); c.node() != nil; c.next() {
+		sn := c.node()
+		if !fn(keyToType(sn.key), sn.value) {
+			return
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package index
+
+import (
+	"reflect"
+)
+
+// 以key为界把树拆分成两棵新树：left包含小于key的关键字，right包含大于等于key的关键字
+// 原来的树保持不变，过期时间会被保留
+func (bt *Btree) SplitAt(key interface{}) (left, right *Btree) {
+	pivot := typeToKey(key)
+	left = newBtree(bt.m)
+	right = newBtree(bt.m)
+	for _, sn := range bt.snapshot() {
+		if sn.key.Less(pivot) {
+			left.insertRecursive(sn.key, nil, left.root, sn)
+		} else {
+			right.insertRecursive(sn.key, nil, right.root, sn)
+		}
+	}
+	return left, right
+}
+
+// 把other中的关键字并入当前树
+// 两棵树都存在的关键字由resolve决定最终的value（resolve为nil时使用other中的value）
+// resolve在持有当前树的写锁时调用，其中不能访问当前树，否则会死锁
+func (bt *Btree) Merge(other *Btree, resolve func(key, old, new interface{}) interface{}) {
+	if other == bt {
+		return
+	}
+	nodes := other.snapshot() // 先复制出来，避免同时持有两棵树的锁
+	bt.mu.Lock()
+	defer bt.unlock()
+	now := bt.now()
+	for _, sn := range nodes {
+		node := bt.findByRoot(sn.key)
+		if node == nil || node.expired(now) {
+			bt.insert(sn.key, sn.value, sn.expire)
+			continue
+		}
+		value := sn.value
+		if resolve != nil {
+			value = resolve(keyToType(sn.key), node.value, sn.value)
+		}
+		old := node.value
+		node.value = value
+		bt.emit(EventUpdate, sn.key, old, value)
+	}
+}
+
+// 复制出所有没有过期的叶子小节点（按关键字从小到大）
+func (bt *Btree) snapshot() []*SNode {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+	nodes := make([]*SNode, 0)
+	for c := bt.first(); c.node() != nil; c.next() {
+		sn := c.node()
+		nodes = append(nodes, &SNode{key: sn.key, value: sn.value, expire: sn.expire})
+	}
+	return nodes
+}
+
+// 两棵树之间的差异类型
+type DiffType int
+
+const (
+	DiffAdded   DiffType = iota // 只在b中存在
+	DiffRemoved                 // 只在a中存在
+	DiffChanged                 // 两棵树都存在但value不同
+)
+
+func (t DiffType) String() string {
+	switch t {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffChanged:
+		return "changed"
+	}
+	return "unknown"
+}
+
+type Difference struct {
+	Type DiffType
+	Key  interface{}
+	Old  interface{} // a中的value
+	New  interface{} // b中的value
+}
+
+// 按关键字从小到大同时遍历a和b的叶子链表，把a到b的变化依次交给fn，fn返回false时停止
+// 先复制出两棵树的关键字，比较时不持有任何锁，fn中可以修改a或b（不影响这次的结果）
+func Diff(a, b *Btree, fn func(d Difference) bool) {
+	na, nb := a.snapshot(), b.snapshot()
+	i, j := 0, 0
+	for i < len(na) || j < len(nb) {
+		var d Difference
+		switch {
+		case j == len(nb) || (i < len(na) && na[i].key.Less(nb[j].key)):
+			d = Difference{Type: DiffRemoved, Key: keyToType(na[i].key), Old: na[i].value}
+			i++
+		case i == len(na) || nb[j].key.Less(na[i].key):
+			d = Difference{Type: DiffAdded, Key: keyToType(nb[j].key), New: nb[j].value}
+			j++
+		default:
+			i, j = i+1, j+1
+			if reflect.DeepEqual(na[i-1].value, nb[j-1].value) {
+				continue
+			}
+			d = Difference{Type: DiffChanged, Key: keyToType(na[i-1].key), Old: na[i-1].value, New: nb[j-1].value}
+		}
+		if !fn(d) {
+			return
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package index
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestBtree_SplitAt(t *testing.T) {
+	bt := buildTree() // 1,2,3,5,6,8,9,11,13,15
+	left, right := bt.SplitAt(int64(8))
+	if left.Stats().Keys != 5 || right.Stats().Keys != 5 || bt.Stats().Keys != 10 {
+		t.Fatalf("left: %d, right: %d", left.Stats().Keys, right.Stats().Keys)
+	}
+	if left.Find(int64(6)) != int64(6) || left.Find(int64(8)) != nil {
+		t.Fatal("left tree error")
+	}
+	if right.Find(int64(8)) != int64(8) || right.Find(int64(6)) != nil {
+		t.Fatal("right tree error")
+	}
+	// 拆分之后的树可以继续修改
+	left.Insert(int64(7), int64(7))
+	if left.Find(int64(7)) != int64(7) || bt.Find(int64(7)) != nil {
+		t.Fatal("insert into left tree error")
+	}
+}
+
+func TestBtree_Merge(t *testing.T) {
+	a := newBtree(3)
+	b := newBtree(4)
+	for i := int64(0); i < 30; i += 2 {
+		a.Insert(i, "a")
+	}
+	for i := int64(0); i < 30; i += 3 {
+		b.Insert(i, "b")
+	}
+	conflicts := 0
+	a.Merge(b, func(key, old, new interface{}) interface{} {
+		conflicts++
+		return fmt.Sprint(old, new)
+	})
+	if conflicts != 5 {
+		t.Fatalf("conflicts: %d, want 5", conflicts)
+	}
+	if a.Find(int64(6)) != "ab" || a.Find(int64(3)) != "b" || a.Find(int64(4)) != "a" {
+		t.Fatal("merge error")
+	}
+	if a.Stats().Keys != 20 {
+		t.Fatalf("keys: %d, want 20", a.Stats().Keys)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := buildTree() // 1,2,3,5,6,8,9,11,13,15
+	b, _ := a.SplitAt(int64(100))
+	b.Delete(int64(1))
+	b.Delete(int64(9))
+	b.Update(int64(5), "five")
+	b.Insert(int64(4), int64(4))
+	b.Insert(int64(20), int64(20))
+	want := []Difference{
+		{Type: DiffRemoved, Key: int64(1), Old: int64(1)},
+		{Type: DiffAdded, Key: int64(4), New: int64(4)},
+		{Type: DiffChanged, Key: int64(5), Old: int64(5), New: "five"},
+		{Type: DiffRemoved, Key: int64(9), Old: int64(9)},
+		{Type: DiffAdded, Key: int64(20), New: int64(20)},
+	}
+	got := make([]Difference, 0)
+	Diff(a, b, func(d Difference) bool {
+		got = append(got, d)
+		return true
+	})
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	Diff(a, a, func(d Difference) bool {
+		t.Fatalf("same tree has difference: %v", d)
+		return false
+	})
+}
+
+// fn中可以修改两棵树；相反顺序的Diff和写操作同时进行时不会死锁
+func TestDiff_NoLocks(t *testing.T) {
+	a := buildTree()
+	b, _ := a.SplitAt(int64(100))
+	b.Insert(int64(4), int64(4))
+	n := 0
+	Diff(a, b, func(d Difference) bool {
+		a.Insert(d.Key, d.New)
+		b.Delete(d.Key)
+		n++
+		return true
+	})
+	if n != 1 {
+		t.Fatalf("got %d differences", n)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if i%2 == 0 {
+					Diff(a, b, func(Difference) bool { return true })
+				} else {
+					Diff(b, a, func(Difference) bool { return true })
+				}
+				a.Insert(int64(1000+i*1000+j), j)
+				b.Insert(int64(1000+i*1000+j), j)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
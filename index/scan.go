@@ -0,0 +1,105 @@
+package index
+
+// 范围查询的条件，Lo、Hi为nil表示这一端不限
+type ScanRange struct {
+	Lo, Hi         interface{}
+	LoOpen, HiOpen bool // 是否不包括Lo、Hi本身
+	Desc           bool // 从大到小
+}
+
+// 以prefix开头的所有字符串关键字
+func PrefixRange(prefix string) ScanRange {
+	r := ScanRange{Lo: prefix}
+	// 上界是prefix之后第一个不以它开头的字符串：去掉末尾的0xff，再把最后一个字节加一
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			r.Hi, r.HiOpen = prefix[:i]+string([]byte{prefix[i] + 1}), true
+			break
+		}
+	}
+	return r
+}
+
+// 沿着叶子链表遍历r中的关键字（已经过期的除外），fn返回false时停止
+// 只有一端时，另一端限制在和它同一类（数字或者字符串）的关键字中：find < 'b'不包括数字
+// fn中不能修改这棵树
+func (bt *Btree) Scan(r ScanRange, fn func(key, value interface{}) bool) {
+	var lo, hi Key
+	if r.Lo != nil {
+		lo = typeToKey(r.Lo)
+	}
+	if r.Hi != nil {
+		hi = typeToKey(r.Hi)
+	}
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+	// 从起点所在的叶子开始，越过终点时停止
+	start, end, startOpen, endOpen := lo, hi, r.LoOpen, r.HiOpen
+	startOp, endOp := "<", ">"
+	if r.Desc {
+		start, end, startOpen, endOpen = hi, lo, r.HiOpen, r.LoOpen
+		startOp, endOp = ">", "<"
+	}
+	c := bt.seek(start, r.Desc)
+	for ; c.node() != nil && start != nil; c.next() {
+		k := c.node().key
+		if !compare(k, startOp, start) && !(startOpen && compare(k, "=", start)) {
+			break
+		}
+	}
+	// 只有一端时的那个端点，遇到其它类的关键字时跳过（还没有到这一类）或者停止（已经越过这一类）
+	var bound Key
+	if (lo == nil) != (hi == nil) {
+		bound = lo
+		if hi != nil {
+			bound = hi
+		}
+	}
+	seen := false
+	for ; c.node() != nil; c.next() {
+		sn := c.node()
+		if end != nil && (compare(sn.key, endOp, end) || (endOpen && compare(sn.key, "=", end))) {
+			return
+		}
+		if bound != nil && !sameClass(sn.key, bound) {
+			if seen {
+				return
+			}
+			continue
+		}
+		seen = true
+		if !fn(keyToType(sn.key), sn.value) {
+			return
+		}
+	}
+}
+
+// 定位到key所在叶子中第一个>=key的位置（没有时是叶子的最后一个关键字），调用者需要再跳过范围外的关键字
+// key为nil时从最小（逆序时最大）的关键字开始
+func (bt *Btree) seek(key Key, desc bool) *cursor {
+	c := &cursor{now: bt.now(), desc: desc}
+	switch {
+	case key != nil:
+		c.bn, c.idx = bt.root.findBNode(key)
+	case desc:
+		c.bn = bt.root
+		for !c.bn.isLeaf {
+			c.bn = c.bn.nodes[len(c.bn.nodes)-1].childPtr
+		}
+		c.idx = len(c.bn.nodes) - 1
+	default:
+		c.bn = bt.sqt
+	}
+	if desc && c.idx >= len(c.bn.nodes) { // 空的叶子节点
+		c.idx = len(c.bn.nodes) - 1
+	}
+	c.skip()
+	return c
+}
+
+// 两个关键字是否都是数字或者都是字符串
+func sameClass(a, b Key) bool {
+	_, _, _, aNum := numeric(a)
+	_, _, _, bNum := numeric(b)
+	return aNum == bNum
+}
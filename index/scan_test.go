@@ -0,0 +1,146 @@
+package index
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func scanKeys(bt *Btree, r ScanRange, limit int) []interface{} {
+	keys := make([]interface{}, 0)
+	bt.Scan(r, func(key, value interface{}) bool {
+		keys = append(keys, key)
+		return limit <= 0 || len(keys) < limit
+	})
+	return keys
+}
+
+func TestBtree_Scan(t *testing.T) {
+	for _, m := range []int{3, 4, 7} {
+		r := rand.New(rand.NewSource(int64(m)))
+		bt := newBtree(m)
+		ref := make(map[int64]bool)
+		for i := 0; i < 3000; i++ {
+			key := int64(r.Intn(200))
+			if ref[key] {
+				bt.Delete(key) // 删除会合并叶子节点，检查prev指针
+				delete(ref, key)
+			} else {
+				bt.Insert(key, key)
+				ref[key] = true
+			}
+		}
+		sorted := make([]int64, 0, len(ref))
+		for key := range ref {
+			sorted = append(sorted, key)
+		}
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		for i := 0; i < 200; i++ {
+			sr := ScanRange{LoOpen: r.Intn(2) == 0, HiOpen: r.Intn(2) == 0, Desc: r.Intn(2) == 0}
+			lo, hi := int64(r.Intn(220)-10), int64(r.Intn(220)-10)
+			if r.Intn(5) > 0 {
+				sr.Lo = lo
+			}
+			if r.Intn(5) > 0 {
+				sr.Hi = hi
+			}
+			want := make([]interface{}, 0)
+			for _, k := range sorted {
+				if (sr.Lo != nil && (k < lo || (sr.LoOpen && k == lo))) || (sr.Hi != nil && (k > hi || (sr.HiOpen && k == hi))) {
+					continue
+				}
+				want = append(want, k)
+			}
+			if sr.Desc {
+				for i, j := 0, len(want)-1; i < j; i, j = i+1, j-1 {
+					want[i], want[j] = want[j], want[i]
+				}
+			}
+			if got := scanKeys(bt, sr, 0); !reflect.DeepEqual(got, want) {
+				t.Fatalf("m=%d, %+v: got %v, want %v", m, sr, got, want)
+			}
+			if len(want) > 3 {
+				if got := scanKeys(bt, sr, 3); !reflect.DeepEqual(got, want[:3]) {
+					t.Fatalf("m=%d, %+v limit 3: got %v, want %v", m, sr, got, want[:3])
+				}
+			}
+		}
+	}
+}
+
+// 只有一端的范围不包括另一类的关键字：数字都排在字符串之前
+func TestBtree_ScanMixed(t *testing.T) {
+	bt := newBtree(3)
+	for _, k := range []interface{}{int64(5), 17.5, int64(30), "a", "b", "c", int64(-1)} {
+		bt.Insert(k, nil)
+	}
+	cases := []struct {
+		r    ScanRange
+		want []interface{}
+	}{
+		{ScanRange{Hi: "b", HiOpen: true}, []interface{}{"a"}},
+		{ScanRange{Hi: "b", Desc: true}, []interface{}{"b", "a"}},
+		{ScanRange{Lo: "b"}, []interface{}{"b", "c"}},
+		{ScanRange{Lo: "b", Desc: true}, []interface{}{"c", "b"}},
+		{ScanRange{Lo: int64(17), LoOpen: true}, []interface{}{17.5, int64(30)}},
+		{ScanRange{Lo: int64(17), Desc: true}, []interface{}{int64(30), 17.5}},
+		{ScanRange{Hi: 17.5}, []interface{}{int64(-1), int64(5), 17.5}},
+		{ScanRange{Hi: int64(5), Desc: true}, []interface{}{int64(5), int64(-1)}},
+		{ScanRange{Lo: int64(10), Hi: "b"}, []interface{}{17.5, int64(30), "a", "b"}},
+		{ScanRange{}, []interface{}{int64(-1), int64(5), 17.5, int64(30), "a", "b", "c"}},
+	}
+	for _, c := range cases {
+		if got := scanKeys(bt, c.r, 0); !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%+v: got %v, want %v", c.r, got, c.want)
+		}
+	}
+}
+
+func TestBtree_ScanPrefix(t *testing.T) {
+	bt := newBtree(3)
+	for _, key := range []string{"order:1", "user", "user:1", "user:2", "user:10", "user;", "users", "\xff\xff", "\xff\xffa"} {
+		bt.Insert(key, nil)
+	}
+	bt.Insert(1, nil)
+	cases := []struct {
+		prefix string
+		desc   bool
+		want   []interface{}
+	}{
+		{"user:", false, []interface{}{"user:1", "user:10", "user:2"}},
+		{"user:", true, []interface{}{"user:2", "user:10", "user:1"}},
+		{"user", false, []interface{}{"user", "user:1", "user:10", "user:2", "user;", "users"}},
+		{"\xff", false, []interface{}{"\xff\xff", "\xff\xffa"}},
+		{"none", false, []interface{}{}},
+	}
+	for _, c := range cases {
+		sr := PrefixRange(c.prefix)
+		sr.Desc = c.desc
+		if got := scanKeys(bt, sr, 0); !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("prefix %q: got %q, want %q", c.prefix, got, c.want)
+		}
+	}
+}
+
+func TestBtree_ScanExpired(t *testing.T) {
+	bt := newBtree(3)
+	now := time.Now()
+	bt.now = func() time.Time { return now }
+	for i := 0; i < 10; i++ {
+		if i%3 == 0 {
+			bt.InsertWithTTL(i, i, time.Second)
+		} else {
+			bt.Insert(i, i)
+		}
+	}
+	now = now.Add(2 * time.Second)
+	want := []interface{}{8, 7, 5, 4, 2, 1}
+	if got := scanKeys(bt, ScanRange{Desc: true}, 0); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got := scanKeys(newBtree(3), ScanRange{Desc: true}, 0); len(got) != 0 {
+		t.Fatalf("empty tree: %v", got)
+	}
+}
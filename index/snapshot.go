@@ -0,0 +1,356 @@
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// 快照格式（小端序）：
+//	magic "HWYB" | version uint16 | m uint32 | key类型 uint8(不同类型混合时为kindMixed) | 关键字个数 uint64
+//	每个关键字：key类型 uint8 | key | expire int64 | value类型 uint8 | value
+//	crc32(IEEE，覆盖前面所有字节) uint32
+// 字符串和[]byte都以 uint32长度 + 内容 编码
+
+const (
+	snapshotMagic   = "HWYB"
+	snapshotVersion = 1
+	// 快照中允许的最大阶数：节点按阶数分配空间，损坏的m不能直接用来建树
+	maxSnapshotOrder = 1 << 16
+)
+
+var (
+	ErrBadSnapshot      = errors.New("snapshot: bad magic")
+	ErrSnapshotVersion  = errors.New("snapshot: unsupported version")
+	ErrSnapshotChecksum = errors.New("snapshot: checksum mismatch")
+)
+
+// 关键字、value的类型编号（写入快照之后不能再修改）
+const (
+	kindNil uint8 = iota
+	kindBool
+	kindInt
+	kindInt8
+	kindInt16
+	kindInt32
+	kindInt64
+	kindFloat32
+	kindFloat64
+	kindString
+	kindBytes
+	kindMixed = 0xff // 树中有不同类型的关键字
+)
+
+// 关键字的类型编号，和typeToKey支持的类型一一对应
+func keyKind(key Key) uint8 {
+	switch key.(type) {
+	case myint:
+		return kindInt
+	case myint8:
+		return kindInt8
+	case myint16:
+		return kindInt16
+	case myint32:
+		return kindInt32
+	case myint64:
+		return kindInt64
+	case myfloat32:
+		return kindFloat32
+	case myfloat64:
+		return kindFloat64
+	case mystr:
+		return kindString
+	}
+	panic("this key is not support!")
+}
+
+// 把整棵树（不包括已经过期的关键字）写入w，返回写入的字节数
+func (bt *Btree) WriteTo(w io.Writer) (int64, error) {
+	nodes := bt.snapshot()
+	kind := kindNil
+	for i, sn := range nodes {
+		if i == 0 {
+			kind = keyKind(sn.key)
+		} else if keyKind(sn.key) != kind {
+			kind = kindMixed
+			break
+		}
+	}
+	e := newEncoder(w)
+	e.write([]byte(snapshotMagic))
+	e.uint16(snapshotVersion)
+	e.uint32(uint32(bt.m))
+	e.uint8(kind)
+	e.uint64(uint64(len(nodes)))
+	for _, sn := range nodes {
+		e.value(keyToType(sn.key))
+		e.uint64(uint64(sn.expire))
+		e.value(sn.value)
+	}
+	e.crcDone()
+	return e.n, e.err
+}
+
+// 从r中读取WriteTo写入的快照，重新构建一棵树
+func ReadFrom(r io.Reader) (*Btree, error) {
+	d := newDecoder(r)
+	magic := make([]byte, len(snapshotMagic))
+	d.read(magic)
+	if d.err == nil && string(magic) != snapshotMagic {
+		return nil, ErrBadSnapshot
+	}
+	version := d.uint16()
+	if d.err == nil && version != snapshotVersion {
+		return nil, ErrSnapshotVersion
+	}
+	m := d.uint32()
+	kind := d.uint8()
+	count := d.uint64()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if m < 3 || m > maxSnapshotOrder {
+		return nil, fmt.Errorf("snapshot: order %d out of range [3, %d]", m, maxSnapshotOrder)
+	}
+	if count > 0 && (kind < kindInt || kind > kindString) && kind != kindMixed {
+		return nil, fmt.Errorf("snapshot: key type %d is not supported", kind)
+	}
+	bt := newBtree(int(m))
+	var last Key
+	for i := uint64(0); i < count; i++ {
+		k, vkind := d.value()
+		if d.err != nil {
+			return nil, d.err
+		}
+		if (kind != kindMixed && vkind != kind) || vkind < kindInt || vkind > kindString {
+			return nil, fmt.Errorf("snapshot: key type %d, want %d", vkind, kind)
+		}
+		key := typeToKey(k)
+		if last != nil && !last.Less(key) {
+			return nil, errors.New("snapshot: keys are not in order")
+		}
+		last = key
+		sn := newSNode(key, nil, nil)
+		sn.expire = int64(d.uint64())
+		sn.value, _ = d.value()
+		if d.err != nil {
+			return nil, d.err
+		}
+		bt.insertRecursive(key, nil, bt.root, sn)
+	}
+	sum := d.crc.Sum32()
+	if want := d.uint32(); d.err != nil {
+		return nil, d.err
+	} else if sum != want {
+		return nil, ErrSnapshotChecksum
+	}
+	return bt, nil
+}
+
+// 写入时同时计算crc，出错之后的写操作都会被忽略
+type encoder struct {
+	w   io.Writer
+	crc hash.Hash32
+	n   int64
+	err error
+	buf [8]byte
+}
+
+func newEncoder(w io.Writer) *encoder {
+	return &encoder{w: w, crc: crc32.NewIEEE()}
+}
+
+func (e *encoder) write(p []byte) {
+	if e.err != nil {
+		return
+	}
+	e.crc.Write(p)
+	n, err := e.w.Write(p)
+	e.n += int64(n)
+	e.err = err
+}
+
+func (e *encoder) uint8(v uint8) {
+	e.buf[0] = v
+	e.write(e.buf[:1])
+}
+
+func (e *encoder) uint16(v uint16) {
+	binary.LittleEndian.PutUint16(e.buf[:], v)
+	e.write(e.buf[:2])
+}
+
+func (e *encoder) uint32(v uint32) {
+	binary.LittleEndian.PutUint32(e.buf[:], v)
+	e.write(e.buf[:4])
+}
+
+func (e *encoder) uint64(v uint64) {
+	binary.LittleEndian.PutUint64(e.buf[:], v)
+	e.write(e.buf[:8])
+}
+
+func (e *encoder) bytes(p []byte) {
+	e.uint32(uint32(len(p)))
+	e.write(p)
+}
+
+// 写入 类型编号 + 值
+func (e *encoder) value(v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		e.uint8(kindNil)
+	case bool:
+		e.uint8(kindBool)
+		if v {
+			e.uint8(1)
+		} else {
+			e.uint8(0)
+		}
+	case int:
+		e.uint8(kindInt)
+		e.uint64(uint64(v))
+	case int8:
+		e.uint8(kindInt8)
+		e.uint8(uint8(v))
+	case int16:
+		e.uint8(kindInt16)
+		e.uint16(uint16(v))
+	case int32:
+		e.uint8(kindInt32)
+		e.uint32(uint32(v))
+	case int64:
+		e.uint8(kindInt64)
+		e.uint64(uint64(v))
+	case float32:
+		e.uint8(kindFloat32)
+		e.uint32(math.Float32bits(v))
+	case float64:
+		e.uint8(kindFloat64)
+		e.uint64(math.Float64bits(v))
+	case string:
+		e.uint8(kindString)
+		e.bytes([]byte(v))
+	case []byte:
+		e.uint8(kindBytes)
+		e.bytes(v)
+	default:
+		if e.err == nil {
+			e.err = fmt.Errorf("snapshot: value type %T is not supported", v)
+		}
+	}
+}
+
+// 写入前面所有字节的crc
+func (e *encoder) crcDone() {
+	binary.LittleEndian.PutUint32(e.buf[:], e.crc.Sum32())
+	if e.err != nil {
+		return
+	}
+	n, err := e.w.Write(e.buf[:4])
+	e.n += int64(n)
+	e.err = err
+}
+
+// 读取时同时计算crc，出错之后的读操作都返回零值
+type decoder struct {
+	r   io.Reader
+	crc hash.Hash32
+	err error
+	buf [8]byte
+}
+
+func newDecoder(r io.Reader) *decoder {
+	return &decoder{r: r, crc: crc32.NewIEEE()}
+}
+
+func (d *decoder) read(p []byte) {
+	if d.err != nil {
+		for i := range p {
+			p[i] = 0
+		}
+		return
+	}
+	_, d.err = io.ReadFull(d.r, p)
+	if d.err == io.EOF {
+		d.err = io.ErrUnexpectedEOF
+	}
+	d.crc.Write(p)
+}
+
+func (d *decoder) uint8() uint8 {
+	d.read(d.buf[:1])
+	return d.buf[0]
+}
+
+func (d *decoder) uint16() uint16 {
+	d.read(d.buf[:2])
+	return binary.LittleEndian.Uint16(d.buf[:])
+}
+
+func (d *decoder) uint32() uint32 {
+	d.read(d.buf[:4])
+	return binary.LittleEndian.Uint32(d.buf[:])
+}
+
+func (d *decoder) uint64() uint64 {
+	d.read(d.buf[:8])
+	return binary.LittleEndian.Uint64(d.buf[:])
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.uint32()
+	if d.err != nil {
+		return nil
+	}
+	// 长度可能已经损坏，不预先分配，按实际读到的内容增长
+	var buf bytes.Buffer
+	if _, err := io.CopyN(io.MultiWriter(&buf, d.crc), d.r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		d.err = err
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// 读取 类型编号 + 值，返回值和类型编号
+func (d *decoder) value() (interface{}, uint8) {
+	kind := d.uint8()
+	var v interface{}
+	switch kind {
+	case kindNil:
+		v = nil
+	case kindBool:
+		v = d.uint8() != 0
+	case kindInt:
+		v = int(d.uint64())
+	case kindInt8:
+		v = int8(d.uint8())
+	case kindInt16:
+		v = int16(d.uint16())
+	case kindInt32:
+		v = int32(d.uint32())
+	case kindInt64:
+		v = int64(d.uint64())
+	case kindFloat32:
+		v = math.Float32frombits(d.uint32())
+	case kindFloat64:
+		v = math.Float64frombits(d.uint64())
+	case kindString:
+		v = string(d.bytes())
+	case kindBytes:
+		v = d.bytes()
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("snapshot: unknown value type %d", kind)
+		}
+	}
+	return v, kind
+}
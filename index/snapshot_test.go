@@ -0,0 +1,102 @@
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBtree_WriteTo(t *testing.T) {
+	values := []interface{}{nil, true, 1, int8(-2), int16(3), int32(-4), int64(5), float32(1.5), 2.5, "何惟禹", []byte{1, 2}}
+	cases := []struct {
+		name string
+		key  func(i int) interface{}
+	}{
+		{"int", func(i int) interface{} { return i }},
+		{"int32", func(i int) interface{} { return int32(i) }},
+		{"float64", func(i int) interface{} { return float64(i) / 3 }},
+		{"string", func(i int) interface{} { return fmt.Sprintf("key%03d", i) }},
+		{"mixed", func(i int) interface{} {
+			if i%2 == 0 {
+				return int64(i)
+			}
+			return fmt.Sprintf("key%03d", i)
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			bt := newBtree(4)
+			for i := 0; i < 50; i++ {
+				bt.Insert(c.key(i), values[i%len(values)])
+			}
+			bt.InsertWithTTL(c.key(100), "ttl", time.Hour)
+			var buf bytes.Buffer
+			n, err := bt.WriteTo(&buf)
+			if err != nil || n != int64(buf.Len()) {
+				t.Fatalf("write: %d bytes, %v", n, err)
+			}
+			got, err := ReadFrom(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.m != 4 || got.Stats().Keys != 51 {
+				t.Fatalf("m: %d, stats: %+v", got.m, got.Stats())
+			}
+			Diff(bt, got, func(d Difference) bool {
+				t.Fatalf("difference after read: %+v", d)
+				return false
+			})
+			if got.findByRoot(typeToKey(c.key(100))).expire == 0 {
+				t.Fatal("expire is lost")
+			}
+		})
+	}
+}
+
+func TestReadFrom_Corrupt(t *testing.T) {
+	bt := buildTree()
+	var buf bytes.Buffer
+	if _, err := bt.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	corrupt := append([]byte{}, data...)
+	corrupt[len(corrupt)-10] ^= 0xff
+	if _, err := ReadFrom(bytes.NewReader(corrupt)); err != ErrSnapshotChecksum {
+		t.Fatalf("got %v, want %v", err, ErrSnapshotChecksum)
+	}
+	if _, err := ReadFrom(bytes.NewReader(data[:len(data)-3])); err == nil {
+		t.Fatal("truncated snapshot should fail")
+	}
+	if _, err := ReadFrom(bytes.NewReader([]byte("HWYA"))); err != ErrBadSnapshot {
+		t.Fatalf("got %v, want %v", err, ErrBadSnapshot)
+	}
+	// 损坏的阶数在建树之前就被拒绝
+	for _, m := range []uint32{0, 2, maxSnapshotOrder + 1, math.MaxUint32} {
+		bad := append([]byte{}, data...)
+		binary.LittleEndian.PutUint32(bad[6:], m)
+		if _, err := ReadFrom(bytes.NewReader(bad)); err == nil || !strings.Contains(err.Error(), "order") {
+			t.Fatalf("m=%d: got %v", m, err)
+		}
+	}
+}
+
+func TestBtree_WriteToUnsupported(t *testing.T) {
+	bt := newBtree(3)
+	bt.Insert("key", struct{}{})
+	if _, err := bt.WriteTo(&bytes.Buffer{}); err == nil {
+		t.Fatal("unsupported value should fail")
+	}
+	empty := newBtree(5)
+	var buf bytes.Buffer
+	empty.WriteTo(&buf)
+	got, err := ReadFrom(&buf)
+	if err != nil || got.m != 5 || !reflect.DeepEqual(got.Stats().Levels, []int{1}) {
+		t.Fatalf("empty tree: %v", err)
+	}
+}
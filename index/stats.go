@@ -0,0 +1,97 @@
+package index
+
+// 树的统计信息，用于容量规划以及选择合适的阶数 m
+type Stats struct {
+	M             int     // 阶数
+	Height        int     // 树高（root.degree + 1）
+	Levels        []int   // 每层的节点数，下标即degree：Levels[0]为叶子层，Levels[Height-1]为root
+	Keys          int     // 叶子节点中的关键字个数（包括已过期但还没被清理的）
+	Expired       int     // 已过期但还没被清理的关键字个数
+	AvgLeafFill   float64 // 叶子节点的平均填充率（关键字个数 / m）
+	KeyBytes      int64   // 叶子节点关键字估算占用的字节数
+	IndexKeyBytes int64   // 索引节点关键字估算占用的字节数
+	ValueBytes    int64   // value估算占用的字节数
+	Splits        int64   // 自创建以来节点分裂的次数
+	Merges        int64   // 自创建以来节点合并的次数
+	Borrows       int64   // 自创建以来兄弟节点之间转移关键字的次数（插入时借给兄弟、删除时向兄弟借）
+}
+
+// 统计整棵树（从root开始层序遍历）
+func (bt *Btree) Stats() Stats {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+	st := Stats{
+		M:       bt.m,
+		Height:  bt.root.degree + 1,
+		Levels:  make([]int, bt.root.degree+1),
+		Splits:  bt.splits,
+		Merges:  bt.merges,
+		Borrows: bt.borrows,
+	}
+	leaves := 0
+	now := bt.now()
+	queue := []*BNode{bt.root}
+	for len(queue) > 0 {
+		bn := queue[0]
+		queue = queue[1:]
+		st.Levels[bn.degree]++
+		for _, sn := range bn.nodes {
+			if bn.isLeaf {
+				st.KeyBytes += sizeOfKey(sn.key)
+				st.ValueBytes += sizeOfValue(sn.value)
+				if sn.expired(now) {
+					st.Expired++
+				}
+				continue
+			}
+			st.IndexKeyBytes += sizeOfKey(sn.key)
+			queue = append(queue, sn.childPtr)
+		}
+		if bn.isLeaf {
+			leaves++
+			st.Keys += len(bn.nodes)
+		}
+	}
+	if leaves > 0 {
+		st.AvgLeafFill = float64(st.Keys) / float64(leaves*bt.m)
+	}
+	return st
+}
+
+// 估算关键字占用的字节数
+func sizeOfKey(key Key) int64 {
+	switch k := key.(type) {
+	case myint, myint64, myfloat64:
+		return 8
+	case myint8:
+		return 1
+	case myint16:
+		return 2
+	case myint32, myfloat32:
+		return 4
+	case mystr:
+		return int64(len(k)) + 16 // 字符串头部 + 内容
+	}
+	return 16
+}
+
+// 估算value占用的字节数，无法识别的类型按一个interface{}计算
+func sizeOfValue(value interface{}) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case bool, int8, uint8:
+		return 1
+	case int16, uint16:
+		return 2
+	case int32, uint32, float32:
+		return 4
+	case int, int64, uint, uint64, float64:
+		return 8
+	case string:
+		return int64(len(v)) + 16
+	case []byte:
+		return int64(len(v)) + 24 // 切片头部 + 内容
+	}
+	return 16
+}
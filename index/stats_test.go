@@ -0,0 +1,42 @@
+package index
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBtree_Stats(t *testing.T) {
+	bt := newBtree(3)
+	for i := 0; i < 100; i++ {
+		bt.Insert(int64(i), fmt.Sprintf("value%d", i))
+	}
+	st := bt.Stats()
+	if st.Keys != 100 {
+		t.Fatalf("keys: %d, want 100", st.Keys)
+	}
+	if st.Height != bt.root.degree+1 || len(st.Levels) != st.Height {
+		t.Fatalf("height: %d, levels: %v", st.Height, st.Levels)
+	}
+	if st.Levels[st.Height-1] != 1 {
+		t.Fatalf("root level should has one node: %v", st.Levels)
+	}
+	if st.Splits == 0 {
+		t.Fatal("splits should be counted")
+	}
+	if st.AvgLeafFill <= 0 || st.AvgLeafFill > 1 {
+		t.Fatalf("avg leaf fill: %v", st.AvgLeafFill)
+	}
+	if st.KeyBytes != 800 {
+		t.Fatalf("key bytes: %d, want 800", st.KeyBytes)
+	}
+	for i := 0; i < 90; i++ {
+		bt.Delete(int64(i))
+	}
+	st = bt.Stats()
+	if st.Keys != 10 || st.Merges == 0 || st.Borrows == 0 {
+		t.Fatalf("after delete: %+v", st)
+	}
+	if st := New(1).Stats(); st.M != 3 {
+		t.Fatalf("order 1 should be raised to 3, got %d", st.M)
+	}
+}
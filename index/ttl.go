@@ -0,0 +1,104 @@
+package index
+
+import (
+	"sync"
+	"time"
+)
+
+// 插入关键字，ttl之后过期（ttl <= 0 表示永不过期）
+func (bt *Btree) InsertWithTTL(key interface{}, value interface{}, ttl time.Duration) error {
+	input := typeToKey(key)
+	bt.mu.Lock()
+	defer bt.unlock()
+	return bt.insert(input, value, bt.expireAt(ttl))
+}
+
+// 更新value，并重新设置过期时间（ttl <= 0 表示永不过期）
+func (bt *Btree) UpdateWithTTL(key interface{}, value interface{}, ttl time.Duration) error {
+	input := typeToKey(key)
+	bt.mu.Lock()
+	defer bt.unlock()
+	return bt.update(input, value, bt.expireAt(ttl))
+}
+
+// 根据ttl计算过期时间
+func (bt *Btree) expireAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return bt.now().Add(ttl).UnixNano()
+}
+
+// 该叶子小节点是否已经过期
+func (sn *SNode) expired(now time.Time) bool {
+	return sn.expire != 0 && sn.expire <= now.UnixNano()
+}
+
+// 启动后台清理：每隔interval顺着叶子链表找出过期的关键字，每批最多删除batch个
+// interval <= 0 时每秒清理一次，batch <= 0 时每批100个；返回的函数用于停止清理，可以调用多次
+func (bt *Btree) StartSweeper(interval time.Duration, batch int) (stop func()) {
+	if interval <= 0 { // 在新的goroutine中NewTicker会panic，调用者无法recover
+		interval = time.Second
+	}
+	if batch <= 0 {
+		batch = 100
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// 每一批从上一批检查到的最后一个关键字之后继续，直到叶子链表的末尾
+				for after := bt.sweep(nil, batch); after != nil; after = bt.sweep(after, batch) {
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// 删除after之后（after为nil时从头开始）最多batch个过期的关键字，
+// 返回检查到的最后一个关键字，已经到叶子链表的末尾时返回nil
+// 查找时只加读锁，删除时才加写锁，避免长时间阻塞读写
+func (bt *Btree) sweep(after Key, batch int) Key {
+	bt.mu.RLock()
+	now := bt.now()
+	keys := make([]Key, 0, batch)
+	bn := bt.sqt
+	if after != nil {
+		bn, _ = bt.root.findBNode(after)
+	}
+	var last Key
+	for ; bn != nil && last == nil; bn = bn.next {
+		for _, sn := range bn.nodes {
+			if after != nil && !after.Less(sn.key) {
+				continue
+			}
+			if sn.expired(now) {
+				keys = append(keys, sn.key)
+				if len(keys) == batch {
+					last = sn.key
+					break
+				}
+			}
+		}
+	}
+	bt.mu.RUnlock()
+	if len(keys) == 0 {
+		return nil
+	}
+	bt.mu.Lock()
+	defer bt.unlock()
+	for _, key := range keys {
+		// 加写锁之前可能被重新设置过，需要再检查一次
+		if node := bt.findByRoot(key); node != nil && node.expired(bt.now()) {
+			bt.delete(key)
+		}
+	}
+	return last
+}
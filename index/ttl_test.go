@@ -0,0 +1,102 @@
+package index
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBtree_TTL(t *testing.T) {
+	bt := newBtree(3)
+	now := time.Now()
+	bt.now = func() time.Time { return now }
+	for i := int64(0); i < 20; i++ {
+		if i%2 == 0 {
+			bt.InsertWithTTL(i, i, time.Minute)
+		} else {
+			bt.Insert(i, i)
+		}
+	}
+	if bt.Find(int64(2)) != int64(2) {
+		t.Fatal("key should not expire yet")
+	}
+	bt.UpdateWithTTL(int64(4), int64(40), time.Hour)
+	now = now.Add(2 * time.Minute)
+	if bt.Find(int64(2)) != nil {
+		t.Fatal("expired key should be hidden")
+	}
+	if bt.Find(int64(4)) != int64(40) || bt.Find(int64(3)) != int64(3) {
+		t.Fatal("unexpired key is hidden")
+	}
+	// 过期的关键字可以重新插入
+	bt.Insert(int64(6), "new")
+	if bt.Find(int64(6)) != "new" {
+		t.Fatal("reinsert expired key error")
+	}
+	if st := bt.Stats(); st.Expired != 8 {
+		t.Fatalf("expired: %d, want 8", st.Expired)
+	}
+	// 每一批从上一批的最后一个关键字之后继续
+	batches := 1
+	for after := bt.sweep(nil, 3); after != nil; after = bt.sweep(after, 3) {
+		batches++
+	}
+	if st := bt.Stats(); st.Expired != 0 || st.Keys != 12 || batches != 3 {
+		t.Fatalf("after %d batches: %+v", batches, st)
+	}
+}
+
+func TestBtree_ModifyTTL(t *testing.T) {
+	bt := newBtree(3)
+	now := time.Now()
+	bt.now = func() time.Time { return now }
+	bt.InsertWithTTL("a", 1, time.Minute)
+	bt.InsertWithTTL("b", 1, time.Second)
+	now = now.Add(2 * time.Second)
+	var seen []bool
+	add := func(old interface{}, ok bool) (interface{}, error) {
+		seen = append(seen, ok)
+		if !ok {
+			return 100, nil
+		}
+		return old.(int) + 1, nil
+	}
+	bt.Modify("a", add)
+	bt.Modify("b", add) // 过期的关键字按不存在处理
+	if len(seen) != 2 || !seen[0] || seen[1] || bt.Find("a") != 2 || bt.Find("b") != 100 {
+		t.Fatalf("seen %v, a=%v, b=%v", seen, bt.Find("a"), bt.Find("b"))
+	}
+	now = now.Add(time.Minute) // 修改之后a仍然按原来的时间过期，b不再过期
+	if bt.Find("a") != nil || bt.Find("b") != 100 {
+		t.Fatalf("a=%v, b=%v", bt.Find("a"), bt.Find("b"))
+	}
+}
+
+func TestBtree_StartSweeper(t *testing.T) {
+	bt := newBtree(3)
+	for i := int64(0); i < 50; i++ {
+		bt.InsertWithTTL(i, i, time.Millisecond)
+	}
+	stop := bt.StartSweeper(5*time.Millisecond, 10)
+	defer stop()
+	deadline := time.Now().Add(time.Second)
+	for bt.Stats().Keys != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("sweeper does not remove expired keys: %+v", bt.Stats())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// interval <= 0 使用默认值，不会在后台的goroutine中panic
+func TestBtree_StartSweeperDefault(t *testing.T) {
+	bt := newBtree(3)
+	stop := bt.StartSweeper(0, 0)
+	time.Sleep(time.Millisecond)
+	stop()
+	stop = bt.StartSweeper(-time.Second, -1)
+	stop()
+	stop() // 可以调用多次
+	// 通过BT接口使用
+	var b BT = New(3)
+	b.StartSweeper(time.Millisecond, 1)()
+}
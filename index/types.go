@@ -1,5 +1,7 @@
 package index
 
+import "math"
+
 // 类型转换 interface{} => 定义好的类型
 func typeToKey(input interface{}) Key {
 	var out Key
@@ -11,7 +13,7 @@ func typeToKey(input interface{}) Key {
 	case int16:
 		out = myint16(input.(int16))
 	case int32:
-		out = myint16(input.(int32))
+		out = myint32(input.(int32))
 	case int64:
 		out = myint64(input.(int64))
 	case float32:
@@ -26,85 +28,176 @@ func typeToKey(input interface{}) Key {
 	return out
 }
 
+// 类型转换 定义好的类型 => interface{}（typeToKey的逆操作）
+func keyToType(key Key) interface{} {
+	switch k := key.(type) {
+	case myint:
+		return int(k)
+	case myint8:
+		return int8(k)
+	case myint16:
+		return int16(k)
+	case myint32:
+		return int32(k)
+	case myint64:
+		return int64(k)
+	case myfloat32:
+		return float32(k)
+	case myfloat64:
+		return float64(k)
+	case mystr:
+		return string(k)
+	}
+	return key
+}
+
 // 整型
 type myint int
 
 func (m myint) Less(than Key) bool {
-	than, ok := than.(myint)
-	if ok {
-		return m < than.(myint)
+	if than, ok := than.(myint); ok {
+		return m < than
 	}
-	panic("this key need int")
+	return lessMixed(m, than)
 }
 
 type myint8 int8
 
 func (m myint8) Less(than Key) bool {
-	than, ok := than.(myint8)
-	if ok {
-		return m < than.(myint8)
+	if than, ok := than.(myint8); ok {
+		return m < than
 	}
-	panic("this key need int8")
+	return lessMixed(m, than)
 }
 
 type myint16 int16
 
 func (m myint16) Less(than Key) bool {
-	than, ok := than.(myint16)
-	if ok {
-		return m < than.(myint16)
+	if than, ok := than.(myint16); ok {
+		return m < than
 	}
-	panic("this key need int16")
+	return lessMixed(m, than)
 }
 
 type myint32 int32
 
 func (m myint32) Less(than Key) bool {
-	than, ok := than.(myint32)
-	if ok {
-		return m < than.(myint32)
+	if than, ok := than.(myint32); ok {
+		return m < than
 	}
-	panic("this key need int32")
+	return lessMixed(m, than)
 }
 
 type myint64 int64
 
 func (m myint64) Less(than Key) bool {
-	than, ok := than.(myint64)
-	if ok {
-		return m < than.(myint64)
+	if than, ok := than.(myint64); ok {
+		return m < than
 	}
-	panic("this key need int64")
+	return lessMixed(m, than)
 }
 
 // 浮点型
 type myfloat32 float32
 
 func (m myfloat32) Less(than Key) bool {
-	than, ok := than.(myfloat32)
-	if ok {
-		return m < than.(myfloat32)
+	if than, ok := than.(myfloat32); ok {
+		return m < than
 	}
-	panic("this key need float32")
+	return lessMixed(m, than)
 }
 
 type myfloat64 float64
 
 func (m myfloat64) Less(than Key) bool {
-	than, ok := than.(myfloat64)
-	if ok {
-		return m < than.(myfloat64)
+	if than, ok := than.(myfloat64); ok {
+		return m < than
 	}
-	panic("this key need float64")
+	return lessMixed(m, than)
 }
 
 // 字符型
 type mystr string
 
 func (m mystr) Less(than Key) bool {
-	than, ok := than.(mystr)
-	if ok {
-		return m < than.(mystr)
+	if than, ok := than.(mystr); ok {
+		return m < than
+	}
+	return lessMixed(m, than)
+}
+
+// 不同类型的关键字比较：数字之间按数值比较（int和float可以比较），数字小于字符串
+func lessMixed(a, b Key) bool {
+	ai, af, aFloat, aNum := numeric(a)
+	bi, bf, bFloat, bNum := numeric(b)
+	switch {
+	case aNum && bNum:
+		switch {
+		case aFloat && bFloat:
+			return af < bf
+		case bFloat:
+			return cmpIntFloat(ai, bf) < 0
+		case aFloat:
+			return cmpIntFloat(bi, af) > 0
+		}
+		return ai < bi
+	case aNum:
+		_, ok := b.(mystr)
+		return ok
+	case bNum:
+		return false
+	}
+	panic("this key is not support!")
+}
+
+// 精确比较整数i和浮点数f，i小于、等于、大于f时分别返回-1、0、1
+// 不能都转换成float64比较：超过2^53的整数转换之后会丢失精度，不同的关键字会相等
+// NaN小于所有的整数
+func cmpIntFloat(i int64, f float64) int {
+	switch {
+	case math.IsNaN(f):
+		return 1
+	case f >= 1<<63: // 超出int64的范围
+		return -1
+	case f < -1<<63:
+		return 1
+	}
+	t := math.Trunc(f)
+	switch ti := int64(t); {
+	case i < ti:
+		return -1
+	case i > ti:
+		return 1
+	}
+	// 整数部分相等，比较小数部分
+	switch frac := f - t; {
+	case frac > 0:
+		return -1
+	case frac < 0:
+		return 1
+	}
+	return 0
+}
+
+// 数字类型的关键字转换成int64和float64，isFloat表示是否为浮点型
+func numeric(key Key) (i int64, f float64, isFloat bool, ok bool) {
+	switch k := key.(type) {
+	case myint:
+		i = int64(k)
+	case myint8:
+		i = int64(k)
+	case myint16:
+		i = int64(k)
+	case myint32:
+		i = int64(k)
+	case myint64:
+		i = int64(k)
+	case myfloat32:
+		return 0, float64(k), true, true
+	case myfloat64:
+		return 0, float64(k), true, true
+	default:
+		return 0, 0, false, false
 	}
-	panic("this key need string")
+	return i, float64(i), false, true
 }
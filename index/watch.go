@@ -0,0 +1,228 @@
+package index
+
+import (
+	"strings"
+	"sync"
+)
+
+// 变更事件的类型
+type EventType int
+
+const (
+	EventPut    EventType = iota // 插入新的关键字
+	EventUpdate                  // 更新已有关键字的value
+	EventDelete                  // 删除关键字（包括过期被清理的）
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventPut:
+		return "put"
+	case EventUpdate:
+		return "update"
+	case EventDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// 一次成功的写操作产生的事件
+type Event struct {
+	Type    EventType
+	Key     interface{}
+	Old     interface{} // 修改之前的value（put时为nil）
+	New     interface{} // 修改之后的value（delete时为nil）
+	Dropped uint64      // 非阻塞模式下，在本事件之前因为缓冲区满而丢弃的事件数
+}
+
+// 选择需要订阅的关键字
+type Selector interface {
+	match(key Key) bool
+}
+
+type prefixSelector string
+
+func (p prefixSelector) match(key Key) bool {
+	s, ok := key.(mystr)
+	return ok && strings.HasPrefix(string(s), string(p))
+}
+
+// 订阅以prefix开头的字符串关键字
+func Prefix(prefix string) Selector {
+	return prefixSelector(prefix)
+}
+
+type rangeSelector struct {
+	start, end Key // nil表示没有边界
+}
+
+func (r rangeSelector) match(key Key) bool {
+	if r.start != nil && key.Less(r.start) {
+		return false
+	}
+	if r.end != nil && !key.Less(r.end) {
+		return false
+	}
+	return true
+}
+
+// 订阅 [start, end) 范围内的关键字，start或end为nil表示该方向没有边界
+func Range(start, end interface{}) Selector {
+	r := rangeSelector{}
+	if start != nil {
+		r.start = typeToKey(start)
+	}
+	if end != nil {
+		r.end = typeToKey(end)
+	}
+	return r
+}
+
+// 订阅所有关键字
+func All() Selector {
+	return rangeSelector{}
+}
+
+type watchOptions struct {
+	buffer int  // 缓冲区大小
+	block  bool // 缓冲区满时是否阻塞写操作
+}
+
+type WatchOption func(o *watchOptions)
+
+// 设置缓冲区大小（默认64）
+func WithBuffer(n int) WatchOption {
+	return func(o *watchOptions) {
+		if n >= 0 {
+			o.buffer = n
+		}
+	}
+}
+
+// 缓冲区满时阻塞写操作，直到消费者取走事件（默认丢弃并在下一个事件的Dropped中计数）
+// 阻塞模式下不要在消费事件的goroutine里修改这棵树，否则会死锁
+func WithBlocking() WatchOption {
+	return func(o *watchOptions) {
+		o.block = true
+	}
+}
+
+type watcher struct {
+	mu      sync.Mutex
+	sel     Selector
+	opts    watchOptions
+	ch      chan Event
+	done    chan struct{}
+	closed  bool
+	dropped uint64
+}
+
+// 订阅变更事件，事件在写操作成功之后按顺序发送
+func (bt *Btree) Watch(sel Selector, opts ...WatchOption) <-chan Event {
+	o := watchOptions{buffer: 64}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	w := &watcher{
+		sel:  sel,
+		opts: o,
+		ch:   make(chan Event, o.buffer),
+		done: make(chan struct{}),
+	}
+	bt.wmu.Lock()
+	if bt.watchers == nil {
+		bt.watchers = make(map[*watcher]struct{})
+	}
+	bt.watchers[w] = struct{}{}
+	bt.wmu.Unlock()
+	return w.ch
+}
+
+// 取消订阅并关闭对应的channel
+func (bt *Btree) Unwatch(ch <-chan Event) {
+	var w *watcher
+	bt.wmu.Lock()
+	for cur := range bt.watchers {
+		if cur.ch == ch {
+			w = cur
+			delete(bt.watchers, cur)
+			break
+		}
+	}
+	bt.wmu.Unlock()
+	if w == nil {
+		return
+	}
+	close(w.done) // 唤醒阻塞在发送上的写操作
+	w.mu.Lock()
+	w.closed = true
+	close(w.ch)
+	w.mu.Unlock()
+}
+
+// 记录一个事件（持有写锁时调用），在unlock时分发
+func (bt *Btree) emit(typ EventType, key Key, old, new interface{}) {
+	bt.wmu.Lock()
+	n := len(bt.watchers)
+	bt.wmu.Unlock()
+	if n == 0 {
+		return
+	}
+	bt.events = append(bt.events, pendingEvent{key: key, ev: Event{Type: typ, Key: keyToType(key), Old: old, New: new}})
+}
+
+// 还没分发的事件，保留Key用于匹配Selector
+type pendingEvent struct {
+	key Key
+	ev  Event
+}
+
+// 释放写锁，并分发本次写操作产生的事件
+// 先拿到nmu再释放写锁，保证下一个写操作的事件在本次之后分发
+func (bt *Btree) unlock() {
+	if len(bt.events) == 0 {
+		bt.mu.Unlock()
+		return
+	}
+	events := bt.events
+	bt.events = nil
+	bt.nmu.Lock()
+	defer bt.nmu.Unlock()
+	bt.mu.Unlock()
+	bt.wmu.Lock()
+	watchers := make([]*watcher, 0, len(bt.watchers))
+	for w := range bt.watchers {
+		watchers = append(watchers, w)
+	}
+	bt.wmu.Unlock()
+	for _, pe := range events {
+		for _, w := range watchers {
+			if w.sel.match(pe.key) {
+				w.send(pe.ev)
+			}
+		}
+	}
+}
+
+func (w *watcher) send(ev Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	ev.Dropped = w.dropped
+	if w.opts.block {
+		select {
+		case w.ch <- ev:
+			w.dropped = 0
+		case <-w.done:
+		}
+		return
+	}
+	select {
+	case w.ch <- ev:
+		w.dropped = 0
+	default:
+		w.dropped++
+	}
+}
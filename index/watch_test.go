@@ -0,0 +1,117 @@
+package index
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBtree_Watch(t *testing.T) {
+	bt := newBtree(3)
+	all := bt.Watch(All())
+	users := bt.Watch(Prefix("user:"))
+	bt.Insert("user:1", "a")
+	bt.Insert("order:1", "x")
+	bt.Update("user:1", "b")
+	bt.Delete("user:1")
+	bt.Delete("user:2") // 不存在，不产生事件
+	want := []Event{
+		{Type: EventPut, Key: "user:1", New: "a"},
+		{Type: EventUpdate, Key: "user:1", Old: "a", New: "b"},
+		{Type: EventDelete, Key: "user:1", Old: "b"},
+	}
+	for _, w := range want {
+		if ev := <-users; ev != w {
+			t.Fatalf("got %+v, want %+v", ev, w)
+		}
+	}
+	if len(users) != 0 || len(all) != 4 {
+		t.Fatalf("users: %d events, all: %d events", len(users), len(all))
+	}
+	bt.Unwatch(users)
+	if _, ok := <-users; ok {
+		t.Fatal("channel should be closed")
+	}
+}
+
+func TestBtree_WatchRange(t *testing.T) {
+	bt := newBtree(3)
+	ch := bt.Watch(Range(int64(10), int64(20)))
+	for i := int64(0); i < 30; i++ {
+		bt.Insert(i, i)
+	}
+	if len(ch) != 10 {
+		t.Fatalf("got %d events, want 10", len(ch))
+	}
+	for i := int64(10); i < 20; i++ {
+		if ev := <-ch; ev.Key != i {
+			t.Fatalf("got key %v, want %v", ev.Key, i)
+		}
+	}
+}
+
+func TestBtree_WatchDrop(t *testing.T) {
+	bt := newBtree(3)
+	ch := bt.Watch(All(), WithBuffer(2))
+	for i := int64(0); i < 5; i++ {
+		bt.Insert(i, i)
+	}
+	<-ch
+	<-ch
+	bt.Insert(int64(5), int64(5))
+	if ev := <-ch; ev.Key != int64(5) || ev.Dropped != 3 {
+		t.Fatalf("got %+v, want key 5 with 3 dropped", ev)
+	}
+}
+
+func TestBtree_WatchBlocking(t *testing.T) {
+	bt := newBtree(3)
+	ch := bt.Watch(All(), WithBuffer(0), WithBlocking())
+	done := make(chan struct{})
+	go func() {
+		for i := int64(0); i < 10; i++ {
+			bt.Insert(i, i)
+		}
+		close(done)
+	}()
+	for i := int64(0); i < 10; i++ {
+		select {
+		case ev := <-ch:
+			if ev.Key != i {
+				t.Fatalf("got key %v, want %v", ev.Key, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("blocking watcher lost events")
+		}
+	}
+	<-done
+	// 取消订阅之后写操作不能再被阻塞
+	bt.Unwatch(ch)
+	bt.Insert(int64(100), int64(100))
+}
+
+func TestBtree_WatchExpire(t *testing.T) {
+	bt := newBtree(3)
+	now := time.Now()
+	bt.now = func() time.Time { return now }
+	bt.InsertWithTTL("session", "token", time.Second)
+	ch := bt.Watch(All())
+	now = now.Add(time.Minute)
+	bt.sweep(nil, 10)
+	if ev := <-ch; ev.Type != EventDelete || ev.Key != "session" || ev.Old != "token" {
+		t.Fatalf("got %+v", ev)
+	}
+}
+
+// 通过BT接口使用Watch，不需要断言成*Btree
+func TestBT_Watch(t *testing.T) {
+	var bt BT = New(3)
+	ch := bt.Watch(Prefix("k"))
+	bt.Insert("k1", 1)
+	if ev := <-ch; ev.Type != EventPut || ev.Key != "k1" || ev.New != 1 {
+		t.Fatalf("got %+v", ev)
+	}
+	bt.Unwatch(ch)
+	if _, ok := <-ch; ok {
+		t.Fatal("channel should be closed")
+	}
+}
@@ -0,0 +1,323 @@
+package sql
+
+import (
+	"HwyDB/catalog"
+	"fmt"
+	"strings"
+)
+
+// 聚合函数：count、sum、avg、min、max，除count(*)之外都忽略null
+// 有聚合函数或者group by的select按以下步骤执行：
+//	1. 按where过滤之后，按group by的表达式分组，计算每组的聚合函数，输出 group by的值 + 聚合函数的值 组成的行
+//	2. fields、having、order by中的表达式改写成引用这一行中的列（slot节点），
+//	   不在聚合函数中的列必须和group by中的某个表达式相同
+
+// 聚合函数的累加器
+type accumulator interface {
+	add(v interface{}) error
+	result() interface{}
+}
+
+var aggregates = map[string]func() accumulator{
+	"count": func() accumulator { return &countAcc{} },
+	"sum":   func() accumulator { return &sumAcc{} },
+	"avg":   func() accumulator { return &avgAcc{} },
+	"min":   func() accumulator { return &extremeAcc{want: -1} },
+	"max":   func() accumulator { return &extremeAcc{want: 1} },
+}
+
+type countAcc struct {
+	n int64
+}
+
+func (a *countAcc) add(v interface{}) error {
+	if v != nil {
+		a.n++
+	}
+	return nil
+}
+
+func (a *countAcc) result() interface{} {
+	return a.n
+}
+
+// 整数之和还是整数，有浮点数时是浮点数，没有值时是null
+type sumAcc struct {
+	sum interface{}
+}
+
+func (a *sumAcc) add(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	if _, ok := toFloat(number(v)); !ok {
+		return fmt.Errorf("sum() needs numbers, got %T", v)
+	}
+	if a.sum == nil {
+		a.sum = number(v)
+		return nil
+	}
+	sum, err := arith("+", a.sum, v)
+	a.sum = sum
+	return err
+}
+
+func (a *sumAcc) result() interface{} {
+	return a.sum
+}
+
+type avgAcc struct {
+	sum float64
+	n   int64
+}
+
+func (a *avgAcc) add(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	f, ok := toFloat(number(v))
+	if !ok {
+		return fmt.Errorf("avg() needs numbers, got %T", v)
+	}
+	a.sum += f
+	a.n++
+	return nil
+}
+
+func (a *avgAcc) result() interface{} {
+	if a.n == 0 {
+		return nil
+	}
+	return a.sum / float64(a.n)
+}
+
+// min（want为-1）和max（want为1）
+type extremeAcc struct {
+	want int
+	v    interface{}
+}
+
+func (a *extremeAcc) add(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	if a.v == nil {
+		a.v = number(v)
+		return nil
+	}
+	c, err := compareValues(v, a.v)
+	if err != nil {
+		return err
+	}
+	if c == a.want {
+		a.v = number(v)
+	}
+	return nil
+}
+
+func (a *extremeAcc) result() interface{} {
+	return a.v
+}
+
+// ----------- 改写表达式 ---------------
+
+// 表达式中是否有聚合函数
+func hasAggregate(node *SynatxTreeNode) bool {
+	if node.Name == "aggregate" {
+		return true
+	}
+	for _, c := range node.Child {
+		if hasAggregate(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// 把在聚合之后计算的表达式改写成引用聚合结果中的列：
+// 和第i个group by相同的表达式引用第i列，聚合函数依次加入lp.aggs，引用group by之后的列
+func (lp *logicalPlan) rewriteAgg(node *SynatxTreeNode) (*SynatxTreeNode, error) {
+	for i, g := range lp.groups {
+		if lp.sameExpr(node, g) {
+			return &SynatxTreeNode{Name: "slot", Value: i, Pos: node.Pos}, nil
+		}
+	}
+	switch node.Name {
+	case "aggregate":
+		for _, c := range node.Child {
+			if hasAggregate(c) {
+				return nil, fmt.Errorf("aggregate function %s() can not be nested", node.Value)
+			}
+		}
+		lp.aggs = append(lp.aggs, node)
+		return &SynatxTreeNode{Name: "slot", Value: len(lp.groups) + len(lp.aggs) - 1, Pos: node.Pos}, nil
+	case "ident":
+		return nil, fmt.Errorf("column %s must appear in group by or be used in an aggregate function", strings.ToLower(node.Value.(string)))
+	}
+	out := *node
+	out.Child = make([]*SynatxTreeNode, len(node.Child))
+	for i, c := range node.Child {
+		var err error
+		if out.Child[i], err = lp.rewriteAgg(c); err != nil {
+			return nil, err
+		}
+	}
+	return &out, nil
+}
+
+// 两个表达式是否相同，列名按照对应的列比较
+func (lp *logicalPlan) sameExpr(a, b *SynatxTreeNode) bool {
+	if a.Name != b.Name || len(a.Child) != len(b.Child) {
+		return false
+	}
+	if a.Name == "ident" {
+		i, _ := lp.scope.lookup(a.Value.(string))
+		j, _ := lp.scope.lookup(b.Value.(string))
+		return i == j
+	}
+	if a.Value != b.Value {
+		return false
+	}
+	for i := range a.Child {
+		if !lp.sameExpr(a.Child[i], b.Child[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ----------- 算子 ---------------
+
+// 分组和计算聚合函数的公共部分
+type aggregator struct {
+	groups []*SynatxTreeNode
+	aggs   []*SynatxTreeNode
+	ctx    *evalContext
+}
+
+// 一组的group by的值和累加器
+type aggGroup struct {
+	keys []interface{}
+	accs []accumulator
+}
+
+func (a *aggregator) newGroup(keys []interface{}) *aggGroup {
+	g := &aggGroup{keys: keys, accs: make([]accumulator, len(a.aggs))}
+	for i, agg := range a.aggs {
+		g.accs[i] = aggregates[agg.Value.(string)]()
+	}
+	return g
+}
+
+// 计算一行的group by的值，以及用来判断是否同一组的字符串
+func (a *aggregator) groupKey(row []interface{}) ([]interface{}, string, error) {
+	a.ctx.row = row
+	keys := make([]interface{}, len(a.groups))
+	for i, g := range a.groups {
+		v, err := a.ctx.eval(g)
+		if err != nil {
+			return nil, "", err
+		}
+		keys[i] = number(v)
+	}
+	buf, err := catalog.EncodeRow(keys)
+	return keys, string(buf), err
+}
+
+func (a *aggregator) add(g *aggGroup, row []interface{}) error {
+	a.ctx.row = row
+	for i, agg := range a.aggs {
+		var v interface{} = true // count(*)
+		if len(agg.Child) > 0 {
+			var err error
+			if v, err = a.ctx.eval(agg.Child[0]); err != nil {
+				return err
+			}
+		}
+		if err := g.accs[i].add(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 聚合结果：group by的值 + 聚合函数的值
+func (g *aggGroup) row() []interface{} {
+	row := append([]interface{}(nil), g.keys...)
+	for _, acc := range g.accs {
+		row = append(row, acc.result())
+	}
+	return row
+}
+
+// 哈希聚合：读取所有的行，按group by的值放到哈希表中，按每组第一次出现的顺序输出
+// 没有group by时即使没有输入也输出一行
+type hashAggregate struct {
+	input operator
+	aggregator
+}
+
+func (h *hashAggregate) run(fn func(row []interface{}) (bool, error)) error {
+	groups := make(map[string]*aggGroup)
+	var order []*aggGroup
+	err := h.input.run(func(row []interface{}) (bool, error) {
+		keys, key, err := h.groupKey(row)
+		if err != nil {
+			return false, err
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = h.newGroup(keys)
+			groups[key] = g
+			order = append(order, g)
+		}
+		return true, h.add(g, row)
+	})
+	if err != nil {
+		return err
+	}
+	if len(h.groups) == 0 && len(order) == 0 {
+		order = append(order, h.newGroup(nil))
+	}
+	for _, g := range order {
+		if ok, err := fn(g.row()); !ok || err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 排序聚合：输入已经按group by的值排好序（如按主键顺序扫描叶子链表），
+// 相同的值是连续的，一组结束时就输出，只需要保存当前的一组
+type streamAggregate struct {
+	input operator
+	aggregator
+}
+
+func (s *streamAggregate) run(fn func(row []interface{}) (bool, error)) error {
+	var cur *aggGroup
+	curKey := ""
+	stopped := false
+	err := s.input.run(func(row []interface{}) (bool, error) {
+		keys, key, err := s.groupKey(row)
+		if err != nil {
+			return false, err
+		}
+		if cur == nil || key != curKey {
+			if cur != nil {
+				ok, err := fn(cur.row())
+				if !ok || err != nil {
+					stopped = true
+					return false, err
+				}
+			}
+			cur, curKey = s.newGroup(keys), key
+		}
+		return true, s.add(cur, row)
+	})
+	if err != nil || stopped || cur == nil {
+		return err
+	}
+	_, err = fn(cur.row())
+	return err
+}
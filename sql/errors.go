@@ -0,0 +1,73 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// 语法错误（包括词法错误），带有出错的位置
+type SyntaxError struct {
+	Pos  Position
+	Msg  string
+	Line string // 出错的那一行语句，为空时不显示
+}
+
+// line是出错的那一行
+func newSyntaxError(line string, pos Position, msg string) *SyntaxError {
+	return &SyntaxError{Pos: pos, Msg: msg, Line: line}
+}
+
+// 形如：
+//
+//	syntax error at line 1, column 12: near "find"
+//	insert age find
+//	           ^
+func (e *SyntaxError) Error() string {
+	msg := fmt.Sprintf("syntax error at line %d, column %d: %s", e.Pos.Row, e.Pos.Col, e.Msg)
+	if e.Line == "" {
+		return msg
+	}
+	return msg + "\n" + e.Line + "\n" + caret(e.Line, e.Pos.Col)
+}
+
+// 一个脚本中所有的语法错误，按出现的顺序
+type SyntaxErrors []*SyntaxError
+
+func (l SyntaxErrors) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// 第一个错误，errors.As可以得到*SyntaxError
+func (l SyntaxErrors) Unwrap() error {
+	return l[0]
+}
+
+// 生成指向第col个字符的^，tab保持不变，中文等宽字符占两列
+func caret(line string, col int) string {
+	var sb strings.Builder
+	i := 1
+	for _, r := range line {
+		if i >= col {
+			break
+		}
+		switch {
+		case r == '\t':
+			sb.WriteRune('\t')
+		case r >= 0x1100 && utf8.RuneLen(r) > 2:
+			sb.WriteString("  ")
+		default:
+			sb.WriteByte(' ')
+		}
+		i++
+	}
+	for ; i < col; i++ { // 行尾之后（如缺少token）
+		sb.WriteByte(' ')
+	}
+	sb.WriteByte('^')
+	return sb.String()
+}
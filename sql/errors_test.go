@@ -0,0 +1,68 @@
+package sql
+
+import (
+	"HwyDB/index"
+	"errors"
+	"testing"
+)
+
+func TestSyntaxError(t *testing.T) {
+	db := Open(index.New(3))
+	cases := []struct {
+		name string
+		sql  string
+		pos  Position
+		msg  string
+	}{
+		{"keyword value", "insert age find", Position{1, 12},
+			"syntax error at line 1, column 12: You have a syntax error near: find\ninsert age find\n           ^"},
+		{"multi line", "insert\n  age\n  find;", Position{3, 3},
+			"syntax error at line 3, column 3: You have a syntax error near: find\n  find;\n  ^"},
+		{"missing value", "insert age", Position{1, 11},
+			"syntax error at line 1, column 11: unexpected end of statement\ninsert age\n          ^"},
+		{"extra token", "find a b", Position{1, 8},
+			"syntax error at line 1, column 8: You have a syntax error near: b\nfind a b\n       ^"},
+		{"unknown char", "find\n\ta #", Position{2, 4},
+			"syntax error at line 2, column 4: unknown character '#'\n\ta #\n\t  ^"},
+		{"unterminated", "insert '名字' '何惟禹", Position{1, 13},
+			"syntax error at line 1, column 13: unterminated string\ninsert '名字' '何惟禹\n              ^"},
+		{"escape", "insert a 'x\\q'", Position{1, 12},
+			"syntax error at line 1, column 12: unknown escape sequence \\q\ninsert a 'x\\q'\n           ^"},
+		{"invalid number", "insert a 1.2.3", Position{1, 10},
+			"syntax error at line 1, column 10: invalid number: 1.2.3\ninsert a 1.2.3\n         ^"},
+		{"integer out of range", "insert 99999999999999999999 1", Position{1, 8},
+			"syntax error at line 1, column 8: integer out of range: 99999999999999999999\ninsert 99999999999999999999 1\n       ^"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Exec(db, c.sql)
+			var se *SyntaxError
+			if !errors.As(err, &se) {
+				t.Fatalf("got %v, want SyntaxError", err)
+			}
+			if se.Pos != c.pos || se.Error() != c.msg {
+				t.Fatalf("got %v\n%s\nwant %v\n%s", se.Pos, se.Error(), c.pos, c.msg)
+			}
+		})
+	}
+}
+
+func Test_tokenPosition(t *testing.T) {
+	l := NewLex("insert '名字'\n  '何惟禹' x")
+	want := []Position{{1, 1}, {1, 8}, {2, 3}, {2, 9}, {2, 10}}
+	if len(l.tokens) != len(want) {
+		t.Fatalf("tokens: %v", l.tokens)
+	}
+	for i, tk := range l.tokens {
+		if tk.pos != want[i] {
+			t.Fatalf("token %v at %v, want %v", tk, tk.pos, want[i])
+		}
+	}
+	root, err := NewTokenReader(l.tokens[:3]).buildAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Pos != (Position{1, 1}) || root.Child[1].Pos != (Position{2, 3}) {
+		t.Fatalf("ast position: %v, %v", root.Pos, root.Child[1].Pos)
+	}
+}
@@ -0,0 +1,80 @@
+package sql
+
+import (
+	"HwyDB/catalog"
+	"HwyDB/index"
+	"time"
+)
+
+// 没有办法从bt得到阶数时，新建表使用的阶数
+const defaultOrder = 32
+
+// 对外提供的数据库，在index.BT上执行查询语句，表保存在catalog中
+type DB struct {
+	bt      index.BT
+	catalog *catalog.Catalog
+	lock    *txLock // 事务持有写锁，其它语句执行时持有读锁
+	tx      *txn    // 事务中执行语句时不为nil，修改时记录撤销的操作
+}
+
+func Open(bt index.BT) *DB {
+	m := defaultOrder
+	if o, ok := bt.(interface{ Order() int }); ok {
+		m = o.Order()
+	}
+	return &DB{bt: bt, catalog: catalog.New(m), lock: newTxLock()}
+}
+
+// 设置语句等待其它会话的事务结束的最长时间，超过时返回ErrLockTimeout，默认是5秒
+func (db *DB) SetLockTimeout(d time.Duration) {
+	db.lock.mu.Lock()
+	db.lock.timeout = d
+	db.lock.mu.Unlock()
+}
+
+// 数据库中的表
+func (db *DB) Catalog() *catalog.Catalog {
+	return db.catalog
+}
+
+// 执行结果
+type Result struct {
+	Statement string          // 语句的类型：insert、find、update、delete、create table等
+	Keys      []interface{}   // 受影响（或找到）的关键字
+	Value     interface{}     // find返回的value（explain时是语法树和执行计划的文本）
+	Values    []interface{}   // 范围查询时和Keys一一对应的value（范围查询时不为nil）
+	Found     bool            // find是否找到了关键字
+	Columns   []string        // select输出的列名
+	Rows      [][]interface{} // select输出的行（select时不为nil）
+}
+
+// 执行语句：词法分析 -> 生成语法树 -> 执行
+// query中可以有多条以;分隔的语句，按顺序执行并返回最后一条语句的结果；
+// 出错时停止（之前的语句已经执行，没有提交的事务回滚）
+// query在一个新的会话中执行，最后还没有提交的事务会回滚并返回ErrTxNotFinished
+func Exec(db *DB, query string) (*Result, error) {
+	s := db.NewSession()
+	ret, err := s.Exec(query)
+	if ferr := s.finish(); err == nil && ferr != nil {
+		return nil, ferr
+	}
+	return ret, err
+}
+
+// 依次执行tr中的语句，至少要有一条
+func (s *Session) execTokens(tr *TokenReader) (*Result, error) {
+	tr.skipEmpty()
+	for {
+		root, err := tr.buildAST()
+		if err != nil {
+			return nil, err
+		}
+		if tr.firstParam != nil { // 占位符只能在Prepare的语句中使用
+			return nil, tr.errorf(tr.firstParam, "placeholder %s has no argument, use Prepare", tr.firstParam.lit)
+		}
+		ret, err := s.exec(root)
+		if err != nil || !tr.skipEmpty() {
+			return ret, err
+		}
+	}
+}
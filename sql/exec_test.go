@@ -0,0 +1,280 @@
+package sql
+
+import (
+	"HwyDB/index"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestExec(t *testing.T) {
+	db := Open(index.New(3))
+	cases := []struct {
+		name  string
+		sql   string
+		found bool
+		value interface{}
+		err   error
+	}{
+		{"insert", "insert name 'hwy'", false, nil, nil},
+		{"find", "find name", true, "hwy", nil},
+		{"find not exist", "find age", false, nil, nil},
+		{"insert exist", "insert name 'wu'", false, nil, index.ErrKeyExist},
+		{"update", "update name 'wu';", false, nil, nil},
+		{"find updated", "find name", true, "wu", nil},
+		{"update not exist", "update age 1", false, nil, index.ErrKeyNotExist},
+		{"delete", "delete name", false, nil, nil},
+		{"delete not exist", "delete name", false, nil, index.ErrKeyNotExist},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ret, err := Exec(db, c.sql)
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("got error %v, want %v", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ret.Found != c.found || ret.Value != c.value {
+				t.Fatalf("got %+v", ret)
+			}
+		})
+	}
+}
+
+func TestExec_SyntaxError(t *testing.T) {
+	db := Open(index.New(3))
+	for _, sql := range []string{"", "age 29", "select age", "insert", "find", "insert age 1 #"} {
+		if _, err := Exec(db, sql); err == nil {
+			t.Fatalf("%q should fail", sql)
+		}
+	}
+}
+
+func TestExec_MultiStatement(t *testing.T) {
+	db := Open(index.New(3))
+	ret, err := Exec(db, "insert a 1; ; insert b 2 -- 注释\n; find a;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.Statement != "find" || ret.Value != int64(1) {
+		t.Fatalf("got %+v", ret)
+	}
+	if ret, err := Exec(db, "find b"); err != nil || ret.Value != int64(2) {
+		t.Fatalf("got %+v, %v", ret, err)
+	}
+	// 出错时停止，之前的语句已经执行
+	if _, err := Exec(db, "insert c 3; insert c 4; insert d 5"); !errors.Is(err, index.ErrKeyExist) {
+		t.Fatalf("got error %v", err)
+	}
+	if ret, _ := Exec(db, "find c; find d"); ret.Found {
+		t.Fatalf("d should not be inserted: %+v", ret)
+	}
+	for _, sql := range []string{";;", "-- only comment", "find a; find"} {
+		if _, err := Exec(db, sql); err == nil {
+			t.Fatalf("%q should fail", sql)
+		}
+	}
+}
+
+func TestExec_Range(t *testing.T) {
+	db := Open(index.New(3))
+	for i := 1; i <= 20; i++ {
+		if _, err := Exec(db, fmt.Sprintf("insert %d %d", i, i*10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Exec(db, "insert 'user:1' a; insert 'user:2' b; insert 'user:10' c; insert users d"); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		sql  string
+		keys []interface{}
+	}{
+		{"find between 3 and 6", []interface{}{int64(3), int64(4), int64(5), int64(6)}},
+		{"find between 2.5 and 4.5", []interface{}{int64(3), int64(4)}},
+		{"find between 6 and 3", []interface{}{}},
+		{"find > 17", []interface{}{int64(18), int64(19), int64(20)}},
+		{"find >= 17 limit 2", []interface{}{int64(17), int64(18)}},
+		{"find < 3 order by key desc", []interface{}{int64(2), int64(1)}},
+		{"find <= 3 order by KEY asc limit 10", []interface{}{int64(1), int64(2), int64(3)}},
+		{"find > 5 order by key desc limit 3", []interface{}{int64(20), int64(19), int64(18)}},
+		{"find < 'user:10'", []interface{}{"user:1"}},
+		{"find >= 'user:2' order by key desc", []interface{}{"users", "user:2"}},
+		{"find <= 'a' order by key desc", []interface{}{}},
+		{"find between 19 and 'user:1'", []interface{}{int64(19), int64(20), "user:1"}},
+		{"find prefix 'user:'", []interface{}{"user:1", "user:10", "user:2"}},
+		{"find prefix 'user:' order by key desc limit 1", []interface{}{"user:2"}},
+		{"find prefix 'none'", []interface{}{}},
+		{"find > 1 limit 0", []interface{}{}},
+	}
+	for _, c := range cases {
+		ret, err := Exec(db, c.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", c.sql, err)
+		}
+		if !reflect.DeepEqual(ret.Keys, c.keys) || len(ret.Values) != len(ret.Keys) || ret.Found != (len(c.keys) > 0) {
+			t.Fatalf("%s: got %+v, want keys %v", c.sql, ret, c.keys)
+		}
+	}
+	ret, _ := Exec(db, "find between 4 and 5")
+	if !reflect.DeepEqual(ret.Values, []interface{}{int64(40), int64(50)}) {
+		t.Fatalf("values: %v", ret.Values)
+	}
+	for _, sql := range []string{"find between 1", "find between 1 or 2", "find > ", "find prefix 1",
+		"find > 1 limit -1", "find > 1 limit 1.5", "find > 1 order key", "find > 1 order by value", "find a limit 1",
+		"find > 1 limit 1 order by key"} {
+		if _, err := Exec(db, sql); err == nil {
+			t.Fatalf("%q should fail", sql)
+		}
+	}
+}
+
+func TestExec_TypedValue(t *testing.T) {
+	db := Open(index.New(3))
+	cases := []struct {
+		sql   string
+		key   string
+		value interface{}
+	}{
+		{"insert age 29", "age", int64(29)},
+		{"insert neg -3", "neg", int64(-3)},
+		{"insert pi 3.14", "pi", 3.14},
+		{"insert big 1e3", "big", 1000.0},
+		{"insert name 'hwy'", "name", "hwy"},
+		{"insert ok true", "ok", true},
+		{"insert no FALSE", "no", false},
+		{"insert nothing null", "nothing", nil},
+		{"insert word hello", "word", "hello"},
+	}
+	for _, c := range cases {
+		if _, err := Exec(db, c.sql); err != nil {
+			t.Fatalf("%s: %v", c.sql, err)
+		}
+		ret, err := Exec(db, "find "+c.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ret.Found || ret.Value != c.value {
+			t.Fatalf("%s: got %#v, want %#v", c.sql, ret.Value, c.value)
+		}
+	}
+	if _, err := Exec(db, "update age 1.2.3"); err == nil {
+		t.Fatal("invalid number should fail")
+	}
+	if _, err := Exec(db, "update age find"); err == nil {
+		t.Fatal("keyword is not a value")
+	}
+}
+
+func TestExec_Key(t *testing.T) {
+	db := Open(index.New(3))
+	cases := []struct {
+		sql   string
+		found bool
+		value interface{}
+	}{
+		{"insert 42 'answer'", false, nil},
+		{"insert -1 'minus'", false, nil},
+		{"insert 2.5 'float'", false, nil},
+		{"insert name 'hwy'", false, nil},
+		{"insert 'quoted' 1", false, nil},
+		{"insert 'my key' 2", false, nil},
+		{"find 42", true, "answer"},
+		{"find 42.0", true, "answer"},
+		{"find -1", true, "minus"},
+		{"find 2.5", true, "float"},
+		{"find '42'", false, nil},
+		{"find name", true, "hwy"},
+		{"find quoted", true, int64(1)},
+		{"find 'my key'", true, int64(2)},
+		{"update 42 'ANSWER'", false, nil},
+		{"find 42", true, "ANSWER"},
+		{"delete 2.5", false, nil},
+		{"find 2.5", false, nil},
+	}
+	for _, c := range cases {
+		ret, err := Exec(db, c.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", c.sql, err)
+		}
+		if ret.Found != c.found || ret.Value != c.value {
+			t.Fatalf("%s: got %+v", c.sql, ret)
+		}
+	}
+	if _, err := Exec(db, "find true"); err == nil {
+		t.Fatal("bool key should fail")
+	}
+}
+
+func TestExec_Modify(t *testing.T) {
+	db := Open(index.New(3))
+	Exec(db, "insert name 'hwy'; insert price 1.5; insert ok true; insert step 5")
+	cases := []struct {
+		sql   string
+		value interface{}
+	}{
+		{"incr counter", int64(1)},
+		{"incr counter", int64(2)},
+		{"incr counter 10", int64(12)},
+		{"decr counter", int64(11)},
+		{"decr counter step * 2", int64(1)},
+		{"incr counter -3", int64(-2)},
+		{"decr fresh", int64(-1)},
+		{"incr price 1", 2.5},
+		{"incr price", 3.5},
+		{"append name '-wu'", "hwy-wu"},
+		{"append name concat('!', 1)", "hwy-wu!1"},
+		{"append greeting hello", "hello"},
+	}
+	for _, c := range cases {
+		ret, err := Exec(db, c.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", c.sql, err)
+		}
+		if ret.Value != c.value || len(ret.Keys) != 1 {
+			t.Fatalf("%s: got %+v, want %#v", c.sql, ret, c.value)
+		}
+	}
+	for _, sql := range []string{"incr name", "incr ok", "append counter 'x'", "append ok 'x'", "incr counter 'x'", "append name 1", "incr missing_step step2"} {
+		if _, err := Exec(db, sql); err == nil {
+			t.Fatalf("%q should fail", sql)
+		}
+	}
+	if ret, _ := Exec(db, "find counter"); ret.Value != int64(-2) {
+		t.Fatalf("failed statements should not change the value: %v", ret.Value)
+	}
+	for _, sql := range []string{"incr", "append name", "incr counter 1 2"} {
+		_, err := Exec(db, sql)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Fatalf("%q: got %v, want SyntaxError", sql, err)
+		}
+	}
+}
+
+func TestExec_IncrConcurrent(t *testing.T) {
+	db := Open(index.New(3))
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if _, err := Exec(db, "incr hits"); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if ret, _ := Exec(db, "find hits"); ret.Value != int64(1600) {
+		t.Fatalf("hits: %v, want 1600", ret.Value)
+	}
+}
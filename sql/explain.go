@@ -0,0 +1,439 @@
+package sql
+
+import (
+	"HwyDB/catalog"
+	"HwyDB/index"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// explain <语句>：不执行语句，输出语句的语法树和执行计划
+// 执行计划中每个算子一行，子算子缩进在下面，括号中是估计的行数
+// 没有列上的统计信息，估计行数时用表的行数乘以条件的选择率，选择率按以下的经验值：
+//	列 = 值 1/10，范围条件 1/3（上下界都有时 1/9），其它条件 1/2
+// 例如：
+//	explain select name from users where age > 30 order by name
+//	plan:
+//	  project: name (rows=34)
+//	    sort by name (rows=34)
+//	      index scan on users using idx_age (age > 30) (rows=34)
+
+const (
+	eqSel      = 0.1
+	rangeSel   = 1.0 / 3
+	defaultSel = 0.5
+)
+
+// 执行计划中的一个算子
+type planNode struct {
+	desc  string
+	rows  float64 // 估计输出的行数，小于0时不显示
+	child []*planNode
+}
+
+func (n *planNode) write(sb *strings.Builder, depth int) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	sb.WriteString(n.desc)
+	if n.rows >= 0 {
+		fmt.Fprintf(sb, " (rows=%d)", int64(math.Ceil(n.rows)))
+	}
+	sb.WriteByte('\n')
+	for _, c := range n.child {
+		c.write(sb, depth+1)
+	}
+}
+
+// 输出语法树，每个节点一行：节点名和值，子节点缩进在下面
+func writeTree(sb *strings.Builder, node *SynatxTreeNode, depth int) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	sb.WriteString(node.Name)
+	if node.Name == "literal" || node.ValueType == ParamType {
+		sb.WriteString(" " + literalText(node))
+	} else if node.Value != nil {
+		fmt.Fprintf(sb, " %v", node.Value)
+	}
+	sb.WriteByte('\n')
+	for _, c := range node.Child {
+		writeTree(sb, c, depth+1)
+	}
+}
+
+// 执行explain，结果是Value中的文本
+func (db *DB) explain(root *SynatxTreeNode) (*Result, error) {
+	stmt := root.Child[0]
+	plan, err := db.planOf(stmt)
+	if err != nil {
+		return nil, fmt.Errorf("explain: %w", err)
+	}
+	var sb strings.Builder
+	sb.WriteString("syntax tree:\n")
+	writeTree(&sb, stmt, 1)
+	sb.WriteString("plan:\n")
+	plan.write(&sb, 1)
+	return &Result{Statement: root.Name, Value: sb.String()}, nil
+}
+
+// 语句的执行计划
+func (db *DB) planOf(stmt *SynatxTreeNode) (*planNode, error) {
+	switch stmt.Name {
+	case "select":
+		lp, err := db.logicalSelect(stmt)
+		if err != nil {
+			return nil, fmt.Errorf("select: %w", err)
+		}
+		op, err := db.physicalSelect(lp)
+		if err != nil {
+			return nil, fmt.Errorf("select: %w", err)
+		}
+		e := &explainer{}
+		if a := aggregatorOf(op); a != nil {
+			e.slots = append(append(e.slots, a.groups...), a.aggs...)
+		}
+		return e.node(op), nil
+	case "insert into":
+		t, err := db.catalog.Table(stmt.Value.(string))
+		if err != nil {
+			return nil, fmt.Errorf("insert into: %w", err)
+		}
+		n := float64(len(childNode(stmt, "values").Child))
+		plan := &planNode{desc: "insert into " + t.Name, rows: n}
+		for _, ix := range t.Indexes() {
+			plan.child = append(plan.child, &planNode{desc: "update index " + ix.Name, rows: n})
+		}
+		return plan, nil
+	case "update set", "delete from":
+		name := stmt.Name // 和执行时的错误相同：update: ...、delete from: ...
+		if name == "update set" {
+			name = "update"
+		}
+		t, op, err := db.matchRows(stmt.Value.(string), childNode(stmt, "where"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		scan := (&explainer{}).node(op)
+		plan := &planNode{desc: name + " " + t.Name, rows: scan.rows, child: []*planNode{scan}}
+		for _, ix := range t.Indexes() {
+			plan.child = append(plan.child, &planNode{desc: "update index " + ix.Name, rows: scan.rows})
+		}
+		return plan, nil
+	case "create table", "drop table", "create index", "drop index":
+		return &planNode{desc: fmt.Sprintf("%s %v", stmt.Name, stmt.Value), rows: -1}, nil
+	case "begin", "commit", "rollback":
+		return &planNode{desc: stmt.Name, rows: -1}, nil
+	}
+	// 关键字上的语句
+	if rng := childNode(stmt, "range"); stmt.Name == "find" && rng != nil {
+		r, limit := findRange(stmt, rng)
+		rows := float64(db.bt.Stats().Keys) * rangeSelectivity(r)
+		if limit >= 0 {
+			rows = math.Min(rows, float64(limit))
+		}
+		desc := "range scan on keys"
+		if p := childNode(rng, "prefix"); p != nil {
+			desc += " (prefix " + formatLiteral(p.Value) + ")"
+		} else {
+			desc += " (" + rangeText("key", r) + ")"
+		}
+		if r.Desc {
+			desc += " desc"
+		}
+		if limit >= 0 {
+			desc += fmt.Sprintf(" limit %d", limit)
+		}
+		return &planNode{desc: desc, rows: rows}, nil
+	}
+	key, err := getChildForName(stmt, "key")
+	if err != nil {
+		return nil, fmt.Errorf("%s error: %w", stmt.Name, err)
+	}
+	if stmt.Name == "find" {
+		return &planNode{desc: "point lookup on key " + formatLiteral(key), rows: 1}, nil
+	}
+	return &planNode{desc: stmt.Name + " on key " + formatLiteral(key), rows: 1}, nil
+}
+
+// 聚合之后的算子中的slot节点引用聚合结果中的列
+type explainer struct {
+	slots []*SynatxTreeNode // group by的表达式和聚合函数
+}
+
+// 执行计划中的聚合算子
+func aggregatorOf(op operator) *aggregator {
+	for {
+		switch o := op.(type) {
+		case *project:
+			op = o.input
+		case *limitRows:
+			op = o.input
+		case *sortRows:
+			op = o.input
+		case *filter:
+			op = o.input
+		case *hashAggregate:
+			return &o.aggregator
+		case *streamAggregate:
+			return &o.aggregator
+		default:
+			return nil
+		}
+	}
+}
+
+// 表达式的文本，slot节点换成原来的表达式
+func (e *explainer) expr(node *SynatxTreeNode) string {
+	return formatExpr(e.unslot(node))
+}
+
+func (e *explainer) unslot(node *SynatxTreeNode) *SynatxTreeNode {
+	if node.Name == "slot" {
+		return e.slots[node.Value.(int)]
+	}
+	out := *node
+	out.Child = make([]*SynatxTreeNode, len(node.Child))
+	for i, c := range node.Child {
+		out.Child[i] = e.unslot(c)
+	}
+	return &out
+}
+
+func (e *explainer) exprs(nodes []*SynatxTreeNode, sep string) string {
+	list := make([]string, len(nodes))
+	for i, n := range nodes {
+		list[i] = e.expr(n)
+	}
+	return strings.Join(list, sep)
+}
+
+// 算子的执行计划
+func (e *explainer) node(op operator) *planNode {
+	switch op := op.(type) {
+	case *pointLookup:
+		t := op.table
+		return &planNode{
+			desc: fmt.Sprintf("point lookup on %s (%s = %s)", t.Name, t.Columns[t.PK].Name, formatLiteral(op.key)),
+			rows: math.Min(1, float64(t.Len())),
+		}
+	case *tableScan:
+		return scanNode(op.table, op.r, op.full)
+	case *indexScan:
+		t := op.table
+		desc := fmt.Sprintf("index scan on %s using %s (%s)", t.Name, op.ix.Name, rangeText(t.Columns[op.ix.Column].Name, op.r))
+		return &planNode{desc: desc, rows: float64(t.Len()) * rangeSelectivity(op.r)}
+	case *filter:
+		in := e.node(op.input)
+		return &planNode{desc: "filter: " + e.exprs(op.conds, " and "), rows: in.rows * selectivity(op.conds), child: []*planNode{in}}
+	case *nestedLoopJoin:
+		l, r := e.node(op.left), e.node(op.right)
+		return e.join(&op.joinBase, "nested-loop", l.rows*r.rows*selectivity(op.conds), l, r)
+	case *indexJoin:
+		return e.indexJoin(op)
+	case *mergeJoin:
+		return e.mergeJoin(op)
+	case *hashAggregate:
+		return e.aggregate("hash aggregate", op.input, &op.aggregator)
+	case *streamAggregate:
+		return e.aggregate("stream aggregate", op.input, &op.aggregator)
+	case *sortRows:
+		in := e.node(op.input)
+		keys := make([]string, len(op.keys))
+		for i, k := range op.keys {
+			keys[i] = e.expr(k.expr)
+			if k.desc {
+				keys[i] += " desc"
+			}
+		}
+		return &planNode{desc: "sort by " + strings.Join(keys, ", "), rows: in.rows, child: []*planNode{in}}
+	case *limitRows:
+		in := e.node(op.input)
+		return &planNode{desc: fmt.Sprintf("limit %d", op.n), rows: math.Min(in.rows, math.Max(float64(op.n), 0)), child: []*planNode{in}}
+	case *project:
+		in := e.node(op.input)
+		return &planNode{desc: "project: " + e.exprs(op.exprs, ", "), rows: in.rows, child: []*planNode{in}}
+	}
+	return &planNode{desc: fmt.Sprintf("%T", op), rows: -1}
+}
+
+// 按主键扫描表
+func scanNode(t *catalog.Table, r index.ScanRange, full bool) *planNode {
+	n := float64(t.Len())
+	if full {
+		return &planNode{desc: "full scan on " + t.Name, rows: n}
+	}
+	desc := fmt.Sprintf("range scan on %s (%s)", t.Name, rangeText(t.Columns[t.PK].Name, r))
+	if r.Desc {
+		desc += " desc"
+	}
+	return &planNode{desc: desc, rows: n * rangeSelectivity(r)}
+}
+
+// 连接，rows是没有考虑left join时估计的行数
+func (e *explainer) join(j *joinBase, method string, rows float64, left, right *planNode) *planNode {
+	desc := method + " join"
+	if j.leftJoin {
+		desc = method + " left join"
+		rows = math.Max(rows, left.rows)
+	}
+	if len(j.conds) > 0 {
+		desc += " on " + e.exprs(j.conds, " and ")
+	}
+	return &planNode{desc: desc, rows: rows, child: []*planNode{left, right}}
+}
+
+// 右边的访问算子上再过滤rconds
+func (e *explainer) withConds(n *planNode, conds []*SynatxTreeNode) *planNode {
+	if len(conds) == 0 {
+		return n
+	}
+	return &planNode{desc: "filter: " + e.exprs(conds, " and "), rows: n.rows * selectivity(conds), child: []*planNode{n}}
+}
+
+// 对左边的每一行在右边查找，右边的行数是每次查找估计的行数
+func (e *explainer) indexJoin(j *indexJoin) *planNode {
+	l := e.node(j.left)
+	t := j.table
+	var right *planNode
+	if j.ix == nil {
+		desc := fmt.Sprintf("point lookup on %s (%s = %s)", t.Name, t.Columns[t.PK].Name, e.expr(j.key))
+		right = &planNode{desc: desc, rows: math.Min(1, float64(t.Len()))}
+	} else {
+		desc := fmt.Sprintf("index scan on %s using %s (%s = %s)", t.Name, j.ix.Name, t.Columns[j.ix.Column].Name, e.expr(j.key))
+		right = &planNode{desc: desc, rows: float64(t.Len()) * eqSel}
+	}
+	right = e.withConds(right, j.rconds)
+	// 用来查找的条件已经计算在右边的行数中
+	var rest []*SynatxTreeNode
+	for _, cond := range j.conds {
+		if cond.Name != "binary" || cond.Value != "=" || (cond.Child[0] != j.key && cond.Child[1] != j.key) {
+			rest = append(rest, cond)
+		}
+	}
+	return e.join(&j.joinBase, "index nested-loop", l.rows*right.rows*selectivity(rest), l, right)
+}
+
+// 左边的每一行最多和右边的一行连接
+func (e *explainer) mergeJoin(j *mergeJoin) *planNode {
+	l := e.node(j.left)
+	right := e.withConds(scanNode(j.table, j.r, j.r.Lo == nil && j.r.Hi == nil), j.rconds)
+	match := 0.0 // 左边的一行在右边找到的概率
+	if n := float64(j.table.Len()); n > 0 {
+		match = right.rows / n
+	}
+	var rest []*SynatxTreeNode
+	for _, cond := range j.conds {
+		if !j.isKey(cond) {
+			rest = append(rest, cond)
+		}
+	}
+	return e.join(&j.joinBase, "merge", l.rows*match*selectivity(rest), l, right)
+}
+
+// cond是否是 左边的连接列 = 右边的主键
+func (j *mergeJoin) isKey(cond *SynatxTreeNode) bool {
+	if cond.Name != "binary" || cond.Value != "=" {
+		return false
+	}
+	for _, c := range cond.Child {
+		if c.Name == "ident" {
+			if i, err := j.ctx.scope.lookup(c.Value.(string)); err == nil && i == j.leftKey {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 没有group by时输出一行，否则按每组平均10行估计
+func (e *explainer) aggregate(method string, input operator, a *aggregator) *planNode {
+	in := e.node(input)
+	desc, rows := method, 1.0
+	// 同一个聚合函数出现多次时只显示一次
+	var aggs []string
+	seen := make(map[string]bool)
+	for _, agg := range a.aggs {
+		if s := e.expr(agg); !seen[s] {
+			seen[s] = true
+			aggs = append(aggs, s)
+		}
+	}
+	if len(aggs) > 0 {
+		desc += ": " + strings.Join(aggs, ", ")
+	}
+	if len(a.groups) > 0 {
+		desc += " group by " + e.exprs(a.groups, ", ")
+		rows = math.Min(in.rows, math.Max(1, in.rows*eqSel))
+	}
+	return &planNode{desc: desc, rows: rows, child: []*planNode{in}}
+}
+
+// 所有的条件都满足的比例
+func selectivity(conds []*SynatxTreeNode) float64 {
+	sel := 1.0
+	for _, cond := range conds {
+		sel *= condSelectivity(cond)
+	}
+	return sel
+}
+
+func condSelectivity(cond *SynatxTreeNode) float64 {
+	switch cond.Name {
+	case "binary":
+		switch cond.Value {
+		case "=":
+			return eqSel
+		case "<", "<=", ">", ">=":
+			return rangeSel
+		case "and":
+			return condSelectivity(cond.Child[0]) * condSelectivity(cond.Child[1])
+		case "or":
+			l, r := condSelectivity(cond.Child[0]), condSelectivity(cond.Child[1])
+			return l + r - l*r
+		}
+	case "unary":
+		if cond.Value == "not" {
+			return 1 - condSelectivity(cond.Child[0])
+		}
+	}
+	return defaultSel
+}
+
+// 范围内的行所占的比例
+func rangeSelectivity(r index.ScanRange) float64 {
+	if r.Lo != nil && r.Lo == r.Hi && !r.LoOpen && !r.HiOpen {
+		return eqSel
+	}
+	sel := 1.0
+	if r.Lo != nil {
+		sel *= rangeSel
+	}
+	if r.Hi != nil {
+		sel *= rangeSel
+	}
+	return sel
+}
+
+// 范围的文本，如 id > 1 and id <= 3
+func rangeText(col string, r index.ScanRange) string {
+	if r.Lo != nil && r.Lo == r.Hi && !r.LoOpen && !r.HiOpen {
+		return col + " = " + formatLiteral(r.Lo)
+	}
+	var parts []string
+	if r.Lo != nil {
+		op := " >= "
+		if r.LoOpen {
+			op = " > "
+		}
+		parts = append(parts, col+op+formatLiteral(r.Lo))
+	}
+	if r.Hi != nil {
+		op := " <= "
+		if r.HiOpen {
+			op = " < "
+		}
+		parts = append(parts, col+op+formatLiteral(r.Hi))
+	}
+	if len(parts) == 0 {
+		return "all"
+	}
+	return strings.Join(parts, " and ")
+}
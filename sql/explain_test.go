@@ -0,0 +1,130 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// explain输出中plan:之后的部分
+func explainPlan(t *testing.T, db *DB, sql string) string {
+	ret, err := Exec(db, "explain "+sql)
+	if err != nil {
+		t.Fatalf("%s: %v", sql, err)
+	}
+	text := ret.Value.(string)
+	i := strings.Index(text, "plan:\n")
+	if ret.Statement != "explain" || !strings.HasPrefix(text, "syntax tree:\n") || i < 0 {
+		t.Fatalf("%s: %q", sql, text)
+	}
+	return text[i+len("plan:\n"):]
+}
+
+func TestExec_Explain(t *testing.T) {
+	db := joinDB(t)
+	if _, err := Exec(db, "create index idx_user on orders (user_id); create index idx_age on users (age)"); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"select name from users where id = 2": `
+  project: name (rows=1)
+    point lookup on users (id = 2) (rows=1)`,
+		"select * from orders where id > 10 and id <= 13 and amount > 2 order by id desc": `
+  project: orders.id, orders.user_id, orders.amount (rows=1)
+    filter: amount > 2 (rows=1)
+      range scan on orders (id > 10 and id <= 13) desc (rows=1)`,
+		"select name from users where age = 30 or name = 'x'": `
+  project: name (rows=1)
+    filter: age = 30 or name = 'x' (rows=1)
+      full scan on users (rows=3)`,
+		"select age, count(*) from users where age >= 20 group by age having count(*) > 1 limit 1": `
+  project: age, count(*) (rows=1)
+    limit 1 (rows=1)
+      filter: count(*) > 1 (rows=1)
+        stream aggregate: count(*) group by age (rows=1)
+          index scan on users using idx_age (age >= 20) (rows=1)`,
+		"select u.name, sum(o.amount) from users u left join orders o on o.user_id = u.id and o.amount > 1 group by u.name order by sum(o.amount) desc": `
+  project: u.name, sum(o.amount) (rows=1)
+    sort by sum(o.amount) desc (rows=1)
+      hash aggregate: sum(o.amount) group by u.name (rows=1)
+        index nested-loop left join on o.user_id = u.id (rows=3)
+          full scan on users (rows=3)
+          filter: o.amount > 1 (rows=1)
+            index scan on orders using idx_user (user_id = u.id) (rows=1)`,
+		"select 1 from users u join profiles p on u.id = p.id join orders o on o.id = p.id and o.amount < u.age": `
+  project: 1 (rows=1)
+    index nested-loop join on o.id = p.id and o.amount < u.age (rows=1)
+      merge join on u.id = p.id (rows=3)
+        full scan on users (rows=3)
+        full scan on profiles (rows=3)
+      point lookup on orders (id = p.id) (rows=1)`,
+		"select 1 from users u join orders o on o.amount = u.age": `
+  project: 1 (rows=2)
+    nested-loop join on o.amount = u.age (rows=2)
+      full scan on users (rows=3)
+      full scan on orders (rows=5)`,
+		"select id from users where age > 9.5 and age < 20.5 and age <> 15.5": `
+  project: id (rows=1)
+    filter: age <> 15.5 (rows=1)
+      index scan on users using idx_age (age >= 10 and age <= 20) (rows=1)`,
+		"select id from users where age = 20.5": `
+  project: id (rows=1)
+    filter: age = 20.5 (rows=1)
+      full scan on users (rows=3)`,
+		"insert into users values (4, 'dan', 50)": `
+  insert into users (rows=1)
+    update index idx_age (rows=1)`,
+		"update users set age = age + 1 where id = 2": `
+  update users (rows=1)
+    point lookup on users (id = 2) (rows=1)
+    update index idx_age (rows=1)`,
+		"delete from users where age > 20": `
+  delete from users (rows=1)
+    index scan on users using idx_age (age > 20) (rows=1)
+    update index idx_age (rows=1)`,
+		"create index idx_name on users (name)": `
+  create index idx_name`,
+		"find k":                  "\n  point lookup on key 'k' (rows=1)",
+		"incr k 2":                "\n  incr on key 'k' (rows=1)",
+		"find prefix 'a' limit 3": "\n  range scan on keys (prefix 'a') limit 3 (rows=0)",
+	}
+	for sql, want := range cases {
+		got := explainPlan(t, db, sql)
+		if got != want[1:]+"\n" {
+			t.Fatalf("%s: got\n%s\nwant%s", sql, got, want)
+		}
+	}
+	// 估计的行数和表的行数成比例
+	for i := 100; i < 400; i++ {
+		Exec(db, fmt.Sprintf("insert into orders values (%d, %d, 1.0)", i, i%7))
+	}
+	if got := explainPlan(t, db, "select id from orders where user_id > 1"); !strings.Contains(got, "index scan on orders using idx_user (user_id > 1) (rows=102)") {
+		t.Fatalf("estimate: %s", got)
+	}
+	// explain不执行语句
+	explainPlan(t, db, "insert into users values (5, 'eve', 50)")
+	if ret, _ := Exec(db, "select id from users where id = 5"); len(ret.Rows) != 0 {
+		t.Fatal("explain should not insert")
+	}
+	explainPlan(t, db, "delete from users")
+	if ret, _ := Exec(db, "select count(*) from users"); ret.Rows[0][0] != int64(3) {
+		t.Fatal("explain should not delete")
+	}
+	errs := map[string]string{
+		"explain select 1 from missing":               "explain: select: missing: table does not exist",
+		"explain select nope from users":              "explain: select: unknown column nope",
+		"explain insert into missing values (1)":      "explain: insert into: missing: table does not exist",
+		"explain delete from missing":                 "explain: delete from: missing: table does not exist",
+		"explain update users set age = 1 where nope": "explain: update: unknown column nope",
+	}
+	for sql, want := range errs {
+		if _, err := Exec(db, sql); err == nil || err.Error() != want {
+			t.Fatalf("%s: got %v, want %s", sql, err, want)
+		}
+	}
+	for _, sql := range []string{"explain", "explain explain find k", "explain find k k"} {
+		if _, err := Exec(db, sql); err == nil {
+			t.Fatalf("%s should fail", sql)
+		}
+	}
+}
@@ -0,0 +1,678 @@
+package sql
+
+import (
+	"HwyDB/index"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 表达式的文法（优先级从低到高）：
+//	expr    = and { "or" and }
+//	and     = not { "and" not }
+//	not     = "not" not | cmp
+//	cmp     = sum [ ("=" | "!=" | "<>" | "<" | "<=" | ">" | ">=") sum | "is" ["not"] "null" ]
+//	sum     = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/" | "%") unary }
+//	unary   = "-" unary | primary
+//	primary = literal | identifier | identifier "(" [ expr { "," expr } ] ")" | "count" "(" "*" ")" | "(" expr ")"
+// 表达式中的标识符表示这个关键字当前的value（在select等语句中表示列），函数名不区分大小写
+// 比较、逻辑运算按照SQL的三值逻辑处理null
+//
+// 语法树节点：
+//	literal  Value是值
+//	ident    Value是关键字（或者列名）
+//	unary    Value是运算符（-、not、is null、is not null），Child是操作数
+//	binary   Value是运算符，Child是左右两个操作数
+//	call     Value是函数名（小写），Child是参数
+//	aggregate  Value是聚合函数名（小写），Child是参数（count(*)没有参数）
+//	slot     Value是当前行中列的序号（只在执行select时由其它节点改写得到）
+
+// 二元运算符的优先级，数字越大越先计算
+var binaryPrec = map[string]int{
+	"or":  1,
+	"and": 2,
+	"=":   4,
+	"!=":  4,
+	"<>":  4,
+	"<":   4,
+	"<=":  4,
+	">":   4,
+	">=":  4,
+	"is":  4,
+	"+":   5,
+	"-":   5,
+	"*":   6,
+	"/":   6,
+	"%":   6,
+}
+
+const notPrec = 3 // not的优先级
+
+func exprParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	return binaryParser(tr, 1)
+}
+
+// 二元运算符：特殊符号或者and、or、is（上下文关键字），返回运算符（小写）
+func binaryOp(t *token) (string, bool) {
+	op := t.lit
+	switch t.typ {
+	case Symbol:
+	case KeyWord, Identifier:
+		op = strings.ToLower(op)
+	default:
+		return "", false
+	}
+	_, ok := binaryPrec[op]
+	return op, ok
+}
+
+// 解析优先级不低于prec的二元运算
+func binaryParser(tr *TokenReader, prec int) (*SynatxTreeNode, error) {
+	var left *SynatxTreeNode
+	var err error
+	if t := tr.peek(); isWord(t, "not") && prec <= notPrec {
+		tr.read()
+		x, err := binaryParser(tr, notPrec)
+		if err != nil {
+			return nil, err
+		}
+		left = &SynatxTreeNode{Name: "unary", Value: "not", Pos: t.pos, Child: []*SynatxTreeNode{x}}
+	} else if left, err = unaryParser(tr); err != nil {
+		return nil, err
+	}
+	for {
+		t := tr.peek()
+		op, ok := binaryOp(t)
+		if !ok || binaryPrec[op] < prec {
+			return left, nil
+		}
+		p := binaryPrec[op]
+		tr.read()
+		if op == "is" { // is [not] null
+			op := "is null"
+			if n := tr.peek(); isWord(n, "not") {
+				tr.read()
+				op = "is not null"
+			}
+			if n := tr.read(); n.typ != Null {
+				return nil, tr.unexpected(n)
+			}
+			left = &SynatxTreeNode{Name: "unary", Value: op, Pos: t.pos, Child: []*SynatxTreeNode{left}}
+			continue
+		}
+		right, err := binaryParser(tr, p+1) // 左结合
+		if err != nil {
+			return nil, err
+		}
+		left = &SynatxTreeNode{Name: "binary", Value: op, Pos: t.pos, Child: []*SynatxTreeNode{left, right}}
+	}
+}
+
+func unaryParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	t := tr.peek()
+	if t.typ != Symbol || t.lit != "-" {
+		return primaryParser(tr)
+	}
+	tr.read()
+	if n := tr.peek(); n.typ == Num { // 负数直接作为字面量
+		tr.read()
+		return negNum(tr, t, n)
+	}
+	x, err := unaryParser(tr)
+	if err != nil {
+		return nil, err
+	}
+	return &SynatxTreeNode{Name: "unary", Value: "-", Pos: t.pos, Child: []*SynatxTreeNode{x}}, nil
+}
+
+func primaryParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	t := tr.read()
+	switch {
+	case t.typ == Paren && t.lit == "(":
+		x, err := exprParser(tr)
+		if err != nil {
+			return nil, err
+		}
+		if end := tr.read(); end.typ != Paren || end.lit != ")" {
+			return nil, tr.unexpected(end)
+		}
+		return x, nil
+	case t.typ == Identifier:
+		if p := tr.peek(); p.typ == Paren && p.lit == "(" {
+			return callParser(tr, t)
+		}
+		return &SynatxTreeNode{Name: "ident", Value: t.lit, Pos: t.pos}, nil
+	}
+	return literalNode(tr, "literal", t)
+}
+
+// 函数调用，函数名已经读过
+func callParser(tr *TokenReader, name *token) (*SynatxTreeNode, error) {
+	fn := strings.ToLower(name.lit)
+	node := &SynatxTreeNode{Name: "call", Value: fn, Pos: name.pos}
+	if _, ok := aggregates[fn]; ok {
+		return aggregateParser(tr, node)
+	}
+	if _, ok := functions[fn]; !ok {
+		return nil, tr.errorf(name, "unknown function: %s", name.lit)
+	}
+	tr.read() // (
+	if t := tr.peek(); t.typ == Paren && t.lit == ")" {
+		tr.read()
+		return node, nil
+	}
+	for {
+		arg, err := exprParser(tr)
+		if err != nil {
+			return nil, err
+		}
+		node.Child = append(node.Child, arg)
+		switch t := tr.read(); {
+		case t.typ == Paren && t.lit == ")":
+			return node, nil
+		case t.typ != Symbol || t.lit != ",":
+			return nil, tr.unexpected(t)
+		}
+	}
+}
+
+// 聚合函数只有一个参数，count可以是count(*)
+func aggregateParser(tr *TokenReader, node *SynatxTreeNode) (*SynatxTreeNode, error) {
+	node.Name = "aggregate"
+	tr.read() // (
+	if t := tr.peek(); t.typ == Symbol && t.lit == "*" && node.Value == "count" {
+		tr.read()
+	} else {
+		arg, err := exprParser(tr)
+		if err != nil {
+			return nil, err
+		}
+		node.Child = []*SynatxTreeNode{arg}
+	}
+	if err := expectParen(tr, ")"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// 负号和之后的数字合成一个字面量（这样最小的int64也能表示）
+func negNum(tr *TokenReader, minus, n *token) (*SynatxTreeNode, error) {
+	return literalNode(tr, "literal", &token{typ: Num, lit: "-" + n.lit, pos: minus.pos, off: minus.off})
+}
+
+// ----------- 计算表达式 ---------------
+
+// 计算表达式时的环境
+type evalContext struct {
+	bt    index.BT
+	now   time.Time
+	scope *scope        // 不为nil时标识符表示row中的列
+	row   []interface{} // 当前的一行
+}
+
+func (ctx *evalContext) eval(node *SynatxTreeNode) (interface{}, error) {
+	switch node.Name {
+	case "literal":
+		return node.Value, nil
+	case "slot":
+		return ctx.row[node.Value.(int)], nil
+	case "aggregate":
+		return nil, fmt.Errorf("aggregate function %s() is not allowed here", node.Value)
+	case "ident":
+		if ctx.scope != nil {
+			i, err := ctx.scope.lookup(node.Value.(string))
+			if err != nil {
+				return nil, err
+			}
+			return ctx.row[i], nil
+		}
+		v, ok := ctx.bt.Get(node.Value)
+		if !ok {
+			return nil, fmt.Errorf("key %v not found", node.Value)
+		}
+		return v, nil
+	case "unary":
+		x, err := ctx.eval(node.Child[0])
+		if err != nil {
+			return nil, err
+		}
+		return unary(node.Value.(string), x)
+	case "binary":
+		op := node.Value.(string)
+		l, err := ctx.eval(node.Child[0])
+		if err != nil {
+			return nil, err
+		}
+		// and、or的短路求值
+		if lb, ok := l.(bool); ok && ((op == "and" && !lb) || (op == "or" && lb)) {
+			return lb, nil
+		}
+		r, err := ctx.eval(node.Child[1])
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "and", "or":
+			return logic(op, l, r)
+		case "=", "!=", "<>", "<", "<=", ">", ">=":
+			return compareOp(op, l, r)
+		}
+		return arith(op, l, r)
+	case "call":
+		args := make([]interface{}, len(node.Child))
+		for i, c := range node.Child {
+			v, err := ctx.eval(c)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		return functions[node.Value.(string)](ctx, args)
+	}
+	return nil, errors.New("unknown expression: " + node.Name)
+}
+
+// 计算条件，null按false处理
+func (ctx *evalContext) test(node *SynatxTreeNode) (bool, error) {
+	v, err := ctx.eval(node)
+	if err != nil || v == nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("condition must be bool, got %T", v)
+	}
+	return b, nil
+}
+
+func unary(op string, x interface{}) (interface{}, error) {
+	switch op {
+	case "is null":
+		return x == nil, nil
+	case "is not null":
+		return x != nil, nil
+	}
+	if x == nil {
+		return nil, nil
+	}
+	if op == "not" {
+		b, ok := x.(bool)
+		if !ok {
+			return nil, fmt.Errorf("cannot apply not to %T", x)
+		}
+		return !b, nil
+	}
+	switch x := number(x).(type) {
+	case int64:
+		if x == math.MinInt64 {
+			return nil, fmt.Errorf("integer overflow: -(%d)", x)
+		}
+		return -x, nil
+	case float64:
+		return -x, nil
+	}
+	return nil, fmt.Errorf("cannot apply - to %T", x)
+}
+
+// and、or：false and null = false，true or null = true，其它有null时结果是null
+func logic(op string, l, r interface{}) (interface{}, error) {
+	lb, lok := l.(bool)
+	rb, rok := r.(bool)
+	if (l != nil && !lok) || (r != nil && !rok) {
+		return nil, fmt.Errorf("cannot apply %s to %T and %T", op, l, r)
+	}
+	if op == "and" {
+		if (lok && !lb) || (rok && !rb) {
+			return false, nil
+		}
+	} else if (lok && lb) || (rok && rb) {
+		return true, nil
+	}
+	if !lok || !rok {
+		return nil, nil
+	}
+	return op == "and", nil
+}
+
+// 比较运算，有null时结果是null
+func compareOp(op string, l, r interface{}) (interface{}, error) {
+	if l == nil || r == nil {
+		return nil, nil
+	}
+	c, err := compareValues(l, r)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "=":
+		return c == 0, nil
+	case "!=", "<>":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	}
+	return c >= 0, nil
+}
+
+// 比较两个非null的值：数字之间按数值比较，字符串、bool只能和同类型比较
+func compareValues(l, r interface{}) (int, error) {
+	l, r = number(l), number(r)
+	if li, ok := l.(int64); ok {
+		if ri, ok := r.(int64); ok {
+			return cmpOrdered(li < ri, li > ri), nil
+		}
+	}
+	if lf, ok := toFloat(l); ok {
+		if rf, ok := toFloat(r); ok {
+			return cmpOrdered(lf < rf, lf > rf), nil
+		}
+	}
+	switch l := l.(type) {
+	case string:
+		if r, ok := r.(string); ok {
+			return strings.Compare(l, r), nil
+		}
+	case bool:
+		if r, ok := r.(bool); ok {
+			return cmpOrdered(!l && r, l && !r), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %T and %T", l, r)
+}
+
+func cmpOrdered(less, greater bool) int {
+	if less {
+		return -1
+	}
+	if greater {
+		return 1
+	}
+	return 0
+}
+
+// 把各种整数、浮点数统一成int64、float64，其它类型不变
+func number(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	}
+	return v
+}
+
+// 四则运算：整数之间的结果还是整数，有浮点数时按浮点数计算，有null时结果是null
+func arith(op string, l, r interface{}) (interface{}, error) {
+	if l == nil || r == nil {
+		return nil, nil
+	}
+	l, r = number(l), number(r)
+	li, lok := l.(int64)
+	ri, rok := r.(int64)
+	if lok && rok {
+		switch op {
+		case "+", "-", "*":
+			v, ok := intArith(op, li, ri)
+			if !ok {
+				return nil, fmt.Errorf("integer overflow: %d %s %d", li, op, ri)
+			}
+			return v, nil
+		case "/", "%":
+			if ri == 0 {
+				return nil, errors.New("division by zero")
+			}
+			if op == "/" && ri == -1 && li == math.MinInt64 {
+				return nil, fmt.Errorf("integer overflow: %d / %d", li, ri)
+			}
+			if op == "/" {
+				return li / ri, nil
+			}
+			return li % ri, nil
+		}
+	}
+	lf, lok := toFloat(l)
+	rf, rok := toFloat(r)
+	if !lok || !rok {
+		return nil, fmt.Errorf("cannot apply %s to %T and %T", op, l, r)
+	}
+	switch op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return lf / rf, nil
+	case "%":
+		if rf == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return math.Mod(lf, rf), nil
+	}
+	return nil, errors.New("unknown operator: " + op)
+}
+
+// 整数的加减乘，溢出时返回false
+func intArith(op string, l, r int64) (int64, bool) {
+	switch op {
+	case "+":
+		v := l + r
+		return v, (v > l) == (r > 0)
+	case "-":
+		v := l - r
+		return v, (v < l) == (r > 0)
+	case "*":
+		if l == 0 || r == 0 {
+			return 0, true
+		}
+		v := l * r
+		// MinInt64 * -1的结果还是MinInt64，除法检查不出来
+		return v, v/r == l && !(r == -1 && l == math.MinInt64)
+	}
+	return 0, false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// ----------- 内置函数 ---------------
+
+type function func(ctx *evalContext, args []interface{}) (interface{}, error)
+
+var functions = map[string]function{
+	"len":    fnLen,
+	"upper":  stringFunc("upper", strings.ToUpper),
+	"lower":  stringFunc("lower", strings.ToLower),
+	"concat": fnConcat,
+	"now":    fnNow,
+	"abs":    fnAbs,
+}
+
+func checkArgs(name string, args []interface{}, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("%s() takes %d argument(s), got %d", name, n, len(args))
+	}
+	return nil
+}
+
+// len(s)：字符串的字符个数，[]byte的字节数
+func fnLen(ctx *evalContext, args []interface{}) (interface{}, error) {
+	if err := checkArgs("len", args, 1); err != nil {
+		return nil, err
+	}
+	switch v := args[0].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return int64(utf8.RuneCountInString(v)), nil
+	case []byte:
+		return int64(len(v)), nil
+	}
+	return nil, fmt.Errorf("len() needs a string, got %T", args[0])
+}
+
+func stringFunc(name string, f func(string) string) function {
+	return func(ctx *evalContext, args []interface{}) (interface{}, error) {
+		if err := checkArgs(name, args, 1); err != nil {
+			return nil, err
+		}
+		switch v := args[0].(type) {
+		case nil:
+			return nil, nil
+		case string:
+			return f(v), nil
+		}
+		return nil, fmt.Errorf("%s() needs a string, got %T", name, args[0])
+	}
+}
+
+// concat(a, b, ...)：把参数按字符串拼接起来，忽略null
+func fnConcat(ctx *evalContext, args []interface{}) (interface{}, error) {
+	var sb strings.Builder
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case nil:
+		case string:
+			sb.WriteString(v)
+		case []byte:
+			sb.Write(v)
+		default:
+			fmt.Fprint(&sb, v)
+		}
+	}
+	return sb.String(), nil
+}
+
+// now()：当前的Unix时间（秒），同一条语句中的now()相同
+func fnNow(ctx *evalContext, args []interface{}) (interface{}, error) {
+	if err := checkArgs("now", args, 0); err != nil {
+		return nil, err
+	}
+	return ctx.now.Unix(), nil
+}
+
+func fnAbs(ctx *evalContext, args []interface{}) (interface{}, error) {
+	if err := checkArgs("abs", args, 1); err != nil {
+		return nil, err
+	}
+	switch v := number(args[0]).(type) {
+	case nil:
+		return nil, nil
+	case int64:
+		if v == math.MinInt64 {
+			return nil, fmt.Errorf("abs(): integer overflow: %d", v)
+		}
+		if v < 0 {
+			return -v, nil
+		}
+		return v, nil
+	case float64:
+		return math.Abs(v), nil
+	}
+	return nil, fmt.Errorf("abs() needs a number, got %T", args[0])
+}
+
+// ----------- 表达式的文本 ---------------
+
+// 把表达式转换成可以再解析的文本，只在需要的地方加括号
+func formatExpr(node *SynatxTreeNode) string {
+	return formatPrec(node, 0)
+}
+
+// prec是外层运算符的优先级，node的优先级更低时加括号
+func formatPrec(node *SynatxTreeNode, prec int) string {
+	paren := func(s string, p int) string {
+		if p < prec {
+			return "(" + s + ")"
+		}
+		return s
+	}
+	switch node.Name {
+	case "literal":
+		return literalText(node)
+	case "ident":
+		return fmt.Sprint(node.Value)
+	case "slot":
+		return fmt.Sprintf("#%v", node.Value)
+	case "unary":
+		switch op := node.Value.(string); op {
+		case "-":
+			x := formatPrec(node.Child[0], binaryPrec["*"]+1)
+			if strings.HasPrefix(x, "-") { // --是注释
+				x = "(" + x + ")"
+			}
+			return "-" + x
+		case "not":
+			return paren("not "+formatPrec(node.Child[0], notPrec), notPrec)
+		default: // is null、is not null
+			return paren(formatPrec(node.Child[0], binaryPrec["is"]+1)+" "+op, binaryPrec["is"])
+		}
+	case "binary":
+		op := node.Value.(string)
+		p := binaryPrec[op]
+		return paren(formatPrec(node.Child[0], p)+" "+op+" "+formatPrec(node.Child[1], p+1), p)
+	case "call", "aggregate":
+		if node.Name == "aggregate" && len(node.Child) == 0 {
+			return "count(*)"
+		}
+		args := make([]string, len(node.Child))
+		for i, c := range node.Child {
+			args[i] = formatExpr(c)
+		}
+		return fmt.Sprintf("%v(%s)", node.Value, strings.Join(args, ", "))
+	}
+	return node.Name
+}
+
+// 字面量节点的文本，占位符统一输出成$n
+func literalText(node *SynatxTreeNode) string {
+	if node.ValueType == ParamType {
+		return fmt.Sprintf("$%d", node.Value.(int)+1)
+	}
+	return formatLiteral(node.Value)
+}
+
+var literalEscaper = strings.NewReplacer(`\`, `\\`, "'", "''", "\n", `\n`, "\t", `\t`, "\r", `\r`, "\x00", `\0`)
+
+// 字面量的文本：字符串加上引号并转义，浮点数总是带小数点或者指数
+func formatLiteral(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "'" + literalEscaper.Replace(v) + "'"
+	case float64:
+		s := strings.Replace(strconv.FormatFloat(v, 'g', -1, 64), "e+", "e", 1)
+		if !strings.ContainsAny(s, ".eIN") {
+			s += ".0"
+		}
+		return s
+	}
+	return fmt.Sprint(v)
+}
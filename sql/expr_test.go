@@ -0,0 +1,156 @@
+package sql
+
+import (
+	"HwyDB/index"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExec_Expr(t *testing.T) {
+	db := Open(index.New(3))
+	if _, err := Exec(db, "insert counter 1; insert price 2.5; insert name 'hwy'; insert nothing null"); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		expr  string
+		value interface{}
+	}{
+		{"1 + 2 * 3", int64(7)},
+		{"(1 + 2) * 3", int64(9)},
+		{"10 - 4 - 3", int64(3)},
+		{"7 / 2", int64(3)},
+		{"7 % 4", int64(3)},
+		{"7 / 2.0", 3.5},
+		{"-(2 + 3)", int64(-5)},
+		{"- -3", int64(3)},
+		{"2-1", int64(1)},
+		{"1e-1 * 10", 1.0},
+		{"-9223372036854775808", int64(-9223372036854775808)},
+		{"9223372036854775806 + 1", int64(9223372036854775807)},
+		{"-9223372036854775807 - 1", int64(-9223372036854775808)},
+		{"-4611686018427387904 * 2", int64(-9223372036854775808)},
+		{"-9223372036854775808 / 1", int64(-9223372036854775808)},
+		{"-9223372036854775808 % -1", int64(0)},
+		{"9223372036854775807 + 1.0", 9223372036854775808.0},
+		{"99999999999999999999.0", 1e20},
+		{"1e20", 1e20},
+		{"counter + 1", int64(2)},
+		{"counter * price", 2.5},
+		{"price % 1", 0.5},
+		{"nothing + 1", nil},
+		{"len('何惟禹')", int64(3)},
+		{"upper(name)", "HWY"},
+		{"LOWER('ABC')", "abc"},
+		{"concat(name, ':', counter, nothing, 1.5)", "hwy:11.5"},
+		{"concat()", ""},
+		{"abs(-3)", int64(3)},
+		{"abs(price - 10)", 7.5},
+		{"len(concat(upper(name), 'x')) + 1", int64(5)},
+		{"hello", "hello"}, // 单独的标识符还是字符串
+	}
+	for _, c := range cases {
+		if _, err := Exec(db, "insert x "+c.expr); err != nil {
+			t.Fatalf("%s: %v", c.expr, err)
+		}
+		ret, _ := Exec(db, "find x")
+		if ret.Value != c.value {
+			t.Fatalf("%s: got %#v, want %#v", c.expr, ret.Value, c.value)
+		}
+		Exec(db, "delete x")
+	}
+	// update中引用自己当前的值
+	for i := 0; i < 3; i++ {
+		if _, err := Exec(db, "update counter counter + 1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ret, _ := Exec(db, "find counter"); ret.Value != int64(4) {
+		t.Fatalf("counter: %v", ret.Value)
+	}
+	before := time.Now().Unix()
+	Exec(db, "insert created now()")
+	if ret, _ := Exec(db, "find created"); ret.Value.(int64) < before || ret.Value.(int64) > time.Now().Unix() {
+		t.Fatalf("now(): %v", ret.Value)
+	}
+}
+
+func TestExec_ExprError(t *testing.T) {
+	db := Open(index.New(3))
+	Exec(db, "insert name 'hwy'; insert ok true; insert big 9223372036854775807")
+	cases := []struct {
+		sql string
+		msg string
+	}{
+		{"insert x 1 / 0", "division by zero"},
+		{"insert x 1.5 % 0", "division by zero"},
+		{"insert x name + 1", "cannot apply + to string and int64"},
+		{"insert x -name", "cannot apply - to string"},
+		{"insert x ok * 2", "cannot apply * to bool and int64"},
+		{"insert x missing + 1", "key missing not found"},
+		{"insert x len(1)", "len() needs a string"},
+		{"insert x upper('a', 'b')", "upper() takes 1 argument(s), got 2"},
+		{"insert x abs('a')", "abs() needs a number"},
+		{"insert x now(1)", "now() takes 0 argument(s), got 1"},
+		{"update name name * 2", "cannot apply *"},
+		{"insert x 9223372036854775807 + 1", "integer overflow"},
+		{"insert x -9223372036854775808 - 1", "integer overflow"},
+		{"insert x 4611686018427387904 * 2", "integer overflow"},
+		{"insert x -9223372036854775808 * -1", "integer overflow"},
+		{"insert x -9223372036854775808 / -1", "integer overflow"},
+		{"insert x -(-9223372036854775808)", "integer overflow"},
+		{"insert x abs(-9223372036854775808)", "integer overflow"},
+		{"incr big", "integer overflow"},
+	}
+	for _, c := range cases {
+		_, err := Exec(db, c.sql)
+		if err == nil || !strings.Contains(err.Error(), c.msg) {
+			t.Fatalf("%s: got %v, want %q", c.sql, err, c.msg)
+		}
+	}
+	for _, sql := range []string{"insert x (1 + 2", "insert x 1 +", "insert x foo(1)", "insert x len(1,", "insert x 1 2", "insert x * 2"} {
+		_, err := Exec(db, sql)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Fatalf("%s: got %v, want SyntaxError", sql, err)
+		}
+	}
+	if ret, _ := Exec(db, "find x"); ret.Found {
+		t.Fatal("x should not be inserted")
+	}
+}
+
+func TestFormatExpr(t *testing.T) {
+	cases := map[string]string{
+		"1+2*3":                        "1 + 2 * 3",
+		"(1 + 2) * 3":                  "(1 + 2) * 3",
+		"10 - (4 - 3)":                 "10 - (4 - 3)",
+		"(10 - 4) - 3":                 "10 - 4 - 3",
+		"- -3":                         "-(-3)",
+		"-(a + b)":                     "-(a + b)",
+		"not (a = 1 or b is not null)": "not (a = 1 or b is not null)",
+		"not a = 1 and b":              "not a = 1 and b",
+		"(a or b) and c":               "(a or b) and c",
+		"(a + 1) is null":              "a + 1 is null",
+		"COUNT(*) + Sum(x)":            "count(*) + sum(x)",
+		`concat('it''s', 'a\\b')`:      `concat('it''s', 'a\\b')`,
+		"2.0 * 1e20 + 1.5e-7":          "2.0 * 1e20 + 1.5e-07",
+		"u.id = null":                  "u.id = null",
+	}
+	for expr, want := range cases {
+		node, err := exprParser(newLexReader(NewLex(expr)))
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		got := formatExpr(node)
+		if got != want {
+			t.Fatalf("%s: got %s, want %s", expr, got, want)
+		}
+		// 输出的文本解析之后是同一个表达式
+		again, err := exprParser(newLexReader(NewLex(got)))
+		if err != nil || formatExpr(again) != got {
+			t.Fatalf("%s: reparse %s: %v", expr, got, err)
+		}
+	}
+}
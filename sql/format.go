@@ -0,0 +1,204 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 把语法树转换回语句的文本：关键字小写，字符串加引号并转义，空白统一成一个空格
+// 输出的文本再解析得到相同的语法树（select中没有别名的计算列，列名变成规范化之后的表达式）
+// 注释不会保留；可以用于记录日志
+
+// 格式化query中的所有语句，每条语句一行，以;结尾；有语法错误时返回所有的错误
+func Format(query string) (string, error) {
+	roots, err := Parse(query)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, root := range roots {
+		sb.WriteString(root.String())
+		sb.WriteString(";\n")
+	}
+	return sb.String(), nil
+}
+
+// 语句的规范化文本，不是语句时按表达式输出
+func (n *SynatxTreeNode) String() string {
+	switch n.Name {
+	case "insert", "update", "delete", "incr", "decr", "append":
+		return strings.TrimSpace(n.Name + " " + literalText(childNode(n, "key")) + " " + valueText(childNode(n, "value")))
+	case "find":
+		return findText(n)
+	case "create table":
+		cols := make([]string, len(n.Child))
+		for i, c := range n.Child {
+			cols[i] = fmt.Sprintf("%v %v", c.Value, childNode(c, "type").Value)
+			if childNode(c, "primary key") != nil {
+				cols[i] += " primary key"
+			}
+		}
+		return fmt.Sprintf("create table %v (%s)", n.Value, strings.Join(cols, ", "))
+	case "create index":
+		on := childNode(n, "on")
+		return fmt.Sprintf("create index %v on %v (%v)", n.Value, on.Value, on.Child[0].Value)
+	case "drop table", "drop index":
+		return fmt.Sprintf("%s %v", n.Name, n.Value)
+	case "insert into":
+		return insertIntoText(n)
+	case "update set":
+		var sets []string
+		for _, c := range n.Child {
+			if c.Name == "set" {
+				sets = append(sets, fmt.Sprintf("%v = %s", c.Value, formatExpr(c.Child[0])))
+			}
+		}
+		return fmt.Sprintf("update %v set %s", n.Value, strings.Join(sets, ", ")) + whereText(n)
+	case "delete from":
+		return fmt.Sprintf("delete from %v", n.Value) + whereText(n)
+	case "select":
+		return selectText(n)
+	case "explain":
+		return "explain " + n.Child[0].String()
+	case "begin", "commit", "rollback":
+		return n.Name
+	}
+	return formatExpr(n)
+}
+
+// value节点的文本，没有value时返回空字符串
+func valueText(node *SynatxTreeNode) string {
+	if node == nil {
+		return ""
+	}
+	if len(node.Child) == 0 {
+		return literalText(node)
+	}
+	if node.Child[0].Name == "ident" { // 单独的标识符是字符串，(a)才表示关键字的value
+		return "(" + formatExpr(node.Child[0]) + ")"
+	}
+	return formatExpr(node.Child[0])
+}
+
+func findText(n *SynatxTreeNode) string {
+	rng := childNode(n, "range")
+	if rng == nil {
+		return "find " + literalText(childNode(n, "key"))
+	}
+	var sb strings.Builder
+	sb.WriteString("find ")
+	if lo, hi := childNode(rng, ">="), childNode(rng, "<="); lo != nil && hi != nil {
+		sb.WriteString("between " + literalText(lo) + " and " + literalText(hi))
+	} else {
+		c := rng.Child[0]
+		sb.WriteString(c.Name + " " + literalText(c))
+	}
+	if order := childNode(n, "order"); order != nil && order.Value == "desc" {
+		sb.WriteString(" order by key desc")
+	}
+	if limit := childNode(n, "limit"); limit != nil {
+		sb.WriteString(" limit " + literalText(limit))
+	}
+	return sb.String()
+}
+
+func insertIntoText(n *SynatxTreeNode) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "insert into %v ", n.Value)
+	if columns := childNode(n, "columns"); columns != nil {
+		names := make([]string, len(columns.Child))
+		for i, c := range columns.Child {
+			names[i] = c.Value.(string)
+		}
+		sb.WriteString("(" + strings.Join(names, ", ") + ") ")
+	}
+	sb.WriteString("values ")
+	for i, row := range childNode(n, "values").Child {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		values := make([]string, len(row.Child))
+		for j, v := range row.Child {
+			values[j] = valueText(v)
+		}
+		sb.WriteString("(" + strings.Join(values, ", ") + ")")
+	}
+	return sb.String()
+}
+
+// " where <条件>"，没有where时返回空字符串
+func whereText(n *SynatxTreeNode) string {
+	if where := childNode(n, "where"); where != nil {
+		return " where " + formatExpr(where.Child[0])
+	}
+	return ""
+}
+
+func selectText(n *SynatxTreeNode) string {
+	var sb strings.Builder
+	sb.WriteString("select ")
+	fields := childNode(n, "fields")
+	if fields.Value == "*" {
+		sb.WriteString("*")
+	}
+	for i, f := range fields.Child {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		expr := formatExpr(f.Child[0])
+		sb.WriteString(expr)
+		// 和默认的列名不同时加上别名
+		name := expr
+		if f.Child[0].Name == "ident" {
+			name = strings.ToLower(expr)
+		}
+		if alias := f.Value.(string); alias != name && isIdentText(alias) {
+			sb.WriteString(" as " + alias)
+		}
+	}
+	for _, c := range n.Child[1:] {
+		switch c.Name {
+		case "from":
+			sb.WriteString(" from " + tableRefText(c))
+		case "join":
+			if c.Value == "left" {
+				sb.WriteString(" left")
+			}
+			fmt.Fprintf(&sb, " join %s on %s", tableRefText(c.Child[0]), formatExpr(childNode(c, "on").Child[0]))
+		case "where", "having":
+			sb.WriteString(" " + c.Name + " " + formatExpr(c.Child[0]))
+		case "group by":
+			exprs := make([]string, len(c.Child))
+			for i, e := range c.Child {
+				exprs[i] = formatExpr(e)
+			}
+			sb.WriteString(" group by " + strings.Join(exprs, ", "))
+		case "order by":
+			items := make([]string, len(c.Child))
+			for i, item := range c.Child {
+				items[i] = formatExpr(item.Child[0])
+				if item.Value == "desc" {
+					items[i] += " desc"
+				}
+			}
+			sb.WriteString(" order by " + strings.Join(items, ", "))
+		case "limit":
+			sb.WriteString(" limit " + literalText(c))
+		}
+	}
+	return sb.String()
+}
+
+// 表名以及可能有的别名
+func tableRefText(n *SynatxTreeNode) string {
+	if as := childNode(n, "as"); as != nil {
+		return fmt.Sprintf("%v as %v", n.Value, as.Value)
+	}
+	return fmt.Sprint(n.Value)
+}
+
+// s能否作为一个标识符解析（不是关键字、true、false、null）
+func isIdentText(s string) bool {
+	lex := NewLex(s)
+	return lex.err == nil && len(lex.tokens) == 2 && lex.tokens[0].typ == Identifier && lex.tokens[0].lit == s
+}
@@ -0,0 +1,145 @@
+package sql
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	cases := map[string]string{
+		"INSERT age 29":                                         "insert 'age' 29;\n",
+		"insert  word hello; Find word;;":                       "insert 'word' 'hello';\nfind 'word';\n",
+		"update k (k); insert into t values ((a))":              "update 'k' (k);\ninsert into t values ((a));\n",
+		"update n -5":                                           "update 'n' -5;\n",
+		"incr counter; DECR c 2*3":                              "incr 'counter';\ndecr 'c' 2 * 3;\n",
+		"append s 'it''s\\n\"'":                                 `append 's' 'it''s\n"';` + "\n",
+		"find between 1 AND 10 Order By KEY desc limit 3":       "find between 1 and 10 order by key desc limit 3;\n",
+		"find >= 'a' order by key asc; find prefix u":           "find >= 'a';\nfind prefix 'u';\n",
+		"create table T (ID int PRIMARY KEY, name String)":      "create table t (id int primary key, name string);\n",
+		"create index I on t(name); drop index i; drop table t": "create index i on t (name);\ndrop index i;\ndrop table t;\n",
+		"insert into t(id,name) values(1,'a'),(2, b)":           "insert into t (id, name) values (1, 'a'), (2, 'b');\n",
+		"select * from users":                                   "select * from users;\n",
+		"UPDATE users SET Age=age+1,name='x' WHERE id>1":        "update users set age = age + 1, name = 'x' where id > 1;\n",
+		"delete from users; Delete FROM users where not vip":    "delete from users;\ndelete from users where not vip;\n",
+		"select Name, age+1, count(*) AS n, u.id as id from users u inner join orders as o on u.id=o.uid where (age>18 or x) and not y group by Name having count(*)>1 order by n desc, 2 asc limit 10": "select Name, age + 1, count(*) as n, u.id as id from users as u join orders as o on u.id = o.uid where (age > 18 or x) and not y group by Name having count(*) > 1 order by n desc, 2 limit 10;\n",
+		"select a from t left outer join u on a = b": "select a from t left join u on a = b;\n",
+		"explain find ?; begin; commit; rollback":    "explain find $1;\nbegin;\ncommit;\nrollback;\n",
+		"  -- 只有注释\n":                                "",
+	}
+	for query, want := range cases {
+		got, err := Format(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if got != want {
+			t.Fatalf("%s:\ngot  %q\nwant %q", query, got, want)
+		}
+	}
+	for _, query := range []string{"insert k", "find 1; select from t", "insert 'a"} {
+		if got, err := Format(query); err == nil {
+			t.Fatalf("%s: should fail, got %q", query, got)
+		}
+	}
+}
+
+// 随机生成的语句：格式化之后再解析得到相同的语法树，再格式化得到相同的文本
+func TestFormat_RoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(49))
+	templates := []string{
+		"insert k %s",
+		"update 'k' %s",
+		"incr k %s",
+		"insert into t (a, b) values (%s, %s), (1, x)",
+		"update t set a = %s, b = %s where %s",
+		"delete from t where %s",
+		"select %s, %s as v from t as x left join u on %s where %s group by %s having %s order by %s desc, %s limit 3",
+	}
+	for i := 0; i < 2000; i++ {
+		tmpl := templates[i%len(templates)]
+		args := make([]interface{}, strings.Count(tmpl, "%s"))
+		for j := range args {
+			args[j] = randExpr(r, 4)
+		}
+		query := fmt.Sprintf(tmpl, args...)
+		root, err := parseOne(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		text := root.String()
+		again, err := parseOne(text)
+		if err != nil {
+			t.Fatalf("%s\nformatted %s: %v", query, text, err)
+		}
+		if !sameTree(root, again) {
+			t.Fatalf("%s\nformatted %s: different syntax tree", query, text)
+		}
+		if again.String() != text {
+			t.Fatalf("%s\nformatted %s\nagain     %s", query, text, again.String())
+		}
+	}
+}
+
+func parseOne(query string) (*SynatxTreeNode, error) {
+	lex := NewLex(query)
+	if lex.err != nil {
+		return nil, lex.err
+	}
+	return newLexReader(lex).buildAST()
+}
+
+// 比较两棵语法树，不比较位置以及field的列名（没有别名时是原文）
+func sameTree(a, b *SynatxTreeNode) bool {
+	if a.Name != b.Name || a.ValueType != b.ValueType || len(a.Child) != len(b.Child) {
+		return false
+	}
+	if a.Name != "field" && !reflect.DeepEqual(a.Value, b.Value) {
+		return false
+	}
+	for i := range a.Child {
+		if !sameTree(a.Child[i], b.Child[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+var randStrings = []string{"''", "'it''s'", `'a\\b'`, `'\n\t'`, `"x'y"`, "'中文'", "'--'"}
+
+// 随机的表达式，depth是最大的深度
+func randExpr(r *rand.Rand, depth int) string {
+	if depth == 0 || r.Intn(4) == 0 {
+		switch r.Intn(6) {
+		case 0:
+			return fmt.Sprintf("%d", r.Intn(100))
+		case 1:
+			return []string{"1.5", "2e10", "0.25", "1e-3"}[r.Intn(4)]
+		case 2:
+			return randStrings[r.Intn(len(randStrings))]
+		case 3:
+			return []string{"true", "false", "null"}[r.Intn(3)]
+		case 4:
+			return fmt.Sprintf("$%d", r.Intn(3)+1)
+		}
+		return []string{"a", "b", "t.c", "Age"}[r.Intn(4)]
+	}
+	x := randExpr(r, depth-1)
+	switch r.Intn(6) {
+	case 0:
+		return "- " + x
+	case 1:
+		return "(not " + x + ")"
+	case 2:
+		return "(" + x + []string{" is null", " is not null"}[r.Intn(2)] + ")"
+	case 3:
+		return []string{"abs", "upper", "len"}[r.Intn(3)] + "(" + x + ")"
+	}
+	ops := []string{"or", "and", "=", "!=", "<>", "<", "<=", ">", ">=", "+", "-", "*", "/", "%"}
+	s := x + " " + ops[r.Intn(len(ops))] + " " + randExpr(r, depth-1)
+	if r.Intn(2) == 0 {
+		s = "(" + s + ")"
+	}
+	return s
+}
@@ -0,0 +1,344 @@
+package sql
+
+import (
+	"HwyDB/catalog"
+	"HwyDB/index"
+	"time"
+)
+
+// 连接：from中的表按顺序依次和之前的结果连接（左深树），每次连接选择以下的一种方法：
+//	merge join         左边按连接的列有序（按主键或者索引扫描），右边是按主键顺序扫描的表，
+//	                   连接条件是 左边的列 = 右边的主键，两边都只需要遍历一次
+//	index nested-loop  连接条件是 左边的表达式 = 右边的主键（或者有索引的列），对左边的每一行在右边的B+树中查找
+//	nested-loop        其它情况，把右边的结果保存在内存中，和左边的每一行逐个比较
+// 条件尽量在连接之前过滤：只涉及一个表的where条件（left join右边的表除外）和left join中只涉及右边的on条件，
+// 在访问这个表时过滤；涉及多个表的条件在所有涉及的表都连接之后过滤
+
+// 条件中用到的表（第i位表示第i个表）
+func (lp *logicalPlan) tablesOf(node *SynatxTreeNode) uint64 {
+	if node.Name == "ident" {
+		col, _ := lp.scope.lookup(node.Value.(string))
+		for i := len(lp.sources) - 1; i >= 0; i-- {
+			if col >= lp.sources[i].offset {
+				return 1 << uint(i)
+			}
+		}
+	}
+	var mask uint64
+	for _, c := range node.Child {
+		mask |= lp.tablesOf(c)
+	}
+	return mask
+}
+
+// 最后一个用到的表
+func lastTable(mask uint64) int {
+	last := -1
+	for i := 0; mask != 0; i, mask = i+1, mask>>1 {
+		if mask&1 != 0 {
+			last = i
+		}
+	}
+	return last
+}
+
+// 把where和on中的条件放到尽量早的位置：pushed[i]在访问第i个表时过滤，
+// joins[i]在和第i个表连接时判断，top在所有的表连接之后过滤
+func (lp *logicalPlan) placeConds() (pushed, joins [][]*SynatxTreeNode, top []*SynatxTreeNode) {
+	pushed = make([][]*SynatxTreeNode, len(lp.sources))
+	joins = make([][]*SynatxTreeNode, len(lp.sources))
+	// inner join的on条件和where条件相同
+	place := func(cond *SynatxTreeNode) {
+		mask := lp.tablesOf(cond)
+		last := lastTable(mask)
+		switch {
+		case last < 0 || lp.sources[last].left:
+			top = append(top, cond)
+		case mask == 1<<uint(last):
+			pushed[last] = append(pushed[last], cond)
+		default:
+			joins[last] = append(joins[last], cond)
+		}
+	}
+	for _, cond := range lp.where {
+		place(cond)
+	}
+	for i, src := range lp.sources {
+		for _, cond := range src.on {
+			switch {
+			case !src.left:
+				place(cond)
+			case lp.tablesOf(cond)&^(1<<uint(i)) == 0: // 只涉及右边的表
+				pushed[i] = append(pushed[i], cond)
+			default:
+				joins[i] = append(joins[i], cond)
+			}
+		}
+	}
+	return pushed, joins, top
+}
+
+// 可以用来查找的连接条件：左边的表达式 = 右边的表的一列
+type equiJoin struct {
+	left *SynatxTreeNode
+	col  int // 右边的表中的列
+}
+
+// 和第i个表连接的条件中所有的等值条件
+func (lp *logicalPlan) equiJoins(i int, conds []*SynatxTreeNode) []equiJoin {
+	src := lp.sources[i]
+	var eqs []equiJoin
+	for _, cond := range conds {
+		if cond.Name != "binary" || cond.Value != "=" {
+			continue
+		}
+		for _, side := range [][2]*SynatxTreeNode{{cond.Child[0], cond.Child[1]}, {cond.Child[1], cond.Child[0]}} {
+			l, r := side[0], side[1]
+			mask := lp.tablesOf(l)
+			if r.Name != "ident" || lp.tablesOf(r) != 1<<uint(i) || mask == 0 || mask>>uint(i) != 0 {
+				continue
+			}
+			col, _ := src.scope.lookup(r.Value.(string))
+			eqs = append(eqs, equiJoin{left: l, col: col})
+		}
+	}
+	return eqs
+}
+
+// 依次连接所有的表
+func (db *DB) joinSources(lp *logicalPlan, pushed, joins [][]*SynatxTreeNode) (operator, error) {
+	first := lp.sources[0]
+	access, rest, _, err := db.accessPath(first, pushed[0], nil)
+	if err != nil {
+		return nil, err
+	}
+	op := db.withFilter(first, access, rest)
+	ordered := -1 // 左边按哪一列升序排列
+	if _, ok := access.(*pointLookup); !ok {
+		ordered = orderedBy(access)
+	}
+	for i := 1; i < len(lp.sources); i++ {
+		src := lp.sources[i]
+		base := joinBase{
+			left:     op,
+			width:    len(src.table.Columns),
+			leftJoin: src.left,
+			conds:    joins[i],
+			ctx:      &evalContext{bt: db.bt, now: time.Now(), scope: lp.scope},
+		}
+		rctx := &evalContext{bt: db.bt, now: time.Now(), scope: src.scope}
+		access, rest, _, err := db.accessPath(src, pushed[i], nil)
+		if err != nil {
+			return nil, err
+		}
+		op = nil
+		eqs := lp.equiJoins(i, joins[i])
+		if _, ok := access.(*pointLookup); ok { // 右边最多一行
+			eqs = nil
+		}
+		t := src.table
+		// 左边按连接的列有序，右边按主键扫描
+		for _, eq := range eqs {
+			scan, ok := access.(*tableScan)
+			if i != 1 || !ok || eq.col != t.PK || eq.left.Name != "ident" {
+				continue
+			}
+			if col, _ := first.scope.lookup(eq.left.Value.(string)); col == ordered && first.table.Columns[col].Type == t.Columns[t.PK].Type {
+				op = &mergeJoin{joinBase: base, leftKey: col, table: t, r: scan.r, rconds: rest, rctx: rctx}
+				break
+			}
+		}
+		// 在右边的主键或者索引上查找
+		for _, eq := range eqs {
+			if op != nil {
+				break
+			}
+			if eq.col == t.PK {
+				op = &indexJoin{joinBase: base, table: t, key: eq.left, rconds: pushed[i], rctx: rctx}
+			}
+		}
+		for _, eq := range eqs {
+			if op != nil {
+				break
+			}
+			if ix := t.IndexOn(eq.col); ix != nil {
+				op = &indexJoin{joinBase: base, table: t, ix: ix, key: eq.left, rconds: pushed[i], rctx: rctx}
+			}
+		}
+		if op == nil {
+			op = &nestedLoopJoin{joinBase: base, right: db.withFilter(src, access, rest)}
+		}
+	}
+	return op, nil
+}
+
+// ----------- 算子 ---------------
+
+// 连接的公共部分
+type joinBase struct {
+	left     operator
+	width    int               // 右边的表的列数
+	leftJoin bool              // 左边的行没有匹配时，输出右边都是null的一行
+	conds    []*SynatxTreeNode // 连接之后需要满足的条件
+	ctx      *evalContext
+}
+
+// 连接左右两行，返回是否满足条件
+func (j *joinBase) match(l, r []interface{}) ([]interface{}, bool, error) {
+	row := make([]interface{}, 0, len(l)+j.width)
+	row = append(append(row, l...), r...)
+	j.ctx.row = row
+	for _, cond := range j.conds {
+		if ok, err := j.ctx.test(cond); !ok || err != nil {
+			return nil, false, err
+		}
+	}
+	return row, true, nil
+}
+
+// 把左边的一行和rights中的每一行连接，输出满足条件的结果
+func (j *joinBase) emit(l []interface{}, rights [][]interface{}, fn func(row []interface{}) (bool, error)) (bool, error) {
+	matched := false
+	for _, r := range rights {
+		row, ok, err := j.match(l, r)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			continue
+		}
+		matched = true
+		if ok, err := fn(row); !ok || err != nil {
+			return false, err
+		}
+	}
+	if j.leftJoin && !matched {
+		return fn(append(append([]interface{}(nil), l...), make([]interface{}, j.width)...))
+	}
+	return true, nil
+}
+
+// 右边的行满足所有的条件
+func testAll(ctx *evalContext, conds []*SynatxTreeNode, row []interface{}) (bool, error) {
+	ctx.row = row
+	for _, cond := range conds {
+		if ok, err := ctx.test(cond); !ok || err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// 把右边的结果保存在内存中，和左边的每一行比较
+type nestedLoopJoin struct {
+	joinBase
+	right operator
+}
+
+func (j *nestedLoopJoin) run(fn func(row []interface{}) (bool, error)) error {
+	var rights [][]interface{}
+	err := j.right.run(func(row []interface{}) (bool, error) {
+		rights = append(rights, row)
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	return j.left.run(func(l []interface{}) (bool, error) {
+		return j.emit(l, rights, fn)
+	})
+}
+
+// 用左边的一行计算key，在右边的主键（ix为nil时）或者索引中查找
+type indexJoin struct {
+	joinBase
+	table  *catalog.Table
+	ix     *catalog.Index
+	key    *SynatxTreeNode
+	rconds []*SynatxTreeNode // 右边的表上的条件
+	rctx   *evalContext
+}
+
+func (j *indexJoin) run(fn func(row []interface{}) (bool, error)) error {
+	col := j.table.PK
+	if j.ix != nil {
+		col = j.ix.Column
+	}
+	typ := j.table.Columns[col].Type
+	return j.left.run(func(l []interface{}) (bool, error) {
+		j.ctx.row = l
+		v, err := j.ctx.eval(j.key)
+		if err != nil {
+			return false, err
+		}
+		var rights [][]interface{}
+		add := func(pk interface{}) error {
+			row, ok, err := j.table.Get(pk)
+			if err == nil && ok {
+				if ok, err = testAll(j.rctx, j.rconds, row); ok {
+					rights = append(rights, row)
+				}
+			}
+			return err
+		}
+		// 类型不同的值在B+树中找不到
+		if key, ok := keyValue(typ, v); ok {
+			if j.ix == nil {
+				err = add(key)
+			} else {
+				scanErr := j.ix.Scan(index.ScanRange{Lo: key, Hi: key}, func(pk interface{}) bool {
+					err = add(pk)
+					return err == nil
+				})
+				if err == nil {
+					err = scanErr
+				}
+			}
+			if err != nil {
+				return false, err
+			}
+		}
+		return j.emit(l, rights, fn)
+	})
+}
+
+// 左边按leftKey列升序排列，右边按主键的顺序扫描，右边的主键不重复
+type mergeJoin struct {
+	joinBase
+	leftKey int
+	table   *catalog.Table
+	r       index.ScanRange
+	rconds  []*SynatxTreeNode
+	rctx    *evalContext
+}
+
+func (j *mergeJoin) run(fn func(row []interface{}) (bool, error)) error {
+	cur := &rowCursor{table: j.table, r: j.r}
+	return j.left.run(func(l []interface{}) (bool, error) {
+		var rights [][]interface{}
+		for k := l[j.leftKey]; k != nil; cur.next() {
+			r, err := cur.peek()
+			if err != nil {
+				return false, err
+			}
+			if r == nil {
+				break
+			}
+			c, err := compareValues(r[j.table.PK], k)
+			if err != nil {
+				return false, err
+			}
+			if c < 0 {
+				continue
+			}
+			// 左边可能有相同的值，所以不移动右边
+			if ok, err := testAll(j.rctx, j.rconds, r); err != nil {
+				return false, err
+			} else if ok && c == 0 {
+				rights = append(rights, r)
+			}
+			break
+		}
+		return j.emit(l, rights, fn)
+	})
+}
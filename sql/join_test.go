@@ -0,0 +1,243 @@
+package sql
+
+import (
+	"HwyDB/index"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func joinDB(t *testing.T) *DB {
+	db := Open(index.New(3))
+	_, err := Exec(db, `create table users (id int primary key, name string, age int);
+		create table orders (id int primary key, user_id int, amount float);
+		create table profiles (id int primary key, city string);
+		insert into users values (1, 'ann', 30), (2, 'bob', 20), (3, 'cat', 40);
+		insert into orders values (10, 1, 5.0), (11, 1, 7.5), (12, 3, 1.0), (13, 9, 2.0), (14, null, 3.0);
+		insert into profiles values (1, 'sh'), (3, 'bj'), (4, 'gz')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestExec_Join(t *testing.T) {
+	db := joinDB(t)
+	cases := []struct {
+		sql     string
+		columns []string
+		rows    [][]interface{}
+	}{
+		{"select u.name, o.amount from users u join orders o on u.id = o.user_id order by o.id",
+			[]string{"u.name", "o.amount"},
+			[][]interface{}{{"ann", 5.0}, {"ann", 7.5}, {"cat", 1.0}}},
+		{"select users.name, amount from orders inner join users on orders.user_id = users.id where amount > 2",
+			[]string{"users.name", "amount"},
+			[][]interface{}{{"ann", 5.0}, {"ann", 7.5}}},
+		{"select name, o.id from users left outer join orders as o on o.user_id = users.id order by name, o.id",
+			[]string{"name", "o.id"},
+			[][]interface{}{{"ann", int64(10)}, {"ann", int64(11)}, {"bob", nil}, {"cat", int64(12)}}},
+		{"select name, o.id from users left join orders o on o.user_id = users.id and o.amount > 4 order by name",
+			[]string{"name", "o.id"},
+			[][]interface{}{{"ann", int64(10)}, {"ann", int64(11)}, {"bob", nil}, {"cat", nil}}},
+		{"select name from users left join orders o on o.user_id = users.id and users.age > 25 where o.id is null",
+			[]string{"name"},
+			[][]interface{}{{"bob"}}},
+		{"select * from users u join profiles p on u.id = p.id",
+			[]string{"id", "name", "age", "id", "city"},
+			[][]interface{}{{int64(1), "ann", int64(30), int64(1), "sh"}, {int64(3), "cat", int64(40), int64(3), "bj"}}},
+		{"select u.name, p.city, count(o.id), sum(o.amount) from users u left join profiles p on p.id = u.id " +
+			"left join orders o on o.user_id = u.id group by u.name, p.city order by u.name",
+			[]string{"u.name", "p.city", "count(o.id)", "sum(o.amount)"},
+			[][]interface{}{{"ann", "sh", int64(2), 12.5}, {"bob", nil, int64(0), nil}, {"cat", "bj", int64(1), 1.0}}},
+		{"select a.id, b.id from users a join users b on a.age < b.age order by a.id, b.id",
+			[]string{"a.id", "b.id"},
+			[][]interface{}{{int64(1), int64(3)}, {int64(2), int64(1)}, {int64(2), int64(3)}}},
+		{"select o.id from orders o join users u on u.id = o.user_id and u.id = 3",
+			[]string{"o.id"},
+			[][]interface{}{{int64(12)}}},
+		{"select u.id from users u join orders o on 1 = 1 where o.id = 14 order by u.id desc limit 2",
+			[]string{"u.id"},
+			[][]interface{}{{int64(3)}, {int64(2)}}},
+	}
+	for _, c := range cases {
+		ret, err := Exec(db, c.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", c.sql, err)
+		}
+		if !reflect.DeepEqual(ret.Columns, c.columns) || !reflect.DeepEqual(ret.Rows, c.rows) {
+			t.Fatalf("%s: got %v %v, want %v %v", c.sql, ret.Columns, ret.Rows, c.columns, c.rows)
+		}
+	}
+	errs := map[string]string{
+		"select id from users join orders on user_id = users.id":   "select: column id is ambiguous",
+		"select 1 from users join users on users.id = users.id":    "select: table name users specified more than once",
+		"select 1 from users u join orders o on users.id = o.id":   "select: unknown column users.id",
+		"select 1 from users u join orders o on count(*) = 1":      "select: aggregate functions are not allowed in on",
+		"select 1 from users u join missing m on u.id = m.id":      "select: missing: table does not exist",
+		"select 1 from users u join orders o on u.name = o.amount": "select: cannot compare string and float64",
+	}
+	for sql, want := range errs {
+		if _, err := Exec(db, sql); err == nil || err.Error() != want {
+			t.Fatalf("%s: got %v, want %s", sql, err, want)
+		}
+	}
+	for _, sql := range []string{
+		"select 1 from users join orders",
+		"select 1 from users left orders on 1 = 1",
+		"select 1 from users join on 1 = 1",
+		"select 1 from users u v",
+	} {
+		if _, err := Exec(db, sql); err == nil {
+			t.Fatalf("%s should fail", sql)
+		}
+	}
+}
+
+// 连接使用的方法，按连接的顺序
+func joinMethods(op operator) []string {
+	switch op := op.(type) {
+	case *project:
+		return joinMethods(op.input)
+	case *filter:
+		return joinMethods(op.input)
+	case *sortRows:
+		return joinMethods(op.input)
+	case *limitRows:
+		return joinMethods(op.input)
+	case *hashAggregate:
+		return joinMethods(op.input)
+	case *nestedLoopJoin:
+		return append(joinMethods(op.left), "nested-loop")
+	case *indexJoin:
+		if op.ix == nil {
+			return append(joinMethods(op.left), "index nested-loop (primary key)")
+		}
+		return append(joinMethods(op.left), "index nested-loop ("+op.ix.Name+")")
+	case *mergeJoin:
+		return append(joinMethods(op.left), "merge")
+	}
+	return nil
+}
+
+func TestSelect_JoinMethod(t *testing.T) {
+	db := joinDB(t)
+	if _, err := Exec(db, "create index idx_user on orders (user_id)"); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string][]string{
+		"select 1 from users u join profiles p on u.id = p.id":                              {"merge"},
+		"select 1 from users u join profiles p on p.id = u.id where p.id > 1":               {"merge"},
+		"select 1 from orders o join users u on o.user_id = u.id where o.user_id > 0":       {"merge"},
+		"select 1 from orders o join users u on o.user_id = u.id":                           {"index nested-loop (primary key)"},
+		"select 1 from users u join orders o on o.user_id = u.id":                           {"index nested-loop (idx_user)"},
+		"select 1 from users u left join orders o on o.user_id = u.id + 0":                  {"index nested-loop (idx_user)"},
+		"select 1 from users u join profiles p on u.age = p.id":                             {"index nested-loop (primary key)"},
+		"select 1 from users u join orders o on o.amount = u.age":                           {"nested-loop"},
+		"select 1 from users u join profiles p on u.id = p.id and p.id = 1":                 {"nested-loop"},
+		"select 1 from users u join profiles p on u.id = p.id join orders o on o.id = p.id": {"merge", "index nested-loop (primary key)"},
+	}
+	for sql, want := range cases {
+		root, err := newLexReader(NewLex(sql)).buildAST()
+		if err != nil {
+			t.Fatal(err)
+		}
+		lp, err := db.logicalSelect(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		op, err := db.physicalSelect(lp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := joinMethods(op); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: got %v, want %v", sql, got, want)
+		}
+	}
+}
+
+// 每种连接方法的结果都和逐行比较的结果相同，覆盖分批扫描
+func TestExec_JoinRandom(t *testing.T) {
+	db := Open(index.New(4))
+	_, err := Exec(db, `create table a (id int primary key, k int, v int);
+		create table b (id int primary key, k int, v int);
+		create index idx_bk on b (k)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type row struct{ id, k, v int64 }
+	var as, bs []row
+	insertA, _ := db.Prepare("insert into a values (?, ?, ?)")
+	insertB, _ := db.Prepare("insert into b values (?, ?, ?)")
+	for i := 0; i < 600; i++ {
+		r := row{int64(i * 2), rand.Int63n(300), rand.Int63n(10)}
+		as = append(as, r)
+		insertA.Exec(r.id, r.k, r.v)
+	}
+	for i := 0; i < 500; i++ {
+		r := row{int64(i * 3), rand.Int63n(300), rand.Int63n(10)}
+		bs = append(bs, r)
+		insertB.Exec(r.id, r.k, r.v)
+	}
+	field := func(r row, f string) int64 {
+		switch f {
+		case "id":
+			return r.id
+		case "k":
+			return r.k
+		}
+		return r.v
+	}
+	// 连接条件是 a.lf = b.rf and a.v < b.v
+	for _, c := range []struct{ lf, rf, method string }{
+		{"id", "id", "merge"},
+		{"k", "id", "index nested-loop (primary key)"},
+		{"id", "k", "index nested-loop (idx_bk)"},
+		{"k", "v", "nested-loop"},
+	} {
+		for _, left := range []bool{false, true} {
+			join := "join"
+			if left {
+				join = "left join"
+			}
+			sql := fmt.Sprintf("select a.id, b.id from a %s b on a.%s = b.%s and a.v < b.v", join, c.lf, c.rf)
+			var want []string
+			for _, ra := range as {
+				matched := false
+				for _, rb := range bs {
+					if field(ra, c.lf) == field(rb, c.rf) && ra.v < rb.v {
+						want = append(want, fmt.Sprint(ra.id, rb.id))
+						matched = true
+					}
+				}
+				if left && !matched {
+					want = append(want, fmt.Sprint(ra.id, nil))
+				}
+			}
+			root, _ := newLexReader(NewLex(sql)).buildAST()
+			lp, err := db.logicalSelect(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			op, _ := db.physicalSelect(lp)
+			if got := joinMethods(op); !reflect.DeepEqual(got, []string{c.method}) {
+				t.Fatalf("%s: method %v, want %s", sql, got, c.method)
+			}
+			ret, err := Exec(db, sql)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, r := range ret.Rows {
+				got = append(got, fmt.Sprint(r[0], r[1]))
+			}
+			sort.Strings(got)
+			sort.Strings(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("%s: got %d rows, want %d", sql, len(got), len(want))
+			}
+		}
+	}
+}
@@ -2,6 +2,7 @@ package sql
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -9,9 +10,10 @@ import (
 
 // 词法分析：将sql语句分割为token序列
 // 还是要借鉴一下 learn-go-with-hard
-type position struct {
-	row int // 行
-	col int // 列
+// 在语句中的位置，从1开始
+type Position struct {
+	Row int // 行
+	Col int // 列（按字符计算）
 }
 
 type tokenType int
@@ -19,21 +21,25 @@ type tokenType int
 type token struct {
 	typ tokenType // 记号
 	lit string    // 对应值
-	pos position  // 当sql命令有多行时使用（暂时没用）
+	pos Position  // token第一个字符的位置
+	off int       // token在输入字符串中的偏移（字节）
+	end int       // token结束的偏移
 }
 
 func (t token) String() string {
 	return fmt.Sprintf("{%s : %q}\t", tokens[t.typ], t.lit)
 }
-// TODO:把字面量改成实际的类型：string、int、float
 const (
 	KeyWord    tokenType = iota // 关键字
 	Identifier                  // 标识符
 	Literal                     //字面量(字符串)
 	Num                         // 数字
+	Bool                        // true、false
+	Null                        // null
 	Symbol                      // 特殊符号
 	Paren                       //括号( or )
 	Semicolon                   //分号;
+	Param                       // 占位符：? 或 $1
 	EOF                         //结束
 )
 
@@ -44,9 +50,12 @@ var tokens = map[tokenType]string{
 	Identifier: "标识符",
 	Literal:    "字面量",
 	Num:        "数字",
+	Bool:       "布尔值",
+	Null:       "空值",
 	Symbol:     "特殊符号",
 	Paren:      "括号",
 	Semicolon:  "分号",
+	Param:      "占位符",
 	EOF:        "结束",
 }
 
@@ -62,6 +71,14 @@ type lexer struct {
 	state    stateFuc        // 状态
 	tokens   []*token        // 解析到的token
 	keyword  map[string]bool // keyword
+	err      error           // 词法错误
+	// 计算行列用：已经数过换行的位置、该位置所在的行及行首
+	scanned   int
+	row       int
+	lineStart int
+	// 输入字符串在整个脚本中的起始位置，以及脚本中同一行在它之前的内容
+	base   Position
+	prefix string
 }
 
 // 启动
@@ -103,19 +120,69 @@ func (l *lexer) peek() rune {
 // 写入tokens
 func (l *lexer) token(typ tokenType) {
 	lit := l.str[l.start:l.pos]
-	if typ == KeyWord {
+	if typ == KeyWord || typ == Bool || typ == Null {
 		lit = strings.ToLower(lit)
 	}
+	l.tokenLit(typ, lit)
+}
+
+// 写入tokens，使用给定的值（如去掉引号、处理过转义的字符串）
+func (l *lexer) tokenLit(typ tokenType, lit string) {
 	t := &token{
 		typ: typ,
 		lit: lit,
-		pos: position{},
+		pos: l.position(l.start),
+		off: l.start,
+		end: l.pos,
 	}
 	l.tokens = append(l.tokens, t)
 	l.start = l.pos
 	l.curToken = t
 }
 
+// 计算offset处的行列（offset一般是递增的，从上次计算的位置继续数换行）
+func (l *lexer) position(offset int) Position {
+	if offset < l.scanned {
+		l.scanned, l.row, l.lineStart = 0, 0, 0
+	}
+	for i := l.scanned; i < offset; i++ {
+		if l.str[i] == '\n' {
+			l.row++
+			l.lineStart = i + 1
+		}
+	}
+	l.scanned = offset
+	col := utf8.RuneCountInString(l.str[l.lineStart:offset]) + 1
+	if l.row == 0 {
+		col += l.base.Col - 1
+	}
+	return Position{Row: l.base.Row + l.row, Col: col}
+}
+
+// offset所在的那一行（第一行包括脚本中在它之前的内容），不包括换行符
+func (l *lexer) line(offset int) string {
+	if offset > len(l.str) {
+		offset = len(l.str)
+	}
+	start := strings.LastIndexByte(l.str[:offset], '\n') + 1
+	end := strings.IndexByte(l.str[offset:], '\n')
+	if end < 0 {
+		end = len(l.str)
+	} else {
+		end += offset
+	}
+	line := strings.TrimRight(l.str[start:end], "\r")
+	if start == 0 {
+		line = l.prefix + line
+	}
+	return line
+}
+
+// 记录offset处的词法错误
+func (l *lexer) errorf(offset int, format string, args ...interface{}) {
+	l.err = newSyntaxError(l.line(offset), l.position(offset), fmt.Sprintf(format, args...))
+}
+
 // 添加keyword
 func (l *lexer) addKeyWord(keywords ...string) {
 	for _, key := range keywords {
@@ -125,9 +192,16 @@ func (l *lexer) addKeyWord(keywords ...string) {
 
 // 入口函数
 func NewLex(str string) *lexer {
+	return newLexAt(str, Position{Row: 1, Col: 1}, "")
+}
+
+// 分析脚本中从base开始的一段语句，prefix是脚本中同一行在base之前的内容（显示错误时用）
+func newLexAt(str string, base Position, prefix string) *lexer {
 	l := &lexer{
 		str:     str,
 		keyword: make(map[string]bool),
+		base:    base,
+		prefix:  prefix,
 	}
 	l.addKeyWord("insert", "update", "delete", "find", "set")
 	l.run()
@@ -181,22 +255,19 @@ func isSymbol(r rune) bool {
 //-------------- state func ------------------------
 func lexBegin(l *lexer) stateFuc {
 	switch r := l.next(); {
-	case unicode.IsDigit(r) || r == '.' || r == '-': // 判断是否是数字
-		if r == '-' && l.curToken.typ == Num {
-			goto L
-		}
+	case r == '-' && l.peek() == '-': // 注释：-- 直到行尾
+		return lexLineComment
+	case r == '/' && l.peek() == '*': // 注释：/* */
+		return lexBlockComment
+	case unicode.IsDigit(r) || r == '.': // 判断是否是数字（负号由语法分析处理）
 		l.backup()
 		lexNum(l)
 		return lexBegin
-	L:
-		fallthrough
 	case unicode.IsSpace(r): // 空格就跳过
 		l.ignore()
 	case isVariable(r):
 		return lexVariable
 	case isLiteral(r):
-		// 当前是引号可以跳过
-		l.ignore()
 		return lexLiteral
 	case isSymbol(r):
 		return lexSymbol
@@ -204,10 +275,12 @@ func lexBegin(l *lexer) stateFuc {
 		return lexParen
 	case r == ';':
 		return lexSemicolon
+	case r == '?' || r == '$':
+		return lexParam
 	case r == eof:
 		return lexEOF
 	default:
-		fmt.Println("this type is unkown")
+		l.errorf(l.start, "unknown character %q", r)
 		return lexUnkown
 	}
 	return lexBegin
@@ -220,11 +293,28 @@ func lexVariable(l *lexer) stateFuc {
 		l.next()
 		r = l.peek()
 	}
-	// 2.判断是否为keyword
+	// 表名.列名 是一个标识符
+	if l.peek() == '.' && strings.IndexFunc(l.str[l.pos+1:], isVariable) == 0 {
+		l.next()
+		for r := l.peek(); isVariable(r) || unicode.IsDigit(r); {
+			l.next()
+			r = l.peek()
+		}
+		return lexIdentifier
+	}
+	// 2.判断是否为keyword（或者true、false、null）
 	v := strings.ToLower(l.str[l.start:l.pos])
 	if ok := l.keyword[v]; ok {
 		return lexKeyWord
 	}
+	switch v {
+	case "true", "false":
+		l.token(Bool)
+		return lexBegin
+	case "null":
+		l.token(Null)
+		return lexBegin
+	}
 	return lexIdentifier
 }
 
@@ -238,30 +328,90 @@ func lexIdentifier(l *lexer) stateFuc {
 	return lexBegin
 }
 
+// 读取引号中的字符串（开头的引号已经读过），结尾的引号需要和开头相同
+// 支持转义：\n \t \r \0 \\ \' \" \uXXXX，以及连续两个引号表示一个引号
 func lexLiteral(l *lexer) stateFuc {
-	// 读取字符串
-	for r := l.peek(); unicode.IsLetter(r); {
-		l.next()
-		r = l.peek()
+	quote := rune(l.str[l.start])
+	var sb strings.Builder
+	for {
+		r := l.next()
+		switch {
+		case r == eof:
+			l.errorf(l.start, "unterminated string")
+			return nil
+		case r == quote:
+			if l.peek() != quote {
+				l.tokenLit(Literal, sb.String())
+				return lexBegin
+			}
+			l.next() // 连续两个引号
+			sb.WriteRune(quote)
+		case r == '\\':
+			e, ok := l.escape()
+			if !ok {
+				return nil
+			}
+			sb.WriteRune(e)
+		default:
+			sb.WriteRune(r)
+		}
 	}
-	l.token(Literal)
-	// 把收尾的引号消耗掉
-	if isLiteral(l.peek()) {
-		l.next()
+}
+
+// 读取\之后的转义字符，出错时记录错误并返回false
+func (l *lexer) escape() (rune, bool) {
+	begin := l.pos - 1 // \的位置
+	switch r := l.next(); r {
+	case 'n':
+		return '\n', true
+	case 't':
+		return '\t', true
+	case 'r':
+		return '\r', true
+	case '0':
+		return 0, true
+	case '\\', '\'', '"':
+		return r, true
+	case 'u':
+		start := l.pos
+		for i := 0; i < 4; i++ {
+			if !isHex(l.next()) {
+				l.errorf(begin, "invalid unicode escape")
+				return 0, false
+			}
+		}
+		v, _ := strconv.ParseUint(l.str[start:l.pos], 16, 32)
+		return rune(v), true
+	case eof:
+		l.errorf(l.start, "unterminated string")
+		return 0, false
+	default:
+		l.errorf(begin, "unknown escape sequence \\%c", r)
+		return 0, false
 	}
-	return lexBegin
+}
+
+func isHex(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
 }
 
 func lexNum(l *lexer) stateFuc {
-	for r := l.peek(); unicode.IsDigit(r) || r == '.' || r == '-' || r == 'e'; {
-		l.next()
+	var prev rune
+	// -只能出现在指数部分（1e-3）
+	for r := l.peek(); unicode.IsDigit(r) || r == '.' || r == 'e' || r == 'E' || (r == '-' && (prev == 'e' || prev == 'E')); {
+		prev = l.next()
 		r = l.peek()
 	}
 	l.token(Num)
 	return lexBegin
 }
 
+// 特殊符号，>= <= != <> 作为一个token
 func lexSymbol(l *lexer) stateFuc {
+	switch r, next := rune(l.str[l.start]), l.peek(); {
+	case (r == '>' || r == '<' || r == '!') && next == '=', r == '<' && next == '>':
+		l.next()
+	}
 	l.token(Symbol)
 	return lexBegin
 }
@@ -271,12 +421,50 @@ func lexParen(l *lexer) stateFuc {
 	return lexBegin
 }
 
+// 一条语句结束，继续分析下一条
 func lexSemicolon(l *lexer) stateFuc {
 	l.token(Semicolon)
-	return nil
+	return lexBegin
+}
+
+// 跳过--注释（第一个-已经读过）
+func lexLineComment(l *lexer) stateFuc {
+	for r := l.next(); r != '\n' && r != eof; r = l.next() {
+	}
+	l.ignore()
+	return lexBegin
+}
+
+// 跳过/* */注释（/已经读过），不支持嵌套
+func lexBlockComment(l *lexer) stateFuc {
+	l.next() // *
+	end := strings.Index(l.str[l.pos:], "*/")
+	if end < 0 {
+		l.errorf(l.start, "unterminated comment")
+		return nil
+	}
+	l.pos += end + 2
+	l.ignore()
+	return lexBegin
+}
+
+// 占位符：?按出现的顺序编号，$n是第n个参数
+func lexParam(l *lexer) stateFuc {
+	if l.str[l.start] == '$' {
+		for unicode.IsDigit(l.peek()) {
+			l.next()
+		}
+		if n, err := strconv.Atoi(l.str[l.start+1 : l.pos]); err != nil || n < 1 {
+			l.errorf(l.start, "invalid placeholder %s", l.str[l.start:l.pos])
+			return nil
+		}
+	}
+	l.token(Param)
+	return lexBegin
 }
 
 func lexEOF(l *lexer) stateFuc {
+	l.token(EOF)
 	return nil
 }
 
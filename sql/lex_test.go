@@ -3,6 +3,7 @@ package sql
 import (
 	"fmt"
 	"log"
+	"strings"
 	"testing"
 	"unicode/utf8"
 )
@@ -52,3 +53,93 @@ func Test_isSymbol(t *testing.T) {
 		log.Printf("%q is variable : %v\n", r, isSymbol(r))
 	}
 }
+
+func Test_lexLiteral(t *testing.T) {
+	cases := []struct {
+		sql  string
+		want string
+	}{
+		{`'hello world'`, "hello world"},
+		{`'a-b'`, "a-b"},
+		{`'3 apples'`, "3 apples"},
+		{`"何惟禹"`, "何惟禹"},
+		{`''`, ""},
+		{`'it''s'`, "it's"},
+		{`"say ""hi"""`, `say "hi"`},
+		{`"it's"`, "it's"},
+		{`'it\'s'`, "it's"},
+		{`'a\\b'`, `a\b`},
+		{`'line1\nline2\ttab'`, "line1\nline2\ttab"},
+		{`'\u4f60\u597D'`, "你好"},
+		{`'a;b'`, "a;b"},
+	}
+	for _, c := range cases {
+		l := NewLex("insert k " + c.sql + ";")
+		if l.err != nil {
+			t.Fatalf("%s: %v", c.sql, l.err)
+		}
+		if len(l.tokens) != 5 || l.tokens[2].typ != Literal || l.tokens[2].lit != c.want {
+			t.Fatalf("%s: got %v, want %q", c.sql, l.tokens, c.want)
+		}
+	}
+	for _, sql := range []string{`insert k 'abc`, `insert k "abc'`, `insert k 'abc\`, `insert k 'a\qb'`, `insert k '\u12'`} {
+		if l := NewLex(sql); l.err == nil {
+			t.Fatalf("%s should fail, got %v", sql, l.tokens)
+		}
+	}
+}
+
+func Test_lexComment(t *testing.T) {
+	sql := "-- 初始数据\ninsert a 1; /* 第二条\n语句 */ insert b -2--注释\n;find a/**/;"
+	l := NewLex(sql)
+	if l.err != nil {
+		t.Fatal(l.err)
+	}
+	var got []string
+	for _, tk := range l.tokens {
+		got = append(got, tk.lit)
+	}
+	want := []string{"insert", "a", "1", ";", "insert", "b", "-", "2", ";", "find", "a", ";", ""}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if l.tokens[4].pos != (Position{3, 7}) {
+		t.Fatalf("position of second insert: %v", l.tokens[4].pos)
+	}
+	if l := NewLex("find a /* abc"); l.err == nil {
+		t.Fatal("unterminated comment should fail")
+	}
+}
+
+func Test_lexSymbol(t *testing.T) {
+	l := NewLex("find >= 1 <= 2 != 3 <> 4 > -5 < = !")
+	var got []string
+	for _, tk := range l.tokens {
+		if tk.typ == Symbol {
+			got = append(got, tk.lit)
+		}
+	}
+	if want := []string{">=", "<=", "!=", "<>", ">", "-", "<", "=", "!"}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func Test_lexQualified(t *testing.T) {
+	l := NewLex("select u.id, Order.user_id2 from users u where u.x = .5 and a. b")
+	want := []struct {
+		typ tokenType
+		lit string
+	}{
+		{Identifier, "select"}, {Identifier, "u.id"}, {Symbol, ","}, {Identifier, "Order.user_id2"}, {Identifier, "from"},
+		{Identifier, "users"}, {Identifier, "u"}, {Identifier, "where"}, {Identifier, "u.x"}, {Symbol, "="},
+		{Num, ".5"}, {Identifier, "and"}, {Identifier, "a"},
+	}
+	if len(l.tokens) < len(want) {
+		t.Fatalf("got %d tokens, want at least %d", len(l.tokens), len(want))
+	}
+	for i, w := range want {
+		if l.tokens[i].typ != w.typ || l.tokens[i].lit != w.lit {
+			t.Fatalf("token %d: got %v, want %v", i, l.tokens[i], w)
+		}
+	}
+}
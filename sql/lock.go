@@ -0,0 +1,84 @@
+package sql
+
+import (
+	"sync"
+	"time"
+)
+
+// 没有设置时等待事务结束的时间
+const defaultLockTimeout = 5 * time.Second
+
+// 事务锁：事务持有写锁，其它语句执行时持有读锁
+// 和sync.RWMutex不同，等待超过timeout时返回ErrLockTimeout，
+// 同一个goroutine在事务中用其它会话执行语句、或者忘记了commit时不会一直阻塞
+// 有写锁在等待时新的读锁也要等待，事务不会因为一直有读锁而拿不到写锁
+type txLock struct {
+	mu      sync.Mutex
+	readers int           // 持有读锁的语句数
+	writer  bool          // 是否有事务持有写锁
+	waiting int           // 等待写锁的事务数
+	changed chan struct{} // 状态改变时关闭并换成新的，唤醒所有等待的goroutine
+	timeout time.Duration
+}
+
+func newTxLock() *txLock {
+	return &txLock{changed: make(chan struct{}), timeout: defaultLockTimeout}
+}
+
+// 加读锁（write为false）或者写锁
+func (l *txLock) lock(write bool) error {
+	var deadline <-chan time.Time
+	l.mu.Lock()
+	if write {
+		l.waiting++
+	}
+	for {
+		if write && !l.writer && l.readers == 0 {
+			l.waiting--
+			l.writer = true
+			l.mu.Unlock()
+			return nil
+		}
+		if !write && !l.writer && l.waiting == 0 {
+			l.readers++
+			l.mu.Unlock()
+			return nil
+		}
+		if deadline == nil {
+			t := time.NewTimer(l.timeout)
+			defer t.Stop()
+			deadline = t.C
+		}
+		changed := l.changed
+		l.mu.Unlock()
+		select {
+		case <-changed:
+			l.mu.Lock()
+		case <-deadline:
+			l.mu.Lock()
+			if write {
+				l.waiting--
+				l.broadcast() // 等待这个写锁的读锁可以继续
+			}
+			l.mu.Unlock()
+			return ErrLockTimeout
+		}
+	}
+}
+
+func (l *txLock) unlock(write bool) {
+	l.mu.Lock()
+	if write {
+		l.writer = false
+	} else {
+		l.readers--
+	}
+	l.broadcast()
+	l.mu.Unlock()
+}
+
+// 唤醒所有等待的goroutine，调用时持有mu
+func (l *txLock) broadcast() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
@@ -0,0 +1,245 @@
+package sql
+
+import (
+	"HwyDB/catalog"
+	"HwyDB/index"
+	"sort"
+)
+
+// 物理算子：每个算子把结果逐行推给fn，fn返回false时停止
+type operator interface {
+	run(fn func(row []interface{}) (bool, error)) error
+}
+
+// 按主键查找一行
+type pointLookup struct {
+	table *catalog.Table
+	key   interface{}
+}
+
+func (p *pointLookup) run(fn func(row []interface{}) (bool, error)) error {
+	row, ok, err := p.table.Get(p.key)
+	if err != nil || !ok {
+		return err
+	}
+	_, err = fn(row)
+	return err
+}
+
+// 扫描时每批读取的行数
+const scanBatch = 256
+
+// 按主键的顺序扫描r范围内的行（full为true时是全表扫描）
+type tableScan struct {
+	table *catalog.Table
+	r     index.ScanRange
+	full  bool
+}
+
+func (s *tableScan) run(fn func(row []interface{}) (bool, error)) error {
+	cur := &rowCursor{table: s.table, r: s.r}
+	for {
+		row, err := cur.peek()
+		if row == nil || err != nil {
+			return err
+		}
+		if ok, err := fn(row); !ok || err != nil {
+			return err
+		}
+		cur.next()
+	}
+}
+
+// 按主键的顺序逐行读取r范围内的行
+// 每次在B+树的锁内读取一批行，释放锁之后再逐行返回，读取的过程中可以再访问这个表
+type rowCursor struct {
+	table *catalog.Table
+	r     index.ScanRange
+	batch []catalog.Row
+	pos   int
+	done  bool // 已经读到了最后一批
+}
+
+// 当前的一行，没有更多的行时返回nil
+func (c *rowCursor) peek() (catalog.Row, error) {
+	if c.pos < len(c.batch) {
+		return c.batch[c.pos], nil
+	}
+	if c.done {
+		return nil, nil
+	}
+	c.batch, c.pos = c.batch[:0], 0
+	err := c.table.Scan(c.r, func(row catalog.Row) bool {
+		c.batch = append(c.batch, row)
+		return len(c.batch) < scanBatch
+	})
+	if err != nil {
+		return nil, err
+	}
+	if c.done = len(c.batch) < scanBatch; c.done && len(c.batch) == 0 {
+		return nil, nil
+	}
+	// 下一批从这一批的最后一行之后开始
+	last := c.batch[len(c.batch)-1][c.table.PK]
+	if c.r.Desc {
+		c.r.Hi, c.r.HiOpen = last, true
+	} else {
+		c.r.Lo, c.r.LoOpen = last, true
+	}
+	return c.batch[0], nil
+}
+
+func (c *rowCursor) next() {
+	c.pos++
+}
+
+// 按索引列的值的顺序遍历r范围内的行，再按主键找到每一行
+type indexScan struct {
+	table *catalog.Table
+	ix    *catalog.Index
+	r     index.ScanRange
+}
+
+func (s *indexScan) run(fn func(row []interface{}) (bool, error)) error {
+	var err error
+	scanErr := s.ix.Scan(s.r, func(pk interface{}) bool {
+		var row catalog.Row
+		var ok bool
+		if row, ok, err = s.table.Get(pk); err != nil {
+			return false
+		}
+		if !ok { // 扫描索引之后这一行被删除了
+			return true
+		}
+		ok, err = fn(row)
+		return ok && err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return scanErr
+}
+
+// 只输出满足所有条件的行
+type filter struct {
+	input operator
+	conds []*SynatxTreeNode
+	ctx   *evalContext
+}
+
+func (f *filter) run(fn func(row []interface{}) (bool, error)) error {
+	return f.input.run(func(row []interface{}) (bool, error) {
+		f.ctx.row = row
+		for _, cond := range f.conds {
+			if ok, err := f.ctx.test(cond); !ok || err != nil {
+				return err == nil, err
+			}
+		}
+		return fn(row)
+	})
+}
+
+// 读取所有的行之后排序
+type sortRows struct {
+	input operator
+	keys  []orderKey
+	ctx   *evalContext
+}
+
+func (s *sortRows) run(fn func(row []interface{}) (bool, error)) error {
+	type sortItem struct {
+		row  []interface{}
+		keys []interface{}
+	}
+	var items []sortItem
+	err := s.input.run(func(row []interface{}) (bool, error) {
+		s.ctx.row = row
+		item := sortItem{row: row, keys: make([]interface{}, len(s.keys))}
+		for i, k := range s.keys {
+			v, err := s.ctx.eval(k.expr)
+			if err != nil {
+				return false, err
+			}
+			item.keys[i] = v
+		}
+		items = append(items, item)
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		for n, k := range s.keys {
+			c := orderValues(items[i].keys[n], items[j].keys[n])
+			if c != 0 {
+				return (c < 0) != k.desc
+			}
+		}
+		return false
+	})
+	for _, item := range items {
+		if ok, err := fn(item.row); !ok || err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 排序时所有的值之间都可以比较：null < bool < 数字 < 字符串
+func orderValues(l, r interface{}) int {
+	if c, err := compareValues(l, r); err == nil {
+		return c
+	}
+	rank := func(v interface{}) int {
+		switch number(v).(type) {
+		case nil:
+			return 0
+		case bool:
+			return 1
+		case int64, float64:
+			return 2
+		}
+		return 3
+	}
+	return rank(l) - rank(r)
+}
+
+// 最多输出n行
+type limitRows struct {
+	input operator
+	n     int64
+}
+
+func (l *limitRows) run(fn func(row []interface{}) (bool, error)) error {
+	if l.n <= 0 {
+		return nil
+	}
+	count := int64(0)
+	return l.input.run(func(row []interface{}) (bool, error) {
+		count++
+		ok, err := fn(row)
+		return ok && count < l.n, err
+	})
+}
+
+// 计算输出的列
+type project struct {
+	input operator
+	exprs []*SynatxTreeNode
+	ctx   *evalContext
+}
+
+func (p *project) run(fn func(row []interface{}) (bool, error)) error {
+	return p.input.run(func(row []interface{}) (bool, error) {
+		p.ctx.row = row
+		out := make([]interface{}, len(p.exprs))
+		for i, e := range p.exprs {
+			v, err := p.ctx.eval(e)
+			if err != nil {
+				return false, err
+			}
+			out[i] = v
+		}
+		return fn(out)
+	})
+}
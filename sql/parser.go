@@ -0,0 +1,421 @@
+package sql
+
+import (
+	"strconv"
+	"strings"
+)
+
+// 语句的文法（EBNF），表达式expr见expr.go，select见select.go：
+//	script     = [ statement ] { ";" [ statement ] }
+//	statement  = [ "explain" ] ( kv | find | create | drop | insertInto | updateSet | deleteFrom | select
+//	           | "begin" | "commit" | "rollback" )
+//	kv         = ( "insert" | "update" | "append" ) key value
+//	           | "delete" key
+//	           | ( "incr" | "decr" ) key [ expr ]
+//	find       = "find" ( key | range [ "order" "by" "key" [ "asc" | "desc" ] ] [ "limit" count ] )
+//	range      = "between" key "and" key | ( ">" | ">=" | "<" | "<=" ) key | "prefix" ( <string> | <ident> | <param> )
+//	key        = [ "-" ] <number> | <string> | <ident> | <param>
+//	value      = <ident> | expr                       单独的标识符是字符串，在表达式中才表示关键字的value
+//	count      = <int> | <param>
+//	create     = "create" "table" <ident> "(" column { "," column } ")"
+//	           | "create" "index" <ident> "on" <ident> "(" <ident> ")"
+//	column     = <ident> <type> [ "primary" "key" ]
+//	drop       = "drop" ( "table" | "index" ) <ident>
+//	insertInto = "insert" "into" <ident> [ "(" <ident> { "," <ident> } ")" ] "values" row { "," row }
+//	row        = "(" value { "," value } ")"
+//	updateSet  = "update" <ident> "set" <ident> "=" expr { "," <ident> "=" expr } [ "where" expr ]
+//	deleteFrom = "delete" "from" <ident> [ "where" expr ]
+//
+// 每个非终结符对应一个xxxParser函数，只向前看一个（insert into时四个，update set、delete from时三个）token决定走哪个分支。
+// token读完之后read返回EOF，缺少token时报告unexpected end of statement。
+// Parse遇到语法错误时跳到这条语句的;之后继续解析，一次报告一个脚本中所有语句的错误。
+//
+// between、and、prefix、order、by、limit、asc、desc，create、drop、table、primary、into、values，
+// select、from、where、or、not、is、as、index、on、group、having，join、inner、left、outer、explain，
+// begin、commit、rollback，incr、decr、append是上下文关键字：词法分析时是标识符，
+// 只在文法中需要它们的位置按文本匹配（isWord），其它位置仍然可以作为关键字、value使用（insert order 1）
+
+// t是不是上下文关键字word（不区分大小写）
+func isWord(t *token, word string) bool {
+	return (t.typ == KeyWord || t.typ == Identifier) && strings.EqualFold(t.lit, word)
+}
+
+// 解析query中的所有语句；有语法错误时返回SyntaxErrors（包括所有出错的语句），以及其它语句的语法树
+func Parse(query string) ([]*SynatxTreeNode, error) {
+	roots, _, err := parseQuery(query)
+	return roots, err
+}
+
+// 解析query，同时返回读取token的TokenReader（用于得到占位符的个数）
+// 词法错误之后的内容无法再分析，只解析词法错误所在的语句之前的语句
+func parseQuery(query string) ([]*SynatxTreeNode, *TokenReader, error) {
+	lex := NewLex(query)
+	tr := newLexReader(lex)
+	if lex.err != nil {
+		end := 0 // 最后一个;之后的token都不要
+		for i, t := range lex.tokens {
+			if t.typ == Semicolon {
+				end = i + 1
+			}
+		}
+		tr.data = lex.tokens[:end]
+	}
+	roots, errs := tr.parseAll()
+	if lex.err != nil {
+		errs = append(errs, lex.err.(*SyntaxError))
+	}
+	if len(errs) > 0 {
+		return roots, tr, errs
+	}
+	return roots, tr, nil
+}
+
+// 解析剩下的所有语句，出错的语句跳过
+func (t *TokenReader) parseAll() ([]*SynatxTreeNode, SyntaxErrors) {
+	var roots []*SynatxTreeNode
+	var errs SyntaxErrors
+	for t.skipEmpty() {
+		start := t.pos
+		root, err := t.buildAST()
+		if err == nil {
+			roots = append(roots, root)
+			continue
+		}
+		se, ok := err.(*SyntaxError)
+		if !ok {
+			se = newSyntaxError("", t.data[start].pos, err.Error())
+		}
+		errs = append(errs, se)
+		t.recover(start)
+	}
+	return roots, errs
+}
+
+// 从出错的语句的第一个token（下标start）跳到这条语句的;之后
+// 语句中不会有;，所以不管出错时读到了哪里，start之后的第一个;就是这条语句的结尾
+func (t *TokenReader) recover(start int) {
+	t.pos = start
+	for t.pos < len(t.data) && t.data[t.pos].typ != Semicolon {
+		t.pos++
+	}
+}
+
+// 从当前位置开始解析一条语句
+func (t *TokenReader) buildAST() (*SynatxTreeNode, error) {
+	first := t.peek()
+	if first.typ == EOF {
+		return nil, t.errorf(first, "empty statement")
+	}
+	var root *SynatxTreeNode
+	var err error
+	if isWord(first, "explain") { // explain <语句>：Child是要解释的语句
+		t.read()
+		root = &SynatxTreeNode{Name: "explain", Pos: first.pos}
+		var stmt *SynatxTreeNode
+		if stmt, err = statementParser(t); err == nil {
+			root.Child = []*SynatxTreeNode{stmt}
+		}
+	} else {
+		root, err = statementParser(t)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 语句之后只能是;或者结束
+	if end := t.read(); end.typ != Semicolon && end.typ != EOF {
+		return nil, t.unexpected(end)
+	}
+	return root, nil
+}
+
+// 按第一个关键字解析一条语句
+func statementParser(t *TokenReader) (*SynatxTreeNode, error) {
+	first := t.peek()
+	if first.typ != KeyWord && first.typ != Identifier {
+		return nil, t.unexpected(first)
+	}
+	switch strings.ToLower(first.lit) {
+	case "find":
+		return findParser(t)
+	case "insert":
+		if isInsertInto(t) {
+			return insertIntoParser(t)
+		}
+		return kvParser(t)
+	case "create":
+		return createParser(t)
+	case "drop":
+		return dropParser(t)
+	case "select":
+		return selectParser(t)
+	case "update":
+		if isUpdateSet(t) {
+			return updateSetParser(t)
+		}
+		return kvParser(t)
+	case "delete":
+		if isDeleteFrom(t) {
+			return deleteFromParser(t)
+		}
+		return kvParser(t)
+	case "incr", "decr", "append":
+		return kvParser(t)
+	case "begin", "commit", "rollback":
+		t.read()
+		return &SynatxTreeNode{Name: strings.ToLower(first.lit), Pos: first.pos}, nil
+	}
+	return nil, t.unexpected(first)
+}
+
+// insert into <table> (... | insert into <table> values ...：插入表的语句，
+// 否则into是关键字（insert into 1、insert into foo插入关键字into）
+func isInsertInto(t *TokenReader) bool {
+	if !isWord(t.peekAt(1), "into") || t.peekAt(2).typ != Identifier {
+		return false
+	}
+	next := t.peekAt(3)
+	return next.typ == Paren && next.lit == "(" || isWord(next, "values")
+}
+
+// update <table> set ...：set是关键字，不能作为value，所以不会和update <key> <value>混淆
+func isUpdateSet(t *TokenReader) bool {
+	return t.peekAt(1).typ == Identifier && isWord(t.peekAt(2), "set")
+}
+
+// delete from <table> ...：delete <key>之后不能再有token，所以不会和它混淆
+func isDeleteFrom(t *TokenReader) bool {
+	return isWord(t.peekAt(1), "from") && t.peekAt(2).typ == Identifier
+}
+
+// insert <key> <value> | update <key> <value> | delete <key> | incr <key> [n] | decr <key> [n] | append <key> <value>
+// incr、decr的n是表达式（默认是1），其中的标识符表示关键字的value
+func kvParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	t := tr.read()
+	key, err := keyParser(tr)
+	if err != nil {
+		return nil, err
+	}
+	node := &SynatxTreeNode{Name: strings.ToLower(t.lit), Pos: t.pos, Child: []*SynatxTreeNode{key}}
+	switch node.Name {
+	case "delete":
+		return node, nil
+	case "incr", "decr":
+		if end := tr.peek(); end.typ == Semicolon || end.typ == EOF {
+			return node, nil
+		}
+		n, err := exprParser(tr)
+		if err != nil {
+			return nil, err
+		}
+		node.Child = append(node.Child, valueNode(n))
+		return node, nil
+	}
+	value, err := valueParser(tr)
+	if err != nil {
+		return nil, err
+	}
+	node.Child = append(node.Child, value)
+	return node, nil
+}
+
+// find <key>
+// find between <v> and <v> | find >、>=、<、<= <v> | find prefix <string>
+// 范围查询之后可以有 order by key [asc|desc] 和 limit <n>
+func findParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	t := tr.read()
+	node := &SynatxTreeNode{Name: "find", Pos: t.pos}
+	rng, err := rangeParser(tr)
+	if err != nil {
+		return nil, err
+	}
+	if rng == nil {
+		key, err := keyParser(tr)
+		if err != nil {
+			return nil, err
+		}
+		node.Child = []*SynatxTreeNode{key}
+		return node, nil
+	}
+	node.Child = []*SynatxTreeNode{rng}
+	if t := tr.peek(); isWord(t, "order") {
+		order, err := orderParser(tr)
+		if err != nil {
+			return nil, err
+		}
+		node.Child = append(node.Child, order)
+	}
+	if t := tr.peek(); isWord(t, "limit") {
+		limit, err := limitParser(tr)
+		if err != nil {
+			return nil, err
+		}
+		node.Child = append(node.Child, limit)
+	}
+	return node, nil
+}
+
+// 解析范围条件，子节点的Name是比较符号（或者prefix），不是范围查询时返回nil
+// between、prefix之后没有内容时是关键字（find prefix）
+func rangeParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	t := tr.peek()
+	if t.typ == Identifier && isValueEnd(tr.peekAt(1)) {
+		return nil, nil
+	}
+	node := &SynatxTreeNode{Name: "range", Pos: t.pos}
+	switch {
+	case isWord(t, "between"):
+		tr.read()
+		lo, err := keyNode(tr, ">=")
+		if err != nil {
+			return nil, err
+		}
+		if and := tr.read(); !isWord(and, "and") {
+			return nil, tr.unexpected(and)
+		}
+		hi, err := keyNode(tr, "<=")
+		if err != nil {
+			return nil, err
+		}
+		node.Child = []*SynatxTreeNode{lo, hi}
+	case t.typ == Symbol && (t.lit == ">" || t.lit == ">=" || t.lit == "<" || t.lit == "<="):
+		tr.read()
+		bound, err := keyNode(tr, t.lit)
+		if err != nil {
+			return nil, err
+		}
+		node.Child = []*SynatxTreeNode{bound}
+	case isWord(t, "prefix"):
+		tr.read()
+		p := tr.read()
+		if p.typ != Literal && p.typ != Identifier && p.typ != Param {
+			return nil, tr.errorf(p, "prefix needs a string")
+		}
+		prefix, err := literalNode(tr, "prefix", p)
+		if err != nil {
+			return nil, err
+		}
+		node.Child = []*SynatxTreeNode{prefix}
+	default:
+		return nil, nil
+	}
+	return node, nil
+}
+
+// order by key [asc|desc]
+func orderParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	t := tr.read()
+	if by := tr.read(); !isWord(by, "by") {
+		return nil, tr.unexpected(by)
+	}
+	if k := tr.read(); !isWord(k, "key") {
+		return nil, tr.errorf(k, "can only order by key")
+	}
+	node := &SynatxTreeNode{Name: "order", Value: "asc", Pos: t.pos}
+	if d := tr.peek(); isWord(d, "asc") || isWord(d, "desc") {
+		node.Value = strings.ToLower(tr.read().lit)
+	}
+	return node, nil
+}
+
+// limit <n>
+func limitParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	tr.read()
+	n := tr.read()
+	if n.typ != Num && n.typ != Param {
+		return nil, tr.unexpected(n)
+	}
+	node, err := literalNode(tr, "limit", n)
+	if err != nil {
+		return nil, err
+	}
+	if node.ValueType == ParamType { // 绑定参数时再检查
+		return node, nil
+	}
+	if node.ValueType != IntType || node.Value.(int64) < 0 {
+		return nil, tr.errorf(n, "limit must be a non-negative integer")
+	}
+	return node, nil
+}
+
+// 解析关键字：标识符、字符串、数字或者占位符
+func keyParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	return keyNode(tr, "key")
+}
+
+// 读取一个可以作为关键字的值（可以是负数），生成名为name的节点
+func keyNode(tr *TokenReader, name string) (*SynatxTreeNode, error) {
+	t := tr.read()
+	if n := tr.peek(); t.typ == Symbol && t.lit == "-" && n.typ == Num {
+		tr.read()
+		node, err := negNum(tr, t, n)
+		if err != nil {
+			return nil, err
+		}
+		node.Name = name
+		return node, nil
+	}
+	if t.typ != Identifier && t.typ != Literal && t.typ != Num && t.typ != Param {
+		return nil, tr.unexpected(t)
+	}
+	return literalNode(tr, name, t)
+}
+
+// 解析value：字面量直接保存在节点中，其它表达式作为子节点，执行时再计算
+// 单独的标识符仍然是字符串（insert word hello），在表达式中才表示关键字的value
+func valueParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	if t := tr.peek(); t.typ == Identifier && isValueEnd(tr.peekAt(1)) {
+		return literalNode(tr, "value", tr.read())
+	}
+	expr, err := exprParser(tr)
+	if err != nil {
+		return nil, err
+	}
+	return valueNode(expr), nil
+}
+
+// value之后的token：语句结束，或者是values列表中的,和)
+func isValueEnd(t *token) bool {
+	return t.typ == Semicolon || t.typ == EOF || (t.typ == Symbol && t.lit == ",") || (t.typ == Paren && t.lit == ")")
+}
+
+// 把表达式包装成value节点
+func valueNode(expr *SynatxTreeNode) *SynatxTreeNode {
+	if expr.Name == "literal" {
+		expr.Name = "value"
+		return expr
+	}
+	return &SynatxTreeNode{Name: "value", Pos: expr.Pos, Child: []*SynatxTreeNode{expr}}
+}
+
+// 把字面量token转换成实际类型的节点
+func literalNode(tr *TokenReader, name string, t *token) (*SynatxTreeNode, error) {
+	node := &SynatxTreeNode{Name: name, Pos: t.pos}
+	switch t.typ {
+	case Num:
+		if v, err := strconv.ParseInt(t.lit, 10, 64); err == nil {
+			node.Value, node.ValueType = v, IntType
+		} else if !strings.ContainsAny(t.lit, ".eE") && err.(*strconv.NumError).Err == strconv.ErrRange {
+			// 没有小数点和指数的数字是整数，超出int64时报错，不转换成浮点数
+			return nil, tr.errorf(t, "integer out of range: %s", t.lit)
+		} else if v, err := strconv.ParseFloat(t.lit, 64); err == nil {
+			node.Value, node.ValueType = v, FloatType
+		} else {
+			return nil, tr.errorf(t, "invalid number: %s", t.lit)
+		}
+	case Literal, Identifier:
+		node.Value, node.ValueType = t.lit, StringType
+	case Bool:
+		node.Value, node.ValueType = t.lit == "true", BoolType
+	case Null:
+		node.Value, node.ValueType = nil, NullType
+	case Param:
+		i, err := tr.param(t)
+		if err != nil {
+			return nil, err
+		}
+		node.Value, node.ValueType = i, ParamType
+	default:
+		return nil, tr.unexpected(t)
+	}
+	return node, nil
+}
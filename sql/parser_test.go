@@ -0,0 +1,170 @@
+package sql
+
+import (
+	"HwyDB/index"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	roots, err := Parse("insert a 1; explain find between 1 and 2 limit 3;; delete a; incr c; decr c 2 * c; append s 'x'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"insert", "explain", "delete", "incr", "decr", "append"}
+	if len(roots) != len(want) {
+		t.Fatalf("got %d statements", len(roots))
+	}
+	for i, root := range roots {
+		if root.Name != want[i] {
+			t.Fatalf("statement %d: got %s, want %s", i, root.Name, want[i])
+		}
+	}
+	if roots, err := Parse(" -- 注释\n;"); err != nil || len(roots) != 0 {
+		t.Fatalf("empty script: %v, %v", roots, err)
+	}
+}
+
+// 出错的语句跳过，继续解析之后的语句，报告所有的错误
+func TestParse_Recover(t *testing.T) {
+	script := "insert a;\nfind a; age 29;\nselect x from;\nupdate b 2;\ndelete"
+	roots, err := Parse(script)
+	var errs SyntaxErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got %v, want SyntaxErrors", err)
+	}
+	want := []struct {
+		pos Position
+		msg string
+	}{
+		{Position{1, 9}, "unexpected end of statement"},
+		{Position{2, 9}, "You have a syntax error near: age"},
+		{Position{3, 14}, "unexpected end of statement"},
+		{Position{5, 7}, "unexpected end of statement"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors:\n%v", len(errs), err)
+	}
+	for i, w := range want {
+		if errs[i].Pos != w.pos || errs[i].Msg != w.msg {
+			t.Fatalf("error %d: got %v %s, want %v %s", i, errs[i].Pos, errs[i].Msg, w.pos, w.msg)
+		}
+	}
+	if len(roots) != 2 || roots[0].Name != "find" || roots[1].Name != "update" {
+		t.Fatalf("roots: %v", roots)
+	}
+	// errors.As也能得到第一个错误
+	var se *SyntaxError
+	if !errors.As(err, &se) || se != errs[0] {
+		t.Fatalf("first error: %v", se)
+	}
+}
+
+// 词法错误之后的内容不再解析，之前的语句仍然报告错误
+func TestParse_LexError(t *testing.T) {
+	roots, err := Parse("find a b; insert c 1; find 'x")
+	errs, ok := err.(SyntaxErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("got %v", err)
+	}
+	if errs[0].Msg != "You have a syntax error near: b" || errs[1].Msg != "unterminated string" {
+		t.Fatalf("got %v", err)
+	}
+	if len(roots) != 1 || roots[0].Name != "insert" {
+		t.Fatalf("roots: %v", roots)
+	}
+	if _, err := Format("find a b; insert c; drop x"); err == nil || len(err.(SyntaxErrors)) != 3 {
+		t.Fatalf("format: %v", err)
+	}
+}
+
+// 上下文关键字在其它位置可以作为关键字、value使用
+func TestParse_ContextualKeywords(t *testing.T) {
+	db := Open(index.New(3))
+	script := "insert order 1; insert limit 2; insert desc 3; insert between 4; insert prefix 5; insert AND by"
+	if _, err := Exec(db, script); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]interface{}{"order": int64(1), "limit": int64(2), "desc": int64(3), "between": int64(4), "prefix": int64(5), "AND": "by"} {
+		if ret, err := Exec(db, "find "+key); err != nil || ret.Value != want {
+			t.Fatalf("find %s: got %v, %v", key, ret, err)
+		}
+	}
+	ret, err := Exec(db, "find BETWEEN 'a' AND 'm' ORDER BY KEY DESC LIMIT 2")
+	if err != nil || !reflect.DeepEqual(ret.Keys, []interface{}{"limit", "desc"}) {
+		t.Fatalf("range: got %v, %v", ret, err)
+	}
+	if ret, err := Exec(db, "update order order + limit > 2 AND true; find order"); err != nil || ret.Value != true {
+		t.Fatalf("expression: got %v, %v", ret, err)
+	}
+	// 建表、插入行的关键字
+	script = "insert table 6; insert values 7; insert into foo; insert create 9; insert drop primary; " +
+		"CREATE TABLE values (table int PRIMARY KEY, into string); INSERT INTO values (table, into) VALUES (1, 'a')"
+	if _, err := Exec(db, script); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]interface{}{"table": int64(6), "values": int64(7), "into": "foo", "create": int64(9), "drop": "primary"} {
+		if ret, err := Exec(db, "find "+key); err != nil || ret.Value != want {
+			t.Fatalf("find %s: got %v, %v", key, ret, err)
+		}
+	}
+	if tb, err := db.Catalog().Table("values"); err != nil || tb.Len() != 1 {
+		t.Fatalf("table values: %v, %v", tb, err)
+	}
+	if _, err := Exec(db, "Drop Table values"); err != nil {
+		t.Fatal(err)
+	}
+	// select和表达式中的关键字
+	for i, key := range []string{"select", "from", "where", "or", "not", "is", "as", "index", "on", "group", "having"} {
+		if _, err := Exec(db, fmt.Sprintf("insert %s %d; find %s", key, i, key)); err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+	}
+	if ret, err := Exec(db, "find prefix or"); err != nil || !reflect.DeepEqual(ret.Keys, []interface{}{"or", "order"}) {
+		t.Fatalf("prefix: got %v, %v", ret, err)
+	}
+	if ret, err := Exec(db, "update not NOT is IS NULL OR on > 100; find not"); err != nil || ret.Value != true {
+		t.Fatalf("expression: got %v, %v", ret, err)
+	}
+	script = "create table t (id int primary key, on int); insert into t values (1, 10), (2, 20), (3, 20); " +
+		"SELECT on AS where, count(*) AS from FROM t AS group WHERE NOT id = 1 GROUP BY on HAVING count(*) > 0"
+	ret, err = Exec(db, script)
+	if err != nil || !reflect.DeepEqual(ret.Columns, []string{"where", "from"}) || !reflect.DeepEqual(ret.Rows, [][]interface{}{{int64(20), int64(2)}}) {
+		t.Fatalf("select: got %v, %v", ret, err)
+	}
+	// 连接和explain的关键字
+	for i, key := range []string{"join", "inner", "left", "outer", "explain"} {
+		if _, err := Exec(db, fmt.Sprintf("insert %s %d; find %s", key, i, key)); err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+	}
+	script = "create table left (id int primary key, inner int); insert into left values (10, 1); " +
+		"select t.id, left.id from t LEFT OUTER JOIN left ON t.id = left.inner JOIN t AS outer ON outer.id = t.id order by t.id"
+	ret, err = Exec(db, script)
+	if err != nil || !reflect.DeepEqual(ret.Rows, [][]interface{}{{int64(1), int64(10)}, {int64(2), nil}, {int64(3), nil}}) {
+		t.Fatalf("join: got %v, %v", ret, err)
+	}
+	if ret, err := Exec(db, "EXPLAIN find explain"); err != nil || ret.Statement != "explain" {
+		t.Fatalf("explain: got %v, %v", ret, err)
+	}
+	// 事务的关键字
+	if _, err := Exec(db, "insert begin 1; insert commit 2; insert rollback 3; BEGIN; delete begin; ROLLBACK"); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]interface{}{"begin": int64(1), "commit": int64(2), "rollback": int64(3)} {
+		if ret, err := Exec(db, "find "+key); err != nil || ret.Value != want {
+			t.Fatalf("find %s: got %v, %v", key, ret, err)
+		}
+	}
+	// 原子修改的关键字
+	if _, err := Exec(db, "insert incr 1; insert decr 2; insert append 'x'; INCR incr; Decr decr decr; append append append"); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]interface{}{"incr": int64(2), "decr": int64(0), "append": "xappend"} {
+		if ret, err := Exec(db, "find "+key); err != nil || ret.Value != want {
+			t.Fatalf("find %s: got %v, %v", key, ret, err)
+		}
+	}
+}
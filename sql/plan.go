@@ -0,0 +1,574 @@
+package sql
+
+import (
+	"HwyDB/catalog"
+	"HwyDB/index"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// 执行select分两步：
+//	1. 语法树 -> 逻辑计划：找到表、检查列名，把where拆成用and连接的条件
+//	2. 逻辑计划 -> 物理算子：根据主键和索引列上的条件选择每个表的访问路径（主键查找、范围扫描、索引扫描、全表扫描），
+//	   有多个表时依次连接（见join.go），再依次加上过滤、聚合、排序、limit和投影
+
+// 行中每一列的名字，用于在表达式中按名字找到列
+type scope struct {
+	cols []scopeColumn
+}
+
+type scopeColumn struct {
+	table string // 表名
+	name  string // 列名
+}
+
+// 表中的列，name是表的别名
+func tableScope(t *catalog.Table, name string) *scope {
+	s := &scope{}
+	for _, c := range t.Columns {
+		s.cols = append(s.cols, scopeColumn{table: name, name: c.Name})
+	}
+	return s
+}
+
+// name可以是 列名 或者 表名.列名，不区分大小写
+func (s *scope) lookup(name string) (int, error) {
+	name = strings.ToLower(name)
+	table, col := "", name
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		table, col = name[:i], name[i+1:]
+	}
+	found := -1
+	for i, c := range s.cols {
+		if c.name != col || (table != "" && c.table != table) {
+			continue
+		}
+		if found >= 0 {
+			return -1, fmt.Errorf("column %s is ambiguous", name)
+		}
+		found = i
+	}
+	if found < 0 {
+		return -1, fmt.Errorf("unknown column %s", name)
+	}
+	return found, nil
+}
+
+// 检查表达式中的列是否都存在
+func (s *scope) check(node *SynatxTreeNode) error {
+	if node.Name == "ident" {
+		_, err := s.lookup(node.Value.(string))
+		return err
+	}
+	for _, c := range node.Child {
+		if err := s.check(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 逻辑计划
+type logicalPlan struct {
+	sources []*source         // from中的表，按连接的顺序
+	scope   *scope            // 所有表的列，连接之后的一行中依次是每个表的列
+	where   []*SynatxTreeNode // 用and连接的条件
+	fields  []*SynatxTreeNode // 输出的表达式
+	columns []string          // 输出的列名
+	orders  []orderKey
+	limit   int64 // 小于0表示不限
+
+	// 有聚合函数或者group by时fields、having、orders中的表达式已经改写成引用聚合的结果
+	aggregate bool
+	groups    []*SynatxTreeNode // group by的表达式
+	aggs      []*SynatxTreeNode // 所有的聚合函数
+	having    *SynatxTreeNode
+}
+
+type orderKey struct {
+	expr *SynatxTreeNode
+	desc bool
+}
+
+// from中的一个表
+type source struct {
+	table  *catalog.Table
+	name   string            // 表的别名，没有别名时是表名
+	scope  *scope            // 这个表的列
+	offset int               // 连接之后的一行中这个表的第一列
+	left   bool              // 是否是left join右边的表
+	on     []*SynatxTreeNode // 连接条件（用and连接的）
+}
+
+// 加入from或者join中的一个表
+func (lp *logicalPlan) addSource(db *DB, node *SynatxTreeNode) (*source, error) {
+	t, err := db.catalog.Table(node.Value.(string))
+	if err != nil {
+		return nil, err
+	}
+	src := &source{table: t, name: t.Name, offset: len(lp.scope.cols)}
+	if as := childNode(node, "as"); as != nil {
+		src.name = as.Value.(string)
+	}
+	for _, other := range lp.sources {
+		if other.name == src.name {
+			return nil, fmt.Errorf("table name %s specified more than once", src.name)
+		}
+	}
+	src.scope = tableScope(t, src.name)
+	lp.scope.cols = append(lp.scope.cols, src.scope.cols...)
+	lp.sources = append(lp.sources, src)
+	return src, nil
+}
+
+func (db *DB) logicalSelect(root *SynatxTreeNode) (*logicalPlan, error) {
+	lp := &logicalPlan{scope: &scope{}, limit: -1}
+	if _, err := lp.addSource(db, childNode(root, "from")); err != nil {
+		return nil, err
+	}
+	var on []*SynatxTreeNode
+	for _, join := range root.Child {
+		if join.Name != "join" {
+			continue
+		}
+		src, err := lp.addSource(db, childNode(join, "table"))
+		if err != nil {
+			return nil, err
+		}
+		src.left = join.Value == "left"
+		src.on = conjuncts(childNode(join, "on").Child[0], nil)
+		on = append(on, src.on...)
+	}
+	if fields := childNode(root, "fields"); fields.Value == "*" {
+		for _, c := range lp.scope.cols {
+			lp.fields = append(lp.fields, &SynatxTreeNode{Name: "ident", Value: c.table + "." + c.name})
+			lp.columns = append(lp.columns, c.name)
+		}
+	} else {
+		for _, f := range fields.Child {
+			lp.fields = append(lp.fields, f.Child[0])
+			lp.columns = append(lp.columns, f.Value.(string))
+		}
+	}
+	if where := childNode(root, "where"); where != nil {
+		lp.where = conjuncts(where.Child[0], nil)
+	}
+	if order := childNode(root, "order by"); order != nil {
+		for _, item := range order.Child {
+			lp.orders = append(lp.orders, orderKey{expr: item.Child[0], desc: item.Value == "desc"})
+		}
+	}
+	if limit := childNode(root, "limit"); limit != nil {
+		lp.limit = limit.Value.(int64)
+	}
+	if group := childNode(root, "group by"); group != nil {
+		lp.groups = group.Child
+	}
+	var having []*SynatxTreeNode
+	if h := childNode(root, "having"); h != nil {
+		lp.having = h.Child[0]
+		having = h.Child
+	}
+	for _, list := range [][]*SynatxTreeNode{lp.fields, lp.where, on, lp.groups, having, lp.orderExprs()} {
+		for _, e := range list {
+			if err := lp.scope.check(e); err != nil {
+				return nil, err
+			}
+			lp.aggregate = lp.aggregate || hasAggregate(e)
+		}
+	}
+	for _, e := range on {
+		if hasAggregate(e) {
+			return nil, fmt.Errorf("aggregate functions are not allowed in on")
+		}
+	}
+	lp.aggregate = lp.aggregate || len(lp.groups) > 0 || lp.having != nil
+	if lp.aggregate {
+		if err := lp.planAggregate(); err != nil {
+			return nil, err
+		}
+	}
+	return lp, nil
+}
+
+// 检查聚合函数的位置，改写聚合之后计算的表达式
+func (lp *logicalPlan) planAggregate() error {
+	for _, list := range []struct {
+		clause string
+		exprs  []*SynatxTreeNode
+	}{{"where", lp.where}, {"group by", lp.groups}} {
+		for _, e := range list.exprs {
+			if hasAggregate(e) {
+				return fmt.Errorf("aggregate functions are not allowed in %s", list.clause)
+			}
+		}
+	}
+	var err error
+	for i, f := range lp.fields {
+		if lp.fields[i], err = lp.rewriteAgg(f); err != nil {
+			return err
+		}
+	}
+	if lp.having != nil {
+		if lp.having, err = lp.rewriteAgg(lp.having); err != nil {
+			return err
+		}
+	}
+	for i, o := range lp.orders {
+		if lp.orders[i].expr, err = lp.rewriteAgg(o.expr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (lp *logicalPlan) orderExprs() []*SynatxTreeNode {
+	exprs := make([]*SynatxTreeNode, len(lp.orders))
+	for i, o := range lp.orders {
+		exprs[i] = o.expr
+	}
+	return exprs
+}
+
+// 把用and连接的条件拆开
+func conjuncts(node *SynatxTreeNode, list []*SynatxTreeNode) []*SynatxTreeNode {
+	if node.Name == "binary" && node.Value == "and" {
+		return conjuncts(node.Child[1], conjuncts(node.Child[0], list))
+	}
+	return append(list, node)
+}
+
+// 生成物理算子
+func (db *DB) physicalSelect(lp *logicalPlan) (operator, error) {
+	ctx := func() *evalContext {
+		return &evalContext{bt: db.bt, now: time.Now(), scope: lp.scope}
+	}
+	pushed, joins, top := lp.placeConds()
+	var op operator
+	sorted, ordered := false, -1
+	if len(lp.sources) == 1 {
+		var orders []orderKey // 按主键排序时可以直接按扫描的顺序输出
+		if !lp.aggregate {
+			orders = lp.orders
+		}
+		access, rest, ok, err := db.accessPath(lp.sources[0], pushed[0], orders)
+		if err != nil {
+			return nil, err
+		}
+		op, sorted, ordered = db.withFilter(lp.sources[0], access, rest), ok, orderedBy(access)
+	} else {
+		var err error
+		if op, err = db.joinSources(lp, pushed, joins); err != nil {
+			return nil, err
+		}
+	}
+	if len(top) > 0 {
+		op = &filter{input: op, conds: top, ctx: ctx()}
+	}
+	if lp.aggregate {
+		agg := aggregator{groups: lp.groups, aggs: lp.aggs, ctx: ctx()}
+		// 输入已经按唯一的group by列排好序时不需要哈希表
+		if i, ok := lp.groupColumn(); ok && i == ordered {
+			op = &streamAggregate{input: op, aggregator: agg}
+		} else {
+			op = &hashAggregate{input: op, aggregator: agg}
+		}
+		if lp.having != nil {
+			op = &filter{input: op, conds: []*SynatxTreeNode{lp.having}, ctx: ctx()}
+		}
+	}
+	if len(lp.orders) > 0 && !sorted {
+		op = &sortRows{input: op, keys: lp.orders, ctx: ctx()}
+	}
+	if lp.limit >= 0 {
+		op = &limitRows{input: op, n: lp.limit}
+	}
+	return &project{input: op, exprs: lp.fields, ctx: ctx()}, nil
+}
+
+// 在访问算子之后过滤这个表上其它的条件
+func (db *DB) withFilter(src *source, access operator, conds []*SynatxTreeNode) operator {
+	if len(conds) == 0 {
+		return access
+	}
+	return &filter{input: access, conds: conds, ctx: &evalContext{bt: db.bt, now: time.Now(), scope: src.scope}}
+}
+
+// 根据一个表上的条件conds，选择主键或者有索引的列上的访问路径，返回访问算子、还需要过滤的条件以及输出是否已经按orders排好序
+// 按以下顺序选择：主键 = 常量、索引列 = 常量、主键的范围、索引列的范围、全表扫描
+func (db *DB) accessPath(src *source, conds []*SynatxTreeNode, orders []orderKey) (op operator, rest []*SynatxTreeNode, sorted bool, err error) {
+	t := src.table
+	ctx := &evalContext{bt: db.bt, now: time.Now()}
+	ranges := make([]*colRange, len(t.Columns)) // 每一列上可以用来查找的条件
+	for _, cond := range conds {
+		b, err := src.colBound(ctx, cond)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if b == nil || (b.col != t.PK && t.IndexOn(b.col) == nil) {
+			rest = append(rest, cond)
+			continue
+		}
+		if ranges[b.col] == nil {
+			ranges[b.col] = &colRange{}
+		}
+		if unused := ranges[b.col].add(b); unused != nil {
+			rest = append(rest, unused.cond)
+		}
+	}
+	// 选中的列，以及它上面的条件
+	col := -1
+	switch {
+	case ranges[t.PK] != nil && ranges[t.PK].eq != nil:
+		col = t.PK
+	case firstRange(ranges, true) >= 0:
+		col = firstRange(ranges, true)
+	case ranges[t.PK] != nil:
+		col = t.PK
+	default:
+		col = firstRange(ranges, false)
+	}
+	for i, cr := range ranges {
+		if cr != nil && i != col {
+			rest = append(rest, cr.conds()...)
+		}
+	}
+	if col < 0 {
+		return &tableScan{table: t, full: true}, rest, false, nil
+	}
+	cr := ranges[col]
+	r := cr.scanRange()
+	if cr.eq != nil { // 相等的条件已经确定了范围，其它的条件交给过滤
+		for _, b := range []*colBound{cr.lo, cr.hi} {
+			if b != nil {
+				rest = append(rest, b.cond)
+			}
+		}
+		if col == t.PK { // 最多一行，不需要排序
+			return &pointLookup{table: t, key: cr.eq.value}, rest, true, nil
+		}
+	}
+	if col != t.PK {
+		return &indexScan{table: t, ix: t.IndexOn(col), r: r}, rest, false, nil
+	}
+	scan := &tableScan{table: t, r: r}
+	// 按主键排序时直接按扫描的顺序输出
+	if len(orders) == 1 && orders[0].expr.Name == "ident" {
+		if i, _ := src.scope.lookup(orders[0].expr.Value.(string)); i == t.PK {
+			scan.r.Desc = orders[0].desc
+			sorted = true
+		}
+	}
+	return scan, rest, sorted, nil
+}
+
+// 访问算子输出的行按哪一列排序，没有顺序时返回-1
+func orderedBy(op operator) int {
+	switch op := op.(type) {
+	case *tableScan:
+		return op.table.PK
+	case *indexScan:
+		return op.ix.Column
+	}
+	return -1
+}
+
+// 只按一列分组时返回这一列
+func (lp *logicalPlan) groupColumn() (int, bool) {
+	if len(lp.groups) != 1 || lp.groups[0].Name != "ident" {
+		return -1, false
+	}
+	i, err := lp.scope.lookup(lp.groups[0].Value.(string))
+	return i, err == nil
+}
+
+// 第一个有条件的索引列，eq为true时只找有相等条件的
+func firstRange(ranges []*colRange, eq bool) int {
+	for i, cr := range ranges {
+		if cr != nil && (!eq || cr.eq != nil) {
+			return i
+		}
+	}
+	return -1
+}
+
+// 一列上的条件：列 op 常量
+type colBound struct {
+	col   int
+	op    string
+	value interface{}
+	cond  *SynatxTreeNode
+}
+
+// 一列上用来查找的条件：一个相等的条件，以及最紧的上下界
+type colRange struct {
+	eq, lo, hi *colBound
+}
+
+// 加入一个条件，返回没有用到的条件（被更紧的条件替换的或者多余的相等条件）
+func (cr *colRange) add(b *colBound) *colBound {
+	var cur **colBound
+	switch b.op {
+	case "=":
+		if cr.eq != nil {
+			return b
+		}
+		cr.eq = b
+		return nil
+	case ">", ">=":
+		cur = &cr.lo
+		if *cur != nil && !tighter(b, *cur, 1) {
+			return b
+		}
+	default:
+		cur = &cr.hi
+		if *cur != nil && !tighter(b, *cur, -1) {
+			return b
+		}
+	}
+	old := *cur
+	*cur = b
+	return old
+}
+
+func (cr *colRange) conds() []*SynatxTreeNode {
+	var conds []*SynatxTreeNode
+	for _, b := range []*colBound{cr.eq, cr.lo, cr.hi} {
+		if b != nil {
+			conds = append(conds, b.cond)
+		}
+	}
+	return conds
+}
+
+func (cr *colRange) scanRange() index.ScanRange {
+	var r index.ScanRange
+	if cr.eq != nil {
+		r.Lo, r.Hi = cr.eq.value, cr.eq.value
+		return r
+	}
+	if cr.lo != nil {
+		r.Lo, r.LoOpen = cr.lo.value, cr.lo.op == ">"
+	}
+	if cr.hi != nil {
+		r.Hi, r.HiOpen = cr.hi.value, cr.hi.op == "<"
+	}
+	return r
+}
+
+// 比较b和other哪个范围更小，dir为1时比较下界，-1时比较上界
+func tighter(b, other *colBound, dir int) bool {
+	c, err := compareValues(b.value, other.value)
+	if err != nil {
+		return false
+	}
+	return c*dir > 0 || (c == 0 && (b.op == ">" || b.op == "<"))
+}
+
+var flipOp = map[string]string{"=": "=", "<": ">", "<=": ">=", ">": "<", ">=": "<="}
+
+// cond是 列 比较 常量（或者 常量 比较 列）时返回对应的colBound，否则返回nil
+func (src *source) colBound(ctx *evalContext, cond *SynatxTreeNode) (*colBound, error) {
+	op, _ := cond.Value.(string)
+	if cond.Name != "binary" || flipOp[op] == "" {
+		return nil, nil
+	}
+	col, val := cond.Child[0], cond.Child[1]
+	if col.Name != "ident" {
+		col, val, op = val, col, flipOp[op]
+	}
+	if col.Name != "ident" || !isConst(val) {
+		return nil, nil
+	}
+	i, err := src.scope.lookup(col.Value.(string))
+	if err != nil {
+		return nil, err
+	}
+	v, err := ctx.eval(val)
+	if err != nil {
+		return nil, err
+	}
+	// 只有类型和列相同的常量才能在B+树上查找，其它的交给过滤
+	typ := src.table.Columns[i].Type
+	key, ok := keyValue(typ, v)
+	if f, isFloat := number(v).(float64); !ok && isFloat && typ == catalog.Int {
+		op, key, ok = intBound(op, f)
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &colBound{col: i, op: op, value: key, cond: cond}, nil
+}
+
+// int列和小数比较时换成等价的整数上的条件：age > 9.5即age >= 10，age < 20.5即age <= 20
+// 等于小数、NaN或者超出int64范围时返回false，交给过滤
+func intBound(op string, f float64) (string, interface{}, bool) {
+	if !(f > math.MinInt64 && f < math.MaxInt64) {
+		return "", nil, false
+	}
+	switch {
+	case f == math.Trunc(f):
+		return op, int64(f), true
+	case op == ">" || op == ">=":
+		return ">=", int64(math.Ceil(f)), true
+	case op == "<" || op == "<=":
+		return "<=", int64(math.Floor(f)), true
+	}
+	return "", nil, false
+}
+
+// 把v转换成类型为typ的列在B+树中的关键字，类型不同（或者v是null）时返回false
+func keyValue(typ catalog.Type, v interface{}) (interface{}, bool) {
+	switch v := number(v).(type) {
+	case int64:
+		switch typ {
+		case catalog.Int:
+			return v, true
+		case catalog.Float:
+			return float64(v), true
+		}
+	case float64:
+		return v, typ == catalog.Float
+	case string:
+		return v, typ == catalog.String
+	case bool:
+		return v, typ == catalog.Bool
+	}
+	return nil, false
+}
+
+// 表达式中没有标识符
+func isConst(node *SynatxTreeNode) bool {
+	if node.Name == "ident" {
+		return false
+	}
+	for _, c := range node.Child {
+		if !isConst(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// 执行select
+func (db *DB) selectRows(root *SynatxTreeNode) (*Result, error) {
+	lp, err := db.logicalSelect(root)
+	if err != nil {
+		return nil, fmt.Errorf("select: %w", err)
+	}
+	op, err := db.physicalSelect(lp)
+	if err != nil {
+		return nil, fmt.Errorf("select: %w", err)
+	}
+	ret := &Result{Statement: "select", Columns: lp.columns, Rows: make([][]interface{}, 0)}
+	err = op.run(func(row []interface{}) (bool, error) {
+		ret.Rows = append(ret.Rows, row)
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select: %w", err)
+	}
+	return ret, nil
+}
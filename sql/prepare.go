@@ -0,0 +1,157 @@
+package sql
+
+import "fmt"
+
+// 预编译的语句：只做一次词法分析和语法分析，执行时把参数绑定到占位符上
+// 参数只作为值使用，不会被当作语句解析，不需要用字符串拼接语句
+// 占位符可以出现在表达式、关键字、范围查询的边界、prefix和limit中：
+//
+//	find ?; insert into users values (?, ?, ?); select name from users where age > $1 limit $2
+//
+// Stmt在创建之后不会被修改，用DB.Prepare创建的可以同时在多个goroutine中执行；
+// 用Session.Prepare创建的在会话中执行，和会话一样不能同时在多个goroutine中使用
+type Stmt struct {
+	db    *DB
+	sess  *Session          // 用Session.Prepare创建时在这个会话中执行，否则每次在新的会话中执行
+	roots []*SynatxTreeNode // 每条语句的语法树
+	n     int               // 参数的个数
+}
+
+// 解析query中的语句（可以有多条以;分隔的语句，参数按整个query编号），有语法错误时返回所有的错误
+func (db *DB) Prepare(query string) (*Stmt, error) {
+	roots, tr, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(roots) == 0 {
+		return nil, tr.errorf(tr.eof(), "empty statement")
+	}
+	return &Stmt{db: db, roots: roots, n: tr.params}, nil
+}
+
+// 参数的个数
+func (s *Stmt) NumInput() int {
+	return s.n
+}
+
+// 绑定参数之后按顺序执行所有的语句，返回最后一条语句的结果；出错时停止（之前的语句已经执行）
+// 不在会话中时和Exec相同，最后没有提交的事务会回滚
+// 参数可以是nil、bool、整数、浮点数、string或者[]byte
+func (s *Stmt) Exec(args ...interface{}) (*Result, error) {
+	if len(args) != s.n {
+		return nil, fmt.Errorf("expected %d arguments, got %d", s.n, len(args))
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		v, ok := paramValue(arg)
+		if !ok {
+			return nil, fmt.Errorf("argument $%d: unsupported type %T", i+1, arg)
+		}
+		values[i] = v
+	}
+	sess := s.sess
+	if sess == nil {
+		sess = s.db.NewSession()
+	}
+	ret, err := sess.execRoots(s.roots, values)
+	if s.sess == nil {
+		if ferr := sess.finish(); err == nil && ferr != nil {
+			return nil, ferr
+		}
+	}
+	return ret, err
+}
+
+func (s *Session) execRoots(roots []*SynatxTreeNode, args []interface{}) (*Result, error) {
+	var ret *Result
+	for _, root := range roots {
+		bound, err := bindParams(root, args)
+		if err != nil {
+			return nil, err
+		}
+		if ret, err = s.exec(bound); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// 把参数转换成语句中的值
+func paramValue(arg interface{}) (interface{}, bool) {
+	switch v := number(arg).(type) {
+	case nil, bool, int64, float64, string:
+		return v, true
+	case []byte:
+		return string(v), true
+	}
+	return nil, false
+}
+
+// 值的类型（SynatxTreeNode.ValueType）
+func valueType(v interface{}) int {
+	switch v.(type) {
+	case int64:
+		return IntType
+	case float64:
+		return FloatType
+	case bool:
+		return BoolType
+	case nil:
+		return NullType
+	}
+	return StringType
+}
+
+// 把语法树中的占位符换成参数，只复制从根到占位符路径上的节点，缓存的语法树不会被修改
+func bindParams(node *SynatxTreeNode, args []interface{}) (*SynatxTreeNode, error) {
+	if node.ValueType == ParamType {
+		i := node.Value.(int)
+		if err := checkParam(node.Name, args[i]); err != nil {
+			return nil, fmt.Errorf("argument $%d: %w", i+1, err)
+		}
+		out := *node
+		out.Value, out.ValueType = args[i], valueType(args[i])
+		return &out, nil
+	}
+	var out *SynatxTreeNode
+	for i, c := range node.Child {
+		b, err := bindParams(c, args)
+		if err != nil {
+			return nil, err
+		}
+		if b == c {
+			continue
+		}
+		if out == nil {
+			cp := *node
+			cp.Child = append([]*SynatxTreeNode(nil), node.Child...)
+			out = &cp
+		}
+		out.Child[i] = b
+	}
+	if out == nil {
+		return node, nil
+	}
+	return out, nil
+}
+
+// 检查参数能否用在名为name的节点中（和解析字面量时的检查相同）
+func checkParam(name string, v interface{}) error {
+	switch name {
+	case "limit":
+		if n, ok := v.(int64); !ok || n < 0 {
+			return fmt.Errorf("limit must be a non-negative integer")
+		}
+	case "prefix":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("prefix needs a string")
+		}
+	case "key", ">", ">=", "<", "<=":
+		switch v.(type) {
+		case int64, float64, string:
+		default:
+			return fmt.Errorf("key must be a number or a string, got %T", v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package sql
+
+import (
+	"HwyDB/index"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStmt_Exec(t *testing.T) {
+	db := Open(index.New(3))
+	if _, err := Exec(db, "create table users (id int primary key, name string, score float)"); err != nil {
+		t.Fatal(err)
+	}
+	insert, err := db.Prepare("insert into users values (?, ?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if insert.NumInput() != 3 {
+		t.Fatalf("NumInput: %d", insert.NumInput())
+	}
+	// 参数中的引号、分号只是值
+	names := []string{"ann", "it's", "x'); drop table users; --", "$1 ?"}
+	for i, name := range names {
+		if _, err := insert.Exec(i+1, name, float32(i)/2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := insert.Exec(10, []byte("bytes"), nil); err != nil {
+		t.Fatal(err)
+	}
+	sel, err := db.Prepare("select name from users where id >= $1 and score < $2 or id = $1 * 10 order by id limit $3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sel.NumInput() != 3 {
+		t.Fatalf("NumInput: %d", sel.NumInput())
+	}
+	ret, err := sel.Exec(2, 1.5, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]interface{}{{"it's"}, {"x'); drop table users; --"}}; !reflect.DeepEqual(ret.Rows, want) {
+		t.Fatalf("got %v", ret.Rows)
+	}
+	// 同一个Stmt可以用不同的参数执行多次
+	if ret, _ := sel.Exec(1, 0.0, 1); !reflect.DeepEqual(ret.Rows, [][]interface{}{{"bytes"}}) {
+		t.Fatalf("got %v", ret.Rows)
+	}
+	if ret, _ := sel.Exec(1, 100, 0); len(ret.Rows) != 0 {
+		t.Fatalf("limit 0: %v", ret.Rows)
+	}
+
+	// 关键字上的语句
+	kv, err := db.Prepare("insert ? ?; incr ? $n; find ?")
+	if err == nil {
+		t.Fatal("mixed placeholders should fail")
+	}
+	kv, err = db.Prepare("insert ? ? * 2; incr ? ?; find ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret, err := kv.Exec("counter", 5, "counter", 3, "counter"); err != nil || ret.Value != int64(13) {
+		t.Fatalf("got %v, %v", ret, err)
+	}
+	scan, err := db.Prepare("find between ? and ? order by key desc limit ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Exec(db, "insert a 1; insert b 2; insert c 3")
+	if ret, err := scan.Exec("a", "c", 2); err != nil || !reflect.DeepEqual(ret.Keys, []interface{}{"c", "b"}) {
+		t.Fatalf("got %v, %v", ret, err)
+	}
+	prefix, err := db.Prepare("find prefix ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret, err := prefix.Exec("co"); err != nil || !reflect.DeepEqual(ret.Keys, []interface{}{"counter"}) {
+		t.Fatalf("got %v, %v", ret, err)
+	}
+	explain, err := db.Prepare("explain select name from users where id = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret, err := explain.Exec(2); err != nil || !strings.Contains(ret.Value.(string), "point lookup on users (id = 2)") {
+		t.Fatalf("got %v, %v", ret, err)
+	}
+
+	errs := []struct {
+		stmt *Stmt
+		args []interface{}
+		want string
+	}{
+		{insert, []interface{}{1, "a"}, "expected 3 arguments, got 2"},
+		{insert, []interface{}{1, "a", struct{}{}}, "argument $3: unsupported type struct {}"},
+		{insert, []interface{}{1, "dup", 1.0}, "insert into: users: key is exist"},
+		{sel, []interface{}{1, 1, -1}, "argument $3: limit must be a non-negative integer"},
+		{sel, []interface{}{1, 1, "x"}, "argument $3: limit must be a non-negative integer"},
+		{scan, []interface{}{true, "c", 1}, "argument $1: key must be a number or a string, got bool"},
+		{prefix, []interface{}{1}, "argument $1: prefix needs a string"},
+	}
+	for _, e := range errs {
+		if _, err := e.stmt.Exec(e.args...); err == nil || err.Error() != e.want {
+			t.Fatalf("%v: got %v, want %s", e.args, err, e.want)
+		}
+	}
+	// 缓存的语法树没有被绑定修改
+	if ret, err := sel.Exec(4, 100, 1); err != nil || !reflect.DeepEqual(ret.Rows, [][]interface{}{{"$1 ?"}}) {
+		t.Fatalf("got %v, %v", ret, err)
+	}
+}
+
+func TestPrepare_Error(t *testing.T) {
+	db := Open(index.New(3))
+	for sql, want := range map[string]string{
+		"find ?":                  "placeholder ? has no argument, use Prepare",
+		"insert a 1; find $1":     "placeholder $1 has no argument, use Prepare",
+		"select 1 from t where ?": "placeholder ? has no argument, use Prepare",
+	} {
+		if _, err := Exec(db, sql); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: got %v, want %s", sql, err, want)
+		}
+	}
+	for _, sql := range []string{"find $0", "find $", "find $x", "find ? and", "select ? from", "create table ? (id int primary key)", "find $1 ?"} {
+		if _, err := db.Prepare(sql); err == nil {
+			t.Fatalf("%s should fail", sql)
+		}
+	}
+	s, err := db.Prepare("find $3; find $1")
+	if err != nil || s.NumInput() != 3 {
+		t.Fatalf("NumInput: %v, %v", s, err)
+	}
+}
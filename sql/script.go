@@ -0,0 +1,137 @@
+package sql
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// 脚本中一条语句的执行结果
+type StmtResult struct {
+	Stmt   string   // 语句（去掉了前面的注释和首尾空白）
+	Pos    Position // 语句在脚本中的位置
+	Result *Result
+	Err    error
+}
+
+// 逐条读取并执行r中的语句（以;分隔，可以有--和/* */注释），每条语句执行之后调用fn，fn返回false时停止。
+// 语句出错不会停止执行（事务中出错的语句没有修改，事务继续），错误通过StmtResult.Err传给fn；只返回读取r时的错误
+// 脚本在一个新的会话中执行，最后还没有提交的事务回滚，ErrTxNotFinished作为最后一条结果传给fn
+func ExecScript(db *DB, r io.Reader, fn func(sr *StmtResult) bool) error {
+	s := db.NewSession()
+	stopped := false
+	err := s.ExecScript(r, func(sr *StmtResult) bool {
+		stopped = !fn(sr)
+		return !stopped
+	})
+	if ferr := s.finish(); ferr != nil && !stopped {
+		fn(&StmtResult{Err: ferr})
+	}
+	return err
+}
+
+// text中的语句是否都已经结束：最后一个;（不在引号、注释中）之后只有空白和注释
+// 交互输入时用来判断是否还要继续读下一行，引号、/* */注释没有结束时返回false
+func Complete(text string) bool {
+	sc := &scriptScanner{r: bufio.NewReader(strings.NewReader(text)), pos: Position{Row: 1, Col: 1}}
+	for {
+		c, err := sc.scan()
+		if err != nil {
+			lex := NewLex(c.text)
+			return lex.err == nil && lex.tokens[0].typ == EOF
+		}
+	}
+}
+
+// 执行一段语句，只有空白、注释时返回nil
+func (s *Session) execChunk(c chunk) *StmtResult {
+	lex := newLexAt(c.text, c.base, c.prefix)
+	sr := &StmtResult{Pos: c.base}
+	if len(lex.tokens) > 0 {
+		first := lex.tokens[0]
+		sr.Stmt, sr.Pos = strings.TrimSpace(c.text[first.off:]), first.pos
+	}
+	if lex.err != nil {
+		if sr.Stmt == "" {
+			sr.Stmt = strings.TrimSpace(c.text)
+		}
+		sr.Err = lex.err
+		return sr
+	}
+	tr := newLexReader(lex)
+	if !tr.skipEmpty() {
+		return nil
+	}
+	sr.Result, sr.Err = s.execTokens(tr)
+	return sr
+}
+
+// 脚本中的一段语句
+type chunk struct {
+	text   string
+	base   Position // text在脚本中的起始位置
+	prefix string   // 脚本中同一行在text之前的内容
+}
+
+// 按;拆分脚本，忽略引号和注释中的;
+type scriptScanner struct {
+	r    *bufio.Reader
+	pos  Position        // 下一个字符的位置
+	line strings.Builder // 当前行已经读到的内容
+}
+
+// 读取到下一个;（包括;），读完时返回剩下的内容和io.EOF
+func (s *scriptScanner) scan() (chunk, error) {
+	c := chunk{base: s.pos, prefix: s.line.String()}
+	var sb strings.Builder
+	var quote, prev rune
+	escaped, lineComment, blockComment := false, false, false
+	for {
+		r, _, err := s.r.ReadRune()
+		if err != nil {
+			c.text = sb.String()
+			return c, err
+		}
+		sb.WriteRune(r)
+		s.advance(r)
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == '\\' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case lineComment:
+			lineComment = r != '\n'
+		case blockComment:
+			if r == '/' && prev == '*' {
+				blockComment = false
+				r = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '-' && prev == '-':
+			lineComment = true
+		case r == '*' && prev == '/':
+			blockComment = true
+			r = 0 // /*/ 中的*不是注释的结尾
+		case r == ';':
+			c.text = sb.String()
+			return c, nil
+		}
+		prev = r
+	}
+}
+
+func (s *scriptScanner) advance(r rune) {
+	if r == '\n' {
+		s.pos.Row++
+		s.pos.Col = 1
+		s.line.Reset()
+		return
+	}
+	s.pos.Col++
+	s.line.WriteRune(r)
+}
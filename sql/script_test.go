@@ -0,0 +1,118 @@
+package sql
+
+import (
+	"HwyDB/index"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExecScript(t *testing.T) {
+	script := `-- 初始数据
+insert name 'hwy;wu'; insert age 29;
+/* 多行
+   注释; */
+insert age 30;
+find age; find name --结尾的注释
+;
+insert x 1; find a b;
+-- 最后只有注释`
+	db := Open(index.New(3))
+	var got []*StmtResult
+	err := ExecScript(db, strings.NewReader(script), func(sr *StmtResult) bool {
+		got = append(got, sr)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		stmt string
+		pos  Position
+		err  bool
+	}{
+		{"insert name 'hwy;wu';", Position{2, 1}, false},
+		{"insert age 29;", Position{2, 23}, false},
+		{"insert age 30;", Position{5, 1}, true},
+		{"find age;", Position{6, 1}, false},
+		{"find name --结尾的注释\n;", Position{6, 11}, false},
+		{"insert x 1;", Position{8, 1}, false},
+		{"find a b;", Position{8, 13}, true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d statements, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Stmt != w.stmt || got[i].Pos != w.pos || (got[i].Err != nil) != w.err {
+			t.Fatalf("statement %d: got %+v, want %+v", i, got[i], w)
+		}
+	}
+	if !errors.Is(got[2].Err, index.ErrKeyExist) || got[3].Result.Value != int64(29) || got[4].Result.Value != "hwy;wu" {
+		t.Fatalf("got %+v, %+v, %+v", got[2], got[3].Result, got[4].Result)
+	}
+	var se *SyntaxError
+	if !errors.As(got[6].Err, &se) {
+		t.Fatalf("got %v, want SyntaxError", got[6].Err)
+	}
+	// 错误中的行号、列号是在整个脚本中的位置
+	wantErr := "syntax error at line 8, column 20: You have a syntax error near: b\ninsert x 1; find a b;\n                   ^"
+	if se.Error() != wantErr {
+		t.Fatalf("got\n%s\nwant\n%s", se.Error(), wantErr)
+	}
+}
+
+func TestExecScript_Stop(t *testing.T) {
+	db := Open(index.New(3))
+	n := 0
+	err := ExecScript(db, strings.NewReader("insert a 1; insert b 2; insert c 3;"), func(sr *StmtResult) bool {
+		n++
+		return n < 2
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("n = %d, err = %v", n, err)
+	}
+	if ret, _ := Exec(db, "find c"); ret.Found {
+		t.Fatal("c should not be inserted")
+	}
+}
+
+func TestExecScript_LexError(t *testing.T) {
+	db := Open(index.New(3))
+	var got []*StmtResult
+	ExecScript(db, strings.NewReader("insert a 1;\ninsert b 'abc;\ninsert c 3;"), func(sr *StmtResult) bool {
+		got = append(got, sr)
+		return true
+	})
+	// 没有结束的字符串一直到脚本结尾
+	if len(got) != 2 || got[0].Err != nil || got[1].Err == nil || got[1].Pos != (Position{2, 1}) {
+		t.Fatalf("got %+v", got)
+	}
+	if !strings.Contains(got[1].Err.Error(), "line 2, column 10: unterminated string") {
+		t.Fatalf("got %v", got[1].Err)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	cases := map[string]bool{
+		"":                    true,
+		"find a;":             true,
+		"find a; -- note\n  ": true,
+		"find a; /* note */":  true,
+		"-- note\n":           true,
+		"find a":              false,
+		"find a; find b":      false,
+		"insert a 'x;":        false,
+		"insert a 'x;\ny';":   true,
+		"insert a 'it''s;":    false,
+		"find a -- note;":     false,
+		"find a -- note;\n;":  true,
+		"find a /* ; */":      false,
+		"find a; /* note":     false,
+		"insert a \"\\\";":    false,
+	}
+	for text, want := range cases {
+		if got := Complete(text); got != want {
+			t.Fatalf("%q: got %v, want %v", text, got, want)
+		}
+	}
+}
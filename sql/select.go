@@ -0,0 +1,236 @@
+package sql
+
+import (
+	"strings"
+)
+
+// select语句：
+//
+//	select   = "select" fields "from" table { join } [ "where" expr ] [ "group" "by" expr { "," expr } ] [ "having" expr ]
+//	           [ "order" "by" item { "," item } ] [ "limit" <n> ]
+//	table    = <table> [ [ "as" ] <alias> ]
+//	join     = [ "inner" | "left" [ "outer" ] ] "join" table "on" expr
+//	fields   = "*" | field { "," field }
+//	field    = expr [ "as" <name> ]
+//	item     = expr [ "asc" | "desc" ]
+//
+// 语法树：
+//
+//	select    Child是fields、from、join（可以有多个）以及可能有的where、group by、having、order by、limit节点
+//	fields    Value是"*"（所有列）或者nil，Child是field节点（Value是输出的列名，Child是表达式）
+//	from      Value是表名，有别名时Child是as节点（Value是别名）
+//	join      Value是inner或left，Child是table节点（和from相同）和on节点（Child是连接条件）
+//	where     Child是条件表达式
+//	group by  Child是分组的表达式
+//	having    Child是分组之后的条件表达式
+//	order by  Child是item节点（Value是asc或desc，Child是表达式）
+//	limit     Value是行数
+func selectParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	t := tr.read()
+	root := &SynatxTreeNode{Name: "select", Pos: t.pos}
+	fields, err := fieldsParser(tr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := expectKeyWord(tr, "from"); err != nil {
+		return nil, err
+	}
+	from, err := tableRefParser(tr, "from")
+	if err != nil {
+		return nil, err
+	}
+	root.Child = []*SynatxTreeNode{fields, from}
+	for {
+		join, err := joinParser(tr)
+		if err != nil {
+			return nil, err
+		}
+		if join == nil {
+			break
+		}
+		root.Child = append(root.Child, join)
+	}
+	where, err := whereParser(tr)
+	if err != nil {
+		return nil, err
+	}
+	if where != nil {
+		root.Child = append(root.Child, where)
+	}
+	if g := tr.peek(); isWord(g, "group") {
+		tr.read()
+		if _, err := expectKeyWord(tr, "by"); err != nil {
+			return nil, err
+		}
+		group := &SynatxTreeNode{Name: "group by", Pos: g.pos}
+		for {
+			expr, err := exprParser(tr)
+			if err != nil {
+				return nil, err
+			}
+			group.Child = append(group.Child, expr)
+			if c := tr.peek(); c.typ != Symbol || c.lit != "," {
+				break
+			}
+			tr.read()
+		}
+		root.Child = append(root.Child, group)
+	}
+	if h := tr.peek(); isWord(h, "having") {
+		tr.read()
+		cond, err := exprParser(tr)
+		if err != nil {
+			return nil, err
+		}
+		root.Child = append(root.Child, &SynatxTreeNode{Name: "having", Pos: h.pos, Child: []*SynatxTreeNode{cond}})
+	}
+	if o := tr.peek(); isWord(o, "order") {
+		order, err := orderByParser(tr)
+		if err != nil {
+			return nil, err
+		}
+		root.Child = append(root.Child, order)
+	}
+	if l := tr.peek(); isWord(l, "limit") {
+		limit, err := limitParser(tr)
+		if err != nil {
+			return nil, err
+		}
+		root.Child = append(root.Child, limit)
+	}
+	return root, nil
+}
+
+// [where <expr>]，没有where时返回nil
+func whereParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	w := tr.peek()
+	if !isWord(w, "where") {
+		return nil, nil
+	}
+	tr.read()
+	cond, err := exprParser(tr)
+	if err != nil {
+		return nil, err
+	}
+	return &SynatxTreeNode{Name: "where", Pos: w.pos, Child: []*SynatxTreeNode{cond}}, nil
+}
+
+// 表名之后的子句开头的上下文关键字，不能省略as作为表的别名
+var clauseWords = map[string]bool{
+	"join": true, "inner": true, "left": true, "on": true,
+	"where": true, "group": true, "having": true, "order": true, "limit": true,
+}
+
+// 表名以及可能有的别名
+func tableRefParser(tr *TokenReader, name string) (*SynatxTreeNode, error) {
+	node, err := identParser(tr, name)
+	if err != nil {
+		return nil, err
+	}
+	as := tr.peek()
+	if isWord(as, "as") {
+		tr.read()
+	} else if as.typ != Identifier || clauseWords[strings.ToLower(as.lit)] {
+		return node, nil
+	}
+	alias, err := identParser(tr, "as")
+	if err != nil {
+		return nil, err
+	}
+	alias.Pos = as.pos
+	node.Child = []*SynatxTreeNode{alias}
+	return node, nil
+}
+
+// [inner | left [outer]] join table on expr，没有join时返回nil
+func joinParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	t := tr.peek()
+	node := &SynatxTreeNode{Name: "join", Value: "inner", Pos: t.pos}
+	switch {
+	case isWord(t, "join"):
+	case isWord(t, "inner"):
+		tr.read()
+	case isWord(t, "left"):
+		tr.read()
+		node.Value = "left"
+		if o := tr.peek(); isWord(o, "outer") {
+			tr.read()
+		}
+	default:
+		return nil, nil
+	}
+	if _, err := expectKeyWord(tr, "join"); err != nil {
+		return nil, err
+	}
+	table, err := tableRefParser(tr, "table")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := expectKeyWord(tr, "on"); err != nil {
+		return nil, err
+	}
+	on, err := exprParser(tr)
+	if err != nil {
+		return nil, err
+	}
+	node.Child = []*SynatxTreeNode{table, {Name: "on", Pos: on.Pos, Child: []*SynatxTreeNode{on}}}
+	return node, nil
+}
+
+func fieldsParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	node := &SynatxTreeNode{Name: "fields", Pos: tr.peek().pos}
+	if t := tr.peek(); t.typ == Symbol && t.lit == "*" {
+		tr.read()
+		node.Value = "*"
+		return node, nil
+	}
+	for {
+		start := tr.peek()
+		expr, err := exprParser(tr)
+		if err != nil {
+			return nil, err
+		}
+		field := &SynatxTreeNode{Name: "field", Value: tr.text(start), Pos: start.pos, Child: []*SynatxTreeNode{expr}}
+		if expr.Name == "ident" {
+			field.Value = strings.ToLower(expr.Value.(string))
+		}
+		if as := tr.peek(); isWord(as, "as") {
+			tr.read()
+			alias, err := identParser(tr, "as")
+			if err != nil {
+				return nil, err
+			}
+			field.Value = alias.Value
+		}
+		node.Child = append(node.Child, field)
+		if c := tr.peek(); c.typ != Symbol || c.lit != "," {
+			return node, nil
+		}
+		tr.read()
+	}
+}
+
+// order by item, ...
+func orderByParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	t := tr.read()
+	if _, err := expectKeyWord(tr, "by"); err != nil {
+		return nil, err
+	}
+	node := &SynatxTreeNode{Name: "order by", Pos: t.pos}
+	for {
+		start := tr.peek()
+		expr, err := exprParser(tr)
+		if err != nil {
+			return nil, err
+		}
+		item := &SynatxTreeNode{Name: "item", Value: "asc", Pos: start.pos, Child: []*SynatxTreeNode{expr}}
+		if d := tr.peek(); isWord(d, "asc") || isWord(d, "desc") {
+			item.Value = strings.ToLower(tr.read().lit)
+		}
+		node.Child = append(node.Child, item)
+		if c := tr.peek(); c.typ != Symbol || c.lit != "," {
+			return node, nil
+		}
+		tr.read()
+	}
+}
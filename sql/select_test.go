@@ -0,0 +1,324 @@
+package sql
+
+import (
+	"HwyDB/index"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func selectDB(t *testing.T) *DB {
+	db := Open(index.New(3))
+	_, err := Exec(db, `create table users (id int primary key, name string, age int, score float);
+		insert into users values (1, 'ann', 30, 1.5), (2, 'bob', 20, null), (3, 'cat', 30, 2.5),
+			(4, 'dan', 40, 0.5), (5, 'eve', null, 3.0)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestExec_Select(t *testing.T) {
+	db := selectDB(t)
+	cases := []struct {
+		sql     string
+		columns []string
+		rows    [][]interface{}
+	}{
+		{"select * from users where id = 2", []string{"id", "name", "age", "score"},
+			[][]interface{}{{int64(2), "bob", int64(20), nil}}},
+		{"select name from users where id > 3", []string{"name"},
+			[][]interface{}{{"dan"}, {"eve"}}},
+		{"select name from users where 3 >= id and id > 1 order by id desc", []string{"name"},
+			[][]interface{}{{"cat"}, {"bob"}}},
+		{"select name, age * 2 as double from users where age = 30", []string{"name", "double"},
+			[][]interface{}{{"ann", int64(60)}, {"cat", int64(60)}}},
+		{"select upper(name), AGE from users where age is null or score < 1", []string{"upper(name)", "age"},
+			[][]interface{}{{"DAN", int64(40)}, {"EVE", nil}}},
+		{"select id from users order by age desc, id limit 3", []string{"id"},
+			[][]interface{}{{int64(4)}, {int64(1)}, {int64(3)}}},
+		{"select id from users order by score", []string{"id"},
+			[][]interface{}{{int64(2)}, {int64(4)}, {int64(1)}, {int64(3)}, {int64(5)}}},
+		{"select id from users where id = 1 and id = 2", []string{"id"}, [][]interface{}{}},
+		{"select id from users where id >= 2.5 limit 0", []string{"id"}, [][]interface{}{}},
+		{"select id from users where id >= 2.5", []string{"id"}, [][]interface{}{{int64(3)}, {int64(4)}, {int64(5)}}},
+	}
+	for _, c := range cases {
+		ret, err := Exec(db, c.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", c.sql, err)
+		}
+		if !reflect.DeepEqual(ret.Columns, c.columns) || !reflect.DeepEqual(ret.Rows, c.rows) {
+			t.Fatalf("%s: got %v %v, want %v %v", c.sql, ret.Columns, ret.Rows, c.columns, c.rows)
+		}
+	}
+}
+
+func TestExec_SelectError(t *testing.T) {
+	db := selectDB(t)
+	cases := map[string]string{
+		"select nick from users":                 "select: unknown column nick",
+		"select id from users order by name + 1": "select: cannot apply + to string and int64",
+		"select id from users where id = 'x'":    "select: cannot compare int64 and string",
+	}
+	for sql, want := range cases {
+		if _, err := Exec(db, sql); err == nil || err.Error() != want {
+			t.Fatalf("%s: got %v, want %s", sql, err, want)
+		}
+	}
+	for _, sql := range []string{"select from users", "select id users", "select id from users limit -1", "select id from users order id"} {
+		var se *SyntaxError
+		if _, err := Exec(db, sql); !errors.As(err, &se) {
+			t.Fatalf("%s: got %v, want SyntaxError", sql, err)
+		}
+	}
+}
+
+// 检查选择的访问路径
+func TestSelect_AccessPath(t *testing.T) {
+	db := selectDB(t)
+	type accessCase struct {
+		where  string
+		access string
+		rest   int
+		sorted bool
+	}
+	check := func(cases []accessCase) {
+		t.Helper()
+		for _, c := range cases {
+			tr := newLexReader(NewLex("select id from users where " + c.where + " order by age"))
+			root, err := tr.buildAST()
+			if err != nil {
+				t.Fatal(err)
+			}
+			lp, err := db.logicalSelect(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			op, rest, sorted, err := db.accessPath(lp.sources[0], lp.where, lp.orders)
+			if err != nil {
+				t.Fatal(err)
+			}
+			access := "point"
+			switch op := op.(type) {
+			case *tableScan:
+				access = "range"
+				if op.full {
+					access = "full"
+				}
+			case *indexScan:
+				access = "index " + op.ix.Name
+			}
+			if access != c.access || len(rest) != c.rest || sorted != c.sorted {
+				t.Fatalf("%s: got %s %d %v, want %s %d %v", c.where, access, len(rest), sorted, c.access, c.rest, c.sorted)
+			}
+		}
+	}
+	check([]accessCase{
+		{"id = 1", "point", 0, true},
+		{"1 = id and age > 3", "point", 1, true},
+		{"id > 1 and id <= 3", "range", 0, false},
+		{"id > 1 and id > 2 and id >= 2", "range", 2, false},
+		{"age = 1", "full", 1, false},
+		{"id = age", "full", 1, false},
+		{"id = 'a'", "full", 1, false},
+		{"id > 1 or id < 0", "full", 1, false},
+	})
+	if _, err := Exec(db, "create index idx_age on users (age); create index idx_name on users (name)"); err != nil {
+		t.Fatal(err)
+	}
+	check([]accessCase{
+		{"id = 1 and age = 30", "point", 1, true},
+		{"age = 30", "index idx_age", 0, false},
+		{"id > 1 and name = 'a' and age > 3", "index idx_name", 2, false},
+		{"id > 1 and age > 3", "range", 1, false},
+		{"age > 3 and age < 40 and score > 1", "index idx_age", 1, false},
+		{"30 <= age and name > 'b'", "index idx_name", 1, false},
+		{"age = 2.5", "full", 1, false},
+		{"score = 1", "full", 1, false},
+	})
+}
+
+// 和逐行过滤、排序的结果比较，覆盖分批扫描表和索引
+func TestExec_SelectRandom(t *testing.T) {
+	db := Open(index.New(4))
+	if _, err := Exec(db, "create table t (k int primary key, v int); create index idx_v on t (v)"); err != nil {
+		t.Fatal(err)
+	}
+	rows := make(map[int64]int64)
+	for len(rows) < 1000 {
+		k, v := rand.Int63n(5000), rand.Int63n(100)
+		if _, ok := rows[k]; ok {
+			continue
+		}
+		rows[k] = v
+		if _, err := Exec(db, fmt.Sprintf("insert into t values (%d, %d)", k, v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		lo, hi, v := rand.Int63n(5000), rand.Int63n(5000), rand.Int63n(100)
+		desc := i%2 == 0
+		// 一半用主键的范围，一半用v上的索引
+		sql := fmt.Sprintf("select k from t where k >= %d and %d > k and v < %d order by k", lo, hi, v)
+		if i%4 < 2 {
+			sql = fmt.Sprintf("select k from t where v < %d and k >= %d and %d > k order by k", v, lo, hi)
+			if i%4 == 0 {
+				lo, hi = 0, 5000
+				sql = fmt.Sprintf("select k from t where v < %d order by k", v)
+			}
+		}
+		if desc {
+			sql += " desc"
+		}
+		var want []int64
+		for k, value := range rows {
+			if k >= lo && k < hi && value < v {
+				want = append(want, k)
+			}
+		}
+		sort.Slice(want, func(i, j int) bool { return (want[i] < want[j]) != desc })
+		ret, err := Exec(db, sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ret.Rows) != len(want) {
+			t.Fatalf("%s: got %d rows, want %d", sql, len(ret.Rows), len(want))
+		}
+		for j, row := range ret.Rows {
+			if row[0] != want[j] {
+				t.Fatalf("%s: row %d got %v, want %d", sql, j, row[0], want[j])
+			}
+		}
+	}
+}
+
+func TestExec_SelectAggregate(t *testing.T) {
+	db := selectDB(t)
+	Exec(db, "insert into users values (6, 'fay', 20, 1.0)")
+	cases := []struct {
+		sql     string
+		columns []string
+		rows    [][]interface{}
+	}{
+		{"select count(*), count(age), sum(age), avg(score), min(name), max(score) from users",
+			[]string{"count(*)", "count(age)", "sum(age)", "avg(score)", "min(name)", "max(score)"},
+			[][]interface{}{{int64(6), int64(5), int64(140), 1.7, "ann", 3.0}}},
+		{"select count(*), sum(age), avg(age), max(age) from users where id > 10", []string{"count(*)", "sum(age)", "avg(age)", "max(age)"},
+			[][]interface{}{{int64(0), nil, nil, nil}}},
+		{"select age, count(*) as n, sum(score) from users group by age order by age", []string{"age", "n", "sum(score)"},
+			[][]interface{}{{nil, int64(1), 3.0}, {int64(20), int64(2), 1.0}, {int64(30), int64(2), 4.0}, {int64(40), int64(1), 0.5}}},
+		{"select age, count(*) from users group by age having count(*) > 1 order by count(*) desc, age", []string{"age", "count(*)"},
+			[][]interface{}{{int64(20), int64(2)}, {int64(30), int64(2)}}},
+		{"select age / 10 * 10 as decade, max(name) from users where age is not null group by age / 10 * 10 order by age / 10 * 10 desc",
+			[]string{"decade", "max(name)"},
+			[][]interface{}{{int64(40), "dan"}, {int64(30), "cat"}, {int64(20), "fay"}}},
+		{"select id, count(*) from users where id >= 5 group by id", []string{"id", "count(*)"},
+			[][]interface{}{{int64(5), int64(1)}, {int64(6), int64(1)}}},
+		{"select count(*) + 1 from users group by age limit 2", []string{"count(*) + 1"},
+			[][]interface{}{{int64(3)}, {int64(3)}}},
+		{"select sum(age) from users group by age having age > 25 order by sum(age) desc", []string{"sum(age)"},
+			[][]interface{}{{int64(60)}, {int64(40)}}},
+	}
+	for _, c := range cases {
+		ret, err := Exec(db, c.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", c.sql, err)
+		}
+		if !reflect.DeepEqual(ret.Columns, c.columns) || !reflect.DeepEqual(ret.Rows, c.rows) {
+			t.Fatalf("%s: got %v %v, want %v %v", c.sql, ret.Columns, ret.Rows, c.columns, c.rows)
+		}
+	}
+	errs := map[string]string{
+		"select name, count(*) from users":                 "select: column name must appear in group by or be used in an aggregate function",
+		"select * from users group by age":                 "select: column users.id must appear in group by or be used in an aggregate function",
+		"select age from users group by age order by name": "select: column name must appear in group by or be used in an aggregate function",
+		"select count(*) from users where count(*) > 1":    "select: aggregate functions are not allowed in where",
+		"select count(*) from users group by count(*)":     "select: aggregate functions are not allowed in group by",
+		"select sum(count(*)) from users":                  "select: aggregate function sum() can not be nested",
+		"select sum(name) from users":                      "select: sum() needs numbers, got string",
+		"select max(id), max(nick) from users":             "select: unknown column nick",
+	}
+	for sql, want := range errs {
+		if _, err := Exec(db, sql); err == nil || err.Error() != want {
+			t.Fatalf("%s: got %v, want %s", sql, err, want)
+		}
+	}
+	for _, sql := range []string{"select sum(*) from users", "select count(a, b) from users", "select age from users group age", "select count() from users"} {
+		var se *SyntaxError
+		if _, err := Exec(db, sql); !errors.As(err, &se) {
+			t.Fatalf("%s: got %v, want SyntaxError", sql, err)
+		}
+	}
+	if _, err := Exec(db, "insert k count(*)"); err == nil || err.Error() != "insert k: aggregate function count() is not allowed here" {
+		t.Fatalf("aggregate in kv statement: %v", err)
+	}
+}
+
+// 按主键、索引列分组时用排序聚合，和哈希聚合的结果相同
+func TestExec_SelectGroupRandom(t *testing.T) {
+	db := Open(index.New(4))
+	if _, err := Exec(db, "create table t (k int primary key, g int, v int); create index idx_g on t (g)"); err != nil {
+		t.Fatal(err)
+	}
+	type agg struct{ count, sum int64 }
+	want := make(map[int64]*agg)
+	for k := 0; k < 1000; k++ {
+		g, v := rand.Int63n(50), rand.Int63n(100)
+		if _, err := Exec(db, fmt.Sprintf("insert into t values (%d, %d, %d)", k, g, v)); err != nil {
+			t.Fatal(err)
+		}
+		if want[g] == nil {
+			want[g] = &agg{}
+		}
+		want[g].count++
+		want[g].sum += v
+	}
+	for _, sql := range []string{
+		"select g, count(*), sum(v) from t where g >= 0 group by g",
+		"select g, count(*), sum(v) from t group by g",
+		"select g + 0, count(*), sum(v) from t group by g + 0",
+	} {
+		ret, err := Exec(db, sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ret.Rows) != len(want) {
+			t.Fatalf("%s: got %d groups, want %d", sql, len(ret.Rows), len(want))
+		}
+		for _, row := range ret.Rows {
+			w := want[row[0].(int64)]
+			if row[1] != w.count || row[2] != w.sum {
+				t.Fatalf("%s: group %v got %v, want %v", sql, row[0], row[1:], *w)
+			}
+		}
+	}
+	for sql, stream := range map[string]bool{
+		"select g, count(*) from t where g >= 0 group by g": true,
+		"select k, count(*) from t group by k":              true,
+		"select g, count(*) from t group by g":              false,
+	} {
+		root, err := newLexReader(NewLex(sql)).buildAST()
+		if err != nil {
+			t.Fatal(err)
+		}
+		lp, err := db.logicalSelect(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		op, err := db.physicalSelect(lp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := op.(*project).input.(*streamAggregate); ok != stream {
+			t.Fatalf("%s: stream aggregate %v, want %v", sql, ok, stream)
+		}
+	}
+	ret, err := Exec(db, "select k, count(*) from t where k < 600 group by k having k % 100 = 0 order by k desc limit 3")
+	if err != nil || !reflect.DeepEqual(ret.Rows, [][]interface{}{{int64(500), int64(1)}, {int64(400), int64(1)}, {int64(300), int64(1)}}) {
+		t.Fatalf("group by primary key: %v, %v", ret, err)
+	}
+}
@@ -0,0 +1,235 @@
+package sql
+
+import (
+	"HwyDB/index"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// 会话和事务：
+// 在一个会话中begin之后，insert、update、delete、incr、decr、append、insert into、update set、
+// delete from直接修改B+树，同时记录撤销的操作；commit丢弃撤销记录，rollback按相反的顺序撤销。事务中的语句直接读B+树，
+// 所以能看到事务自己的修改。
+// 事务从begin到commit（或者rollback）一直持有整个数据库的写锁，不在事务中的语句执行时持有读锁，
+// 所以其它会话的语句会等到事务结束，看不到没有提交的修改；等待超过DB.SetLockTimeout设置的时间时
+// 返回ErrLockTimeout。同一个goroutine在事务结束之前用其它会话（包括Exec、db.Prepare的Stmt）
+// 执行语句时也会等到超时，而不是死锁。
+// 事务中不能执行create/drop table、create/drop index。
+
+var (
+	ErrTxInProgress  = errors.New("transaction already in progress")
+	ErrNoTransaction = errors.New("no transaction in progress")
+	ErrTxNotFinished = errors.New("transaction not committed, rolled back")
+	ErrLockTimeout   = errors.New("timeout waiting for another transaction")
+)
+
+// 会话：依次执行语句，记录当前的事务。会话不能同时在多个goroutine中使用
+type Session struct {
+	db *DB
+	tx *DB // 事务中执行语句用的DB，修改时记录撤销的操作；不在事务中时为nil
+}
+
+func (db *DB) NewSession() *Session {
+	return &Session{db: db}
+}
+
+// 是否在事务中
+func (s *Session) InTransaction() bool {
+	return s.tx != nil
+}
+
+// 执行query中的语句，返回最后一条语句的结果；出错时停止，事务仍然保持
+func (s *Session) Exec(query string) (*Result, error) {
+	lex := NewLex(query)
+	if lex.err != nil {
+		return nil, lex.err
+	}
+	return s.execTokens(newLexReader(lex))
+}
+
+// 逐条执行r中的语句，和ExecScript相同
+func (s *Session) ExecScript(r io.Reader, fn func(sr *StmtResult) bool) error {
+	sc := &scriptScanner{r: bufio.NewReader(r), pos: Position{Row: 1, Col: 1}}
+	for {
+		c, err := sc.scan()
+		if sr := s.execChunk(c); sr != nil && !fn(sr) {
+			return nil
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// 准备在这个会话中执行的语句
+func (s *Session) Prepare(query string) (*Stmt, error) {
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	stmt.sess = s
+	return stmt, nil
+}
+
+// 结束会话，回滚没有提交的事务
+func (s *Session) Close() error {
+	if s.tx == nil {
+		return nil
+	}
+	return s.rollback()
+}
+
+// 执行一条语句
+func (s *Session) exec(root *SynatxTreeNode) (*Result, error) {
+	switch root.Name {
+	case "begin":
+		if s.tx != nil {
+			return nil, ErrTxInProgress
+		}
+		if err := s.db.lock.lock(true); err != nil {
+			return nil, err
+		}
+		s.tx = &DB{catalog: s.db.catalog, lock: s.db.lock, tx: &txn{}}
+		s.tx.bt = &txBT{BT: s.db.bt, tx: s.tx.tx}
+		return &Result{Statement: root.Name}, nil
+	case "commit", "rollback":
+		if s.tx == nil {
+			return nil, ErrNoTransaction
+		}
+		if root.Name == "commit" {
+			s.tx = nil
+			s.db.lock.unlock(true)
+			return &Result{Statement: root.Name}, nil
+		}
+		if err := s.rollback(); err != nil {
+			return nil, err
+		}
+		return &Result{Statement: root.Name}, nil
+	}
+	if s.tx == nil {
+		if err := s.db.lock.lock(false); err != nil {
+			return nil, err
+		}
+		defer s.db.lock.unlock(false)
+		return execAST(s.db, root)
+	}
+	switch root.Name {
+	case "create table", "drop table", "create index", "drop index":
+		return nil, fmt.Errorf("%s is not allowed in a transaction", root.Name)
+	}
+	return execAST(s.tx, root)
+}
+
+// 撤销事务中的修改并结束事务
+func (s *Session) rollback() error {
+	err := s.tx.tx.rollback()
+	s.tx = nil
+	s.db.lock.unlock(true)
+	return err
+}
+
+// 结束临时的会话（Exec、ExecScript、Stmt.Exec使用的），没有提交的事务回滚之后返回ErrTxNotFinished
+func (s *Session) finish() error {
+	if s.tx == nil {
+		return nil
+	}
+	if err := s.rollback(); err != nil {
+		return err
+	}
+	return ErrTxNotFinished
+}
+
+// 事务的撤销记录
+type txn struct {
+	undo []func() error
+}
+
+func (tx *txn) record(f func() error) {
+	tx.undo = append(tx.undo, f)
+}
+
+// 按相反的顺序撤销所有的修改，返回第一个错误
+func (tx *txn) rollback() error {
+	var first error
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		if err := tx.undo[i](); err != nil && first == nil {
+			first = err
+		}
+	}
+	tx.undo = nil
+	return first
+}
+
+// 事务中使用的B+树：修改成功之后记录恢复原来的值的操作
+// 撤销带TTL的插入时直接删除，撤销更新时恢复的值没有TTL
+type txBT struct {
+	index.BT
+	tx *txn
+}
+
+func (b *txBT) Insert(key interface{}, value interface{}) error {
+	if err := b.BT.Insert(key, value); err != nil {
+		return err
+	}
+	b.tx.record(func() error { return b.BT.Delete(key) })
+	return nil
+}
+
+func (b *txBT) InsertWithTTL(key interface{}, value interface{}, ttl time.Duration) error {
+	if err := b.BT.InsertWithTTL(key, value, ttl); err != nil {
+		return err
+	}
+	b.tx.record(func() error { return b.BT.Delete(key) })
+	return nil
+}
+
+func (b *txBT) Update(key interface{}, value interface{}) error {
+	old, _ := b.BT.Get(key)
+	if err := b.BT.Update(key, value); err != nil {
+		return err
+	}
+	b.tx.record(func() error { return b.BT.Update(key, old) })
+	return nil
+}
+
+func (b *txBT) UpdateWithTTL(key interface{}, value interface{}, ttl time.Duration) error {
+	old, _ := b.BT.Get(key)
+	if err := b.BT.UpdateWithTTL(key, value, ttl); err != nil {
+		return err
+	}
+	b.tx.record(func() error { return b.BT.Update(key, old) })
+	return nil
+}
+
+func (b *txBT) Delete(key interface{}) error {
+	old, _ := b.BT.Get(key)
+	if err := b.BT.Delete(key); err != nil {
+		return err
+	}
+	b.tx.record(func() error { return b.BT.Insert(key, old) })
+	return nil
+}
+
+func (b *txBT) Modify(key interface{}, fn func(old interface{}, ok bool) (interface{}, error)) (interface{}, error) {
+	var old interface{}
+	var existed bool
+	v, err := b.BT.Modify(key, func(o interface{}, ok bool) (interface{}, error) {
+		old, existed = o, ok
+		return fn(o, ok)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if existed {
+		b.tx.record(func() error { return b.BT.Update(key, old) })
+	} else {
+		b.tx.record(func() error { return b.BT.Delete(key) })
+	}
+	return v, nil
+}
@@ -0,0 +1,210 @@
+package sql
+
+import (
+	"HwyDB/index"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExec_Transaction(t *testing.T) {
+	db := Open(index.New(3))
+	if _, err := Exec(db, "begin; insert a 1; insert b 2; commit"); err != nil {
+		t.Fatal(err)
+	}
+	// 出错时停止，没有提交的事务回滚
+	if _, err := Exec(db, "begin; insert c 3; insert a 5; commit"); err == nil || err.Error() != "insert a: key is exist" {
+		t.Fatalf("got %v", err)
+	}
+	if _, err := Exec(db, "begin; insert d 4"); !errors.Is(err, ErrTxNotFinished) {
+		t.Fatalf("got %v", err)
+	}
+	ret, _ := Exec(db, "find >= 'a'")
+	if !reflect.DeepEqual(ret.Keys, []interface{}{"a", "b"}) {
+		t.Fatalf("keys: %v", ret.Keys)
+	}
+	for sql, want := range map[string]error{
+		"commit":                  ErrNoTransaction,
+		"rollback":                ErrNoTransaction,
+		"begin; begin":            ErrTxInProgress,
+		"begin; rollback; commit": ErrNoTransaction,
+	} {
+		if _, err := Exec(db, sql); !errors.Is(err, want) {
+			t.Fatalf("%s: got %v, want %v", sql, err, want)
+		}
+	}
+}
+
+func TestSession_Rollback(t *testing.T) {
+	db := Open(index.New(3))
+	if _, err := Exec(db, `insert a 1; insert b 2; insert s 'x';
+		create table t (id int primary key, v int); create index idx_v on t (v);
+		insert into t values (1, 10)`); err != nil {
+		t.Fatal(err)
+	}
+	s := db.NewSession()
+	steps := []struct {
+		sql  string
+		want interface{} // find的value或者select的第一个值
+		err  string
+	}{
+		{"begin", nil, ""},
+		{"update a a + 10; find a", int64(11), ""},
+		{"delete b; find b", nil, ""},
+		{"incr cnt 2; incr cnt; find cnt", int64(3), ""},
+		{"append s 'yz'; find s", "xyz", ""},
+		{"insert b 20; delete b; insert b 30; find b", int64(30), ""},
+		{"insert into t values (2, 20), (3, 10)", nil, ""},
+		{"insert into t values (4, 40), (1, 0)", nil, "insert into: t: key is exist"}, // 出错的语句没有修改，事务继续
+		{"create index idx_id on t (id)", nil, "create index is not allowed in a transaction"},
+		{"select count(*) from t where v = 10", int64(2), ""},
+		{"select count(*) from t where id > 3", int64(0), ""},
+		{"update t set v = v + 1 where v = 10; select count(*) from t where v = 11", int64(2), ""},
+		{"delete from t where id < 3; select count(*) from t", int64(1), ""},
+	}
+	for _, step := range steps {
+		ret, err := s.Exec(step.sql)
+		if step.err != "" {
+			if err == nil || err.Error() != step.err {
+				t.Fatalf("%s: got %v, want %s", step.sql, err, step.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", step.sql, err)
+		}
+		var got interface{}
+		switch {
+		case ret.Rows != nil && len(ret.Rows) > 0:
+			got = ret.Rows[0][0]
+		case ret.Statement == "find":
+			got = ret.Value
+		}
+		if step.want != nil && got != step.want {
+			t.Fatalf("%s: got %v, want %v", step.sql, got, step.want)
+		}
+	}
+	if !s.InTransaction() {
+		t.Fatal("should be in a transaction")
+	}
+	if _, err := s.Exec("rollback"); err != nil {
+		t.Fatal(err)
+	}
+	ret, _ := Exec(db, "find >= 'a'")
+	if !reflect.DeepEqual(ret.Keys, []interface{}{"a", "b", "s"}) || !reflect.DeepEqual(ret.Values, []interface{}{int64(1), int64(2), "x"}) {
+		t.Fatalf("after rollback: %v %v", ret.Keys, ret.Values)
+	}
+	// 表和索引也恢复了
+	ret, _ = Exec(db, "select id, v from t where v >= 0")
+	if !reflect.DeepEqual(ret.Rows, [][]interface{}{{int64(1), int64(10)}}) {
+		t.Fatalf("table after rollback: %v", ret.Rows)
+	}
+	if tb, _ := db.Catalog().Table("t"); tb.Len() != 1 {
+		t.Fatalf("len: %d", tb.Len())
+	}
+
+	// 会话中准备的语句在会话的事务中执行
+	insert, err := s.Prepare("insert ? ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Exec("begin")
+	insert.Exec("p", 1)
+	if ret, _ := s.Exec("find p"); ret.Value != int64(1) {
+		t.Fatalf("find p: %v", ret.Value)
+	}
+	if err := s.Close(); err != nil || s.InTransaction() {
+		t.Fatalf("close: %v", err)
+	}
+	if ret, _ := Exec(db, "find p"); ret.Found {
+		t.Fatal("p should be rolled back")
+	}
+	// 不在会话中的语句没有提交时回滚
+	stmt, _ := db.Prepare("begin; insert ? 1")
+	if _, err := stmt.Exec("q"); !errors.Is(err, ErrTxNotFinished) {
+		t.Fatalf("got %v", err)
+	}
+}
+
+// 其它会话在事务结束之后才能执行，看不到没有提交的修改
+func TestSession_Isolation(t *testing.T) {
+	db := Open(index.New(3))
+	s := db.NewSession()
+	if _, err := s.Exec("begin; insert a 1"); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan *Result)
+	go func() {
+		ret, _ := Exec(db, "find a")
+		done <- ret
+	}()
+	select {
+	case <-done:
+		t.Fatal("other sessions should wait for the transaction")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, err := s.Exec("update a 2; commit"); err != nil {
+		t.Fatal(err)
+	}
+	if ret := <-done; ret.Value != int64(2) {
+		t.Fatalf("got %v", ret.Value)
+	}
+}
+
+// 同一个goroutine在事务中用其它会话执行语句、或者忘记了commit时，等待超时之后返回错误
+func TestSession_LockTimeout(t *testing.T) {
+	db := Open(index.New(3))
+	db.SetLockTimeout(20 * time.Millisecond)
+	s := db.NewSession()
+	if _, err := s.Exec("begin; insert a 1"); err != nil {
+		t.Fatal(err)
+	}
+	stmt, _ := db.Prepare("find a")
+	if _, err := stmt.Exec(); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("stmt: got %v", err)
+	}
+	if _, err := Exec(db, "begin"); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("begin: got %v", err)
+	}
+	// 等待超时的事务不会挡住之后的语句
+	if _, err := s.Exec("commit"); err != nil {
+		t.Fatal(err)
+	}
+	if ret, err := Exec(db, "find a"); err != nil || ret.Value != int64(1) {
+		t.Fatalf("find a: %v, %v", ret, err)
+	}
+	// 其它会话的begin在超时之前等到事务结束
+	r := db.NewSession()
+	r.Exec("begin")
+	done := make(chan error)
+	go func() {
+		_, err := Exec(db, "begin; commit")
+		done <- err
+	}()
+	time.Sleep(5 * time.Millisecond)
+	r.Exec("rollback")
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExecScript_Transaction(t *testing.T) {
+	db := Open(index.New(3))
+	script := "begin;\ninsert a 1;\ninsert a 2;\ncommit;\nbegin; insert b 1;"
+	var got []*StmtResult
+	ExecScript(db, strings.NewReader(script), func(sr *StmtResult) bool {
+		got = append(got, sr)
+		return true
+	})
+	if len(got) != 7 || got[2].Err == nil || got[3].Err != nil || !errors.Is(got[6].Err, ErrTxNotFinished) {
+		t.Fatalf("results: %+v", got)
+	}
+	if ret, _ := Exec(db, "find a"); ret.Value != int64(1) {
+		t.Fatalf("a: %v", ret.Value)
+	}
+	if ret, _ := Exec(db, "find b"); ret.Found {
+		t.Fatal("b should be rolled back")
+	}
+}
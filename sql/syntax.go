@@ -4,6 +4,8 @@ import (
 	"HwyDB/index"
 	"errors"
 	"fmt"
+	"strconv"
+	"time"
 )
 
 // 1.生成语法树
@@ -22,43 +24,118 @@ type SynatxTreeNode struct {
 	Child     []*SynatxTreeNode // 子节点
 	Value     interface{}       // 实际值
 	ValueType int               // 值的类型：int、float、string
+	Pos       Position          // 在语句中的位置
 }
 
+// SynatxTreeNode.ValueType
+const (
+	StringType int = iota // string（标识符也按string处理）
+	IntType               // int64
+	FloatType             // float64
+	BoolType              // bool
+	NullType              // nil
+	ParamType             // 占位符，Value是参数的序号（从0开始）
+)
+
 type TokenReader struct {
 	data []*token // 存储lex生成的tokens
 	pos int // 记录读取到的tokens的位置
+	lex *lexer // 生成tokens的lexer，用于在语法错误中显示出错的行
+	params     int    // 读到的占位符的个数（$n时是最大的n）
+	firstParam *token // 第一个占位符
 }
 
+// 读取下一个token，读完之后返回EOF
 func (t *TokenReader) read() *token {
 	t.pos++
 	if t.pos > len(t.data) {
-		return nil
+		return t.eof()
 	}
 	return t.data[t.pos - 1]
 }
 
-func (t *TokenReader) buildAST() (*SynatxTreeNode, error) {
-	if t.data[0].typ != KeyWord {
-		return nil, errors.New("需要关键字开头")
+// 上一个读过的token
+func (t *TokenReader) last() *token {
+	if t.pos == 0 || t.pos > len(t.data) {
+		return t.eof()
 	}
-	var root *SynatxTreeNode
-	var err error
-	switch t.data[0].lit {
-	case "find":
-		root, err = findParser(t)
-	case "insert":
-		root, err = insertParser(t)
-	case "update":
-		root, err = updateParser(t)
-	case "delete":
-		root, err = deleteParser(t)
-	default:
-		return nil, errors.New("You have a syntax error near: " + t.data[0].lit)
+	return t.data[t.pos-1]
+}
+
+// 从from到上一个读过的token之间的原文
+func (t *TokenReader) text(from *token) string {
+	if t.lex == nil {
+		return from.lit
 	}
-	if err != nil {
-		return nil, err
+	return t.lex.str[from.off:t.last().end]
+}
+
+// 查看下一个token
+func (t *TokenReader) peek() *token {
+	return t.peekAt(0)
+}
+
+// 查看之后的第n+1个token
+func (t *TokenReader) peekAt(n int) *token {
+	if t.pos+n >= len(t.data) {
+		return t.eof()
 	}
-	return root, nil
+	return t.data[t.pos+n]
+}
+
+// 跳过空语句（连续的;），返回后面是否还有语句
+func (t *TokenReader) skipEmpty() bool {
+	for t.peek().typ == Semicolon {
+		t.pos++
+	}
+	return t.peek().typ != EOF
+}
+
+// 结束的token，位置为最后一个token的位置
+func (t *TokenReader) eof() *token {
+	if len(t.data) == 0 {
+		return &token{typ: EOF, pos: Position{Row: 1, Col: 1}}
+	}
+	last := t.data[len(t.data)-1]
+	if last.typ == EOF {
+		return last
+	}
+	return &token{typ: EOF, pos: last.pos, off: last.off}
+}
+
+// 生成指向token t的语法错误
+func (t *TokenReader) errorf(tk *token, format string, args ...interface{}) error {
+	line := ""
+	if t.lex != nil {
+		line = t.lex.line(tk.off)
+	}
+	return newSyntaxError(line, tk.pos, fmt.Sprintf(format, args...))
+}
+
+// token t不符合语法，缺少token（读到;或者结束）时报告unexpected end of statement
+func (t *TokenReader) unexpected(tk *token) error {
+	if tk.typ == EOF || tk.typ == Semicolon {
+		return t.errorf(tk, "unexpected end of statement")
+	}
+	return t.errorf(tk, "You have a syntax error near: %s", tk.lit)
+}
+
+// 占位符对应的参数的序号（从0开始），同一个查询中?和$n不能混用
+func (t *TokenReader) param(tk *token) (int, error) {
+	if t.firstParam == nil {
+		t.firstParam = tk
+	} else if (tk.lit == "?") != (t.firstParam.lit == "?") {
+		return 0, t.errorf(tk, "can not mix ? and $n placeholders")
+	}
+	i := t.params
+	if tk.lit != "?" {
+		n, _ := strconv.Atoi(tk.lit[1:])
+		i = n - 1
+	}
+	if i >= t.params {
+		t.params = i + 1
+	}
+	return i, nil
 }
 
 func NewTokenReader(data []*token) *TokenReader {
@@ -67,59 +144,174 @@ func NewTokenReader(data []*token) *TokenReader {
 		pos:  0,
 	}
 }
+
+// 读取lex生成的tokens，语法错误中会显示出错的行
+func newLexReader(lex *lexer) *TokenReader {
+	tr := NewTokenReader(lex.tokens)
+	tr.lex = lex
+	return tr
+}
 // 解析语法树并执行相应函数
-func parseAST(root *SynatxTreeNode, bt index.BT) (interface{}) {
+func parseAST(root *SynatxTreeNode, bt index.BT) (*Result, error) {
 	action := root.Name
-	var ret interface{}
+	if rng := childNode(root, "range"); action == "find" && rng != nil {
+		return scanAST(root, rng, bt), nil
+	}
+	ret := &Result{Statement: action}
+	key, err := getChildForName(root, "key")
+	if err != nil {
+		return nil, fmt.Errorf("%s error: %w", action, err)
+	}
+	ctx := &evalContext{bt: bt, now: time.Now()}
 	switch action {
 	case "insert":
-		key, err := getChildForName(root, "key")
+		value, err := valueOf(ctx, childNode(root, "value"))
 		if err != nil {
-			fmt.Println("insert error")
-			return nil
+			return nil, fmt.Errorf("insert %v: %w", key, err)
 		}
-		value, err := getChildForName(root, "value")
-		if err != nil {
-			fmt.Println("insert error")
-			return nil
+		if err := bt.Insert(key, value); err != nil {
+			return nil, fmt.Errorf("insert %v: %w", key, err)
 		}
-		//fmt.Printf("exec insert %s : %v\n", key, value)
-		bt.Insert(key, value)
 	case "find":
-		key, err := getChildForName(root, "key")
+		ret.Value, ret.Found = bt.Get(key)
+	case "update":
+		value, err := valueOf(ctx, childNode(root, "value"))
 		if err != nil {
-			fmt.Println("find error")
-			return nil
+			return nil, fmt.Errorf("update %v: %w", key, err)
 		}
-		//fmt.Printf("exec find %s\n", key)
-		ret = bt.Find(key)
-		if ret == nil {
-			fmt.Println("the key:", key, "is not exist")
+		if err := bt.Update(key, value); err != nil {
+			return nil, fmt.Errorf("update %v: %w", key, err)
 		}
-	case "update":
-		key, err := getChildForName(root, "key")
-		if err != nil {
-			fmt.Println("update error")
-			return nil
+	case "delete":
+		if err := bt.Delete(key); err != nil {
+			return nil, fmt.Errorf("delete %v: %w", key, err)
 		}
-		value, err := getChildForName(root, "value")
+	case "incr", "decr", "append":
+		value, err := modifyAST(ctx, root, key, bt)
 		if err != nil {
-			fmt.Println("update error")
-			return nil
+			return nil, fmt.Errorf("%s %v: %w", action, key, err)
 		}
-		//fmt.Printf("exec update %s = %v\n", key, value)
-		bt.Update(key, value)
-	case "delete":
-		key, err := getChildForName(root, "key")
+		ret.Value, ret.Found = value, true
+	default:
+		return nil, errors.New("unknown statement: " + action)
+	}
+	if action != "find" || ret.Found {
+		ret.Keys = []interface{}{key}
+	}
+	return ret, nil
+}
+// incr、decr、append：先计算参数，再在一次查找中读取并修改value
+// 不存在的关键字按0（append时按空字符串）处理
+func modifyAST(ctx *evalContext, root *SynatxTreeNode, key interface{}, bt index.BT) (interface{}, error) {
+	var arg interface{} = int64(1)
+	if n := childNode(root, "value"); n != nil {
+		v, err := valueOf(ctx, n)
 		if err != nil {
-			fmt.Println("delet error")
-			return nil
+			return nil, err
+		}
+		arg = v
+	}
+	if root.Name == "append" {
+		suffix, ok := arg.(string)
+		if !ok {
+			return nil, fmt.Errorf("append needs a string, got %T", arg)
+		}
+		return bt.Modify(key, func(old interface{}, ok bool) (interface{}, error) {
+			switch old := old.(type) {
+			case nil:
+				if !ok {
+					return suffix, nil
+				}
+			case string:
+				return old + suffix, nil
+			case []byte:
+				return append(append([]byte{}, old...), suffix...), nil
+			}
+			return nil, fmt.Errorf("value %v is not a string", old)
+		})
+	}
+	arg = number(arg)
+	if _, ok := toFloat(arg); !ok {
+		return nil, fmt.Errorf("%s needs a number, got %T", root.Name, arg)
+	}
+	op := "+"
+	if root.Name == "decr" {
+		op = "-"
+	}
+	return bt.Modify(key, func(old interface{}, ok bool) (interface{}, error) {
+		if !ok {
+			old = int64(0)
+		}
+		if _, isNum := toFloat(number(old)); !isNum {
+			return nil, fmt.Errorf("value %v is not a number", old)
 		}
-		//fmt.Printf("exec delet %s\n", key)
-		bt.Delete(key)
+		return arith(op, old, arg)
+	})
+}
+
+// value节点的值：字面量直接返回，表达式需要计算
+func valueOf(ctx *evalContext, node *SynatxTreeNode) (interface{}, error) {
+	if node == nil {
+		return nil, errors.New("no get the value")
+	}
+	if len(node.Child) == 0 {
+		return node.Value, nil
 	}
+	return ctx.eval(node.Child[0])
+}
+
+// 范围查询：沿着叶子链表扫描
+func scanAST(root, rng *SynatxTreeNode, bt index.BT) *Result {
+	sr, limit := findRange(root, rng)
+	ret := &Result{Statement: "find", Keys: make([]interface{}, 0), Values: make([]interface{}, 0)}
+	if limit != 0 {
+		bt.Scan(sr, func(key, value interface{}) bool {
+			ret.Keys = append(ret.Keys, key)
+			ret.Values = append(ret.Values, value)
+			return limit < 0 || int64(len(ret.Keys)) < limit
+		})
+	}
+	ret.Found = len(ret.Keys) > 0
 	return ret
 }
+
+// 范围查询扫描的范围和最多返回的个数（没有limit时为-1）
+func findRange(root, rng *SynatxTreeNode) (index.ScanRange, int64) {
+	var sr index.ScanRange
+	for _, c := range rng.Child {
+		switch c.Name {
+		case ">":
+			sr.Lo, sr.LoOpen = c.Value, true
+		case ">=":
+			sr.Lo = c.Value
+		case "<":
+			sr.Hi, sr.HiOpen = c.Value, true
+		case "<=":
+			sr.Hi = c.Value
+		case "prefix":
+			sr = index.PrefixRange(c.Value.(string))
+		}
+	}
+	if order := childNode(root, "order"); order != nil {
+		sr.Desc = order.Value == "desc"
+	}
+	limit := int64(-1)
+	if n := childNode(root, "limit"); n != nil {
+		limit = n.Value.(int64)
+	}
+	return sr, limit
+}
+
+// 名为name的子节点，没有时返回nil
+func childNode(root *SynatxTreeNode, name string) *SynatxTreeNode {
+	for _, c := range root.Child {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
 // 根据child的name获取child的value值
 func getChildForName(root *SynatxTreeNode, name string) (interface{}, error) {
 	if root == nil {
@@ -138,100 +330,3 @@ func getChildForName(root *SynatxTreeNode, name string) (interface{}, error) {
 	}
 	return nil, errors.New("no get the " + name)
 }
-// -----------关键字解析函数---------------
-
-func insertParser(tr *TokenReader) (*SynatxTreeNode, error) {
-	t := tr.read()
-	if t.typ != KeyWord || t.lit != "insert" {
-		return nil, errors.New("You have a syntax error near: " + t.lit)
-	}
-	tKey := tr.read()
-	if tKey.typ != Identifier {
-		return nil, errors.New("You have a syntax error near: " + tKey.lit)
-	}
-	tValue := tr.read()
-	if tKey.typ != Identifier {
-		return nil, errors.New("You have a syntax error near: " + tValue.lit)
-	}
-	return &SynatxTreeNode{
-		Name:      "insert",
-		Child:     []*SynatxTreeNode{&SynatxTreeNode{
-			Name:      "key",
-			Value:     tKey.lit,
-			ValueType: 0,
-		},&SynatxTreeNode{
-			Name:      "value",
-			Value:     tValue.lit,
-			ValueType: 1,
-		}},
-	}, nil
-}
-
-func findParser(tr *TokenReader) (*SynatxTreeNode, error) {
-	t := tr.read()
-	if t.typ != KeyWord || t.lit != "find" {
-		return nil, errors.New("You have a syntax error near: " + t.lit)
-	}
-	tKey := tr.read()
-	if tKey.typ != Identifier {
-		return nil, errors.New("You have a syntax error near: " + tKey.lit)
-	}
-	return &SynatxTreeNode{
-		Name:      "find",
-		Child:     []*SynatxTreeNode{&SynatxTreeNode{
-			Name:      "key",
-			Value:     tKey.lit,
-			ValueType: 0,
-		}},
-	}, nil
-}
-
-func updateParser(tr *TokenReader) (*SynatxTreeNode, error) {
-	t := tr.read()
-	if t.typ != KeyWord || t.lit != "update" {
-		return nil, errors.New("You have a syntax error near: " + t.lit)
-	}
-	tKey := tr.read()
-	if tKey.typ != Identifier {
-		return nil, errors.New("You have a syntax error near: " + tKey.lit)
-	}
-	tValue := tr.read()
-	if tKey.typ != Identifier {
-		return nil, errors.New("You have a syntax error near: " + tValue.lit)
-	}
-	return &SynatxTreeNode{
-		Name:      "update",
-		Child:     []*SynatxTreeNode{&SynatxTreeNode{
-			Name:      "key",
-			Value:     tKey.lit,
-			ValueType: 0,
-		},&SynatxTreeNode{
-			Name:      "value",
-			Value:     tValue.lit,
-			ValueType: 1,
-		}},
-	}, nil
-}
-
-func deleteParser(tr *TokenReader) (*SynatxTreeNode, error) {
-	t := tr.read()
-	if t.typ != KeyWord || t.lit != "delete" {
-		return nil, errors.New("You have a syntax error near: " + t.lit)
-	}
-	tKey := tr.read()
-	if tKey.typ != Identifier {
-		return nil, errors.New("You have a syntax error near: " + tKey.lit)
-	}
-	return &SynatxTreeNode{
-		Name:      "delete",
-		Child:     []*SynatxTreeNode{&SynatxTreeNode{
-			Name:      "key",
-			Value:     tKey.lit,
-			ValueType: 0,
-		}},
-	}, nil
-}
-
-func valueParser(tr *TokenReader) (*SynatxTreeNode, error) {
-	panic("valueParser")
-}
\ No newline at end of file
@@ -45,8 +45,11 @@ func Test_parseAST(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
-			ret := parseAST(root, bt)
-			fmt.Println("ret:", ret)
+			ret, err := parseAST(root, bt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fmt.Printf("ret: %+v\n", ret)
 		})
 	}
 }
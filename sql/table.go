@@ -0,0 +1,528 @@
+package sql
+
+import (
+	"HwyDB/catalog"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 关系表相关的语句：
+//	create table <table> (<column> <type> [primary key], ...)
+//	drop table <table>
+//	create index <index> on <table> (<column>)
+//	drop index <index>
+//	insert into <table> [(<column>, ...)] values (<value>, ...) [, (<value>, ...)]
+//	update <table> set <column> = <expr> [, <column> = <expr>] [where <expr>]
+//	delete from <table> [where <expr>]
+//
+// 语法树：
+//	create table  Value是表名，Child是column节点（Value是列名，Child是type节点以及可能有的primary key节点）
+//	drop table    Value是表名
+//	create index  Value是索引名，Child是on节点（Value是表名，Child是column节点）
+//	drop index    Value是索引名
+//	insert into   Value是表名，Child是columns节点（可以没有）和values节点，values的每个子节点row是一行value
+//	update set    Value是表名，Child是set节点（Value是列名，Child是表达式）以及可能有的where节点
+//	delete from   Value是表名，Child是可能有的where节点
+
+// 执行一条语句，表相关的语句（包括select）在catalog上执行，其它的在db.bt上执行
+func execAST(db *DB, root *SynatxTreeNode) (*Result, error) {
+	switch root.Name {
+	case "create table":
+		return db.createTable(root)
+	case "drop table":
+		return db.dropTable(root)
+	case "create index":
+		return db.createIndex(root)
+	case "drop index":
+		return db.dropIndex(root)
+	case "insert into":
+		return db.insertInto(root)
+	case "update set":
+		return db.updateSet(root)
+	case "delete from":
+		return db.deleteFrom(root)
+	case "select":
+		return db.selectRows(root)
+	case "explain":
+		return db.explain(root)
+	}
+	return parseAST(root, db.bt)
+}
+
+// 读取一个指定的关键字（包括上下文关键字）
+func expectKeyWord(tr *TokenReader, lit string) (*token, error) {
+	t := tr.read()
+	if !isWord(t, lit) {
+		return nil, tr.unexpected(t)
+	}
+	return t, nil
+}
+
+// 读取指定的括号
+func expectParen(tr *TokenReader, lit string) error {
+	if t := tr.read(); t.typ != Paren || t.lit != lit {
+		return tr.unexpected(t)
+	}
+	return nil
+}
+
+// 读取标识符（表名、列名），统一成小写
+func identParser(tr *TokenReader, name string) (*SynatxTreeNode, error) {
+	t := tr.read()
+	if t.typ != Identifier {
+		return nil, tr.unexpected(t)
+	}
+	return &SynatxTreeNode{Name: name, Value: strings.ToLower(t.lit), Pos: t.pos}, nil
+}
+
+// 解析用,分隔、用括号括起来的列表，item解析其中的一项
+func listParser(tr *TokenReader, item func() error) error {
+	if err := expectParen(tr, "("); err != nil {
+		return err
+	}
+	for {
+		if err := item(); err != nil {
+			return err
+		}
+		switch t := tr.read(); {
+		case t.typ == Paren && t.lit == ")":
+			return nil
+		case t.typ != Symbol || t.lit != ",":
+			return tr.unexpected(t)
+		}
+	}
+}
+
+func createParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	if i := tr.peekAt(1); isWord(i, "index") {
+		return createIndexParser(tr)
+	}
+	t := tr.read()
+	if _, err := expectKeyWord(tr, "table"); err != nil {
+		return nil, err
+	}
+	name, err := identParser(tr, "create table")
+	if err != nil {
+		return nil, err
+	}
+	root := &SynatxTreeNode{Name: "create table", Value: name.Value, Pos: t.pos}
+	hasPK := false
+	err = listParser(tr, func() error {
+		col, err := identParser(tr, "column")
+		if err != nil {
+			return err
+		}
+		typ := tr.read()
+		if typ.typ != Identifier {
+			return tr.unexpected(typ)
+		}
+		if _, ok := catalog.ParseType(typ.lit); !ok {
+			return tr.errorf(typ, "unknown type: %s", typ.lit)
+		}
+		col.Child = []*SynatxTreeNode{{Name: "type", Value: strings.ToLower(typ.lit), Pos: typ.pos}}
+		if p := tr.peek(); isWord(p, "primary") {
+			tr.read()
+			if k := tr.read(); !isWord(k, "key") {
+				return tr.unexpected(k)
+			}
+			if hasPK {
+				return tr.errorf(p, "multiple primary keys")
+			}
+			hasPK = true
+			col.Child = append(col.Child, &SynatxTreeNode{Name: "primary key", Pos: p.pos})
+		}
+		root.Child = append(root.Child, col)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !hasPK {
+		return nil, tr.errorf(t, "table %s needs a primary key", name.Value)
+	}
+	return root, nil
+}
+
+func createIndexParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	t := tr.read()
+	tr.read() // index
+	name, err := identParser(tr, "create index")
+	if err != nil {
+		return nil, err
+	}
+	name.Pos = t.pos
+	if _, err := expectKeyWord(tr, "on"); err != nil {
+		return nil, err
+	}
+	on, err := identParser(tr, "on")
+	if err != nil {
+		return nil, err
+	}
+	err = listParser(tr, func() error {
+		if len(on.Child) > 0 {
+			return tr.errorf(tr.peek(), "index on multiple columns is not supported")
+		}
+		col, err := identParser(tr, "column")
+		on.Child = append(on.Child, col)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	name.Child = []*SynatxTreeNode{on}
+	return name, nil
+}
+
+func dropParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	t := tr.read()
+	what := tr.read()
+	if !isWord(what, "table") && !isWord(what, "index") {
+		return nil, tr.unexpected(what)
+	}
+	name, err := identParser(tr, "drop "+strings.ToLower(what.lit))
+	if err != nil {
+		return nil, err
+	}
+	name.Pos = t.pos
+	return name, nil
+}
+
+func insertIntoParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	t := tr.read()
+	tr.read() // into
+	name, err := identParser(tr, "insert into")
+	if err != nil {
+		return nil, err
+	}
+	root := &SynatxTreeNode{Name: "insert into", Value: name.Value, Pos: t.pos}
+	if p := tr.peek(); p.typ == Paren && p.lit == "(" {
+		columns := &SynatxTreeNode{Name: "columns", Pos: p.pos}
+		err := listParser(tr, func() error {
+			col, err := identParser(tr, "column")
+			columns.Child = append(columns.Child, col)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		root.Child = append(root.Child, columns)
+	}
+	v, err := expectKeyWord(tr, "values")
+	if err != nil {
+		return nil, err
+	}
+	values := &SynatxTreeNode{Name: "values", Pos: v.pos}
+	for {
+		row := &SynatxTreeNode{Name: "row", Pos: tr.peek().pos}
+		err := listParser(tr, func() error {
+			value, err := valueParser(tr)
+			row.Child = append(row.Child, value)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		values.Child = append(values.Child, row)
+		if c := tr.peek(); c.typ != Symbol || c.lit != "," {
+			break
+		}
+		tr.read()
+	}
+	root.Child = append(root.Child, values)
+	return root, nil
+}
+
+func updateSetParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	t := tr.read()
+	name, err := identParser(tr, "update set")
+	if err != nil {
+		return nil, err
+	}
+	root := &SynatxTreeNode{Name: "update set", Value: name.Value, Pos: t.pos}
+	tr.read() // set
+	for {
+		col, err := identParser(tr, "set")
+		if err != nil {
+			return nil, err
+		}
+		if eq := tr.read(); eq.typ != Symbol || eq.lit != "=" {
+			return nil, tr.unexpected(eq)
+		}
+		expr, err := exprParser(tr)
+		if err != nil {
+			return nil, err
+		}
+		col.Child = []*SynatxTreeNode{expr}
+		root.Child = append(root.Child, col)
+		if c := tr.peek(); c.typ != Symbol || c.lit != "," {
+			break
+		}
+		tr.read()
+	}
+	where, err := whereParser(tr)
+	if err != nil {
+		return nil, err
+	}
+	if where != nil {
+		root.Child = append(root.Child, where)
+	}
+	return root, nil
+}
+
+func deleteFromParser(tr *TokenReader) (*SynatxTreeNode, error) {
+	t := tr.read()
+	tr.read() // from
+	name, err := identParser(tr, "delete from")
+	if err != nil {
+		return nil, err
+	}
+	root := &SynatxTreeNode{Name: "delete from", Value: name.Value, Pos: t.pos}
+	where, err := whereParser(tr)
+	if err != nil {
+		return nil, err
+	}
+	if where != nil {
+		root.Child = append(root.Child, where)
+	}
+	return root, nil
+}
+
+// ----------- 执行 ---------------
+
+func (db *DB) createTable(root *SynatxTreeNode) (*Result, error) {
+	columns := make([]catalog.Column, 0, len(root.Child))
+	pk := ""
+	for _, c := range root.Child {
+		typ, _ := catalog.ParseType(childNode(c, "type").Value.(string))
+		columns = append(columns, catalog.Column{Name: c.Value.(string), Type: typ})
+		if childNode(c, "primary key") != nil {
+			pk = c.Value.(string)
+		}
+	}
+	s, err := catalog.NewSchema(root.Value.(string), columns, pk)
+	if err != nil {
+		return nil, fmt.Errorf("create table: %w", err)
+	}
+	if _, err := db.catalog.Create(s); err != nil {
+		return nil, fmt.Errorf("create table: %w", err)
+	}
+	return &Result{Statement: root.Name}, nil
+}
+
+func (db *DB) dropTable(root *SynatxTreeNode) (*Result, error) {
+	if err := db.catalog.Drop(root.Value.(string)); err != nil {
+		return nil, fmt.Errorf("drop table: %w", err)
+	}
+	return &Result{Statement: root.Name}, nil
+}
+
+func (db *DB) createIndex(root *SynatxTreeNode) (*Result, error) {
+	on := childNode(root, "on")
+	if _, err := db.catalog.CreateIndex(root.Value.(string), on.Value.(string), on.Child[0].Value.(string)); err != nil {
+		return nil, fmt.Errorf("create index: %w", err)
+	}
+	return &Result{Statement: root.Name}, nil
+}
+
+func (db *DB) dropIndex(root *SynatxTreeNode) (*Result, error) {
+	if err := db.catalog.DropIndex(root.Value.(string)); err != nil {
+		return nil, fmt.Errorf("drop index: %w", err)
+	}
+	return &Result{Statement: root.Name}, nil
+}
+
+// 先计算、检查所有的行再插入，插入失败时删除已经插入的行
+func (db *DB) insertInto(root *SynatxTreeNode) (*Result, error) {
+	t, err := db.catalog.Table(root.Value.(string))
+	if err != nil {
+		return nil, fmt.Errorf("insert into: %w", err)
+	}
+	// 每个value对应的列
+	idx := make([]int, len(t.Columns))
+	for i := range idx {
+		idx[i] = i
+	}
+	if columns := childNode(root, "columns"); columns != nil {
+		idx = idx[:0]
+		seen := make(map[int]bool)
+		for _, c := range columns.Child {
+			i, ok := t.Column(c.Value.(string))
+			if !ok {
+				return nil, fmt.Errorf("insert into %s: unknown column %s", t.Name, c.Value)
+			}
+			if seen[i] {
+				return nil, fmt.Errorf("insert into %s: duplicate column %s", t.Name, c.Value)
+			}
+			seen[i] = true
+			idx = append(idx, i)
+		}
+	}
+	ctx := &evalContext{bt: db.bt, now: time.Now()}
+	rows := make([]catalog.Row, 0)
+	for n, r := range childNode(root, "values").Child {
+		if len(r.Child) != len(idx) {
+			return nil, fmt.Errorf("insert into %s: row %d has %d values, want %d", t.Name, n+1, len(r.Child), len(idx))
+		}
+		row := make(catalog.Row, len(t.Columns))
+		for i, v := range r.Child {
+			value, err := valueOf(ctx, v)
+			if err != nil {
+				return nil, fmt.Errorf("insert into %s: %w", t.Name, err)
+			}
+			row[idx[i]] = value
+		}
+		if row, err = t.Validate(row); err != nil {
+			return nil, fmt.Errorf("insert into %s: row %d: %w", t.Name, n+1, err)
+		}
+		rows = append(rows, row)
+	}
+	ret := &Result{Statement: root.Name, Keys: make([]interface{}, 0, len(rows))}
+	for _, row := range rows {
+		if err := t.Insert(row); err != nil {
+			for _, key := range ret.Keys {
+				t.Delete(key)
+			}
+			return nil, fmt.Errorf("insert into: %w", err)
+		}
+		ret.Keys = append(ret.Keys, row[t.PK])
+	}
+	if db.tx != nil {
+		for _, key := range ret.Keys {
+			key := key
+			db.tx.record(func() error { return t.Delete(key) })
+		}
+	}
+	return ret, nil
+}
+
+// 表中满足where（可以为nil）的行的访问算子，和select一样选择访问路径
+func (db *DB) matchRows(table string, where *SynatxTreeNode) (*catalog.Table, operator, error) {
+	sel := &SynatxTreeNode{Name: "select", Child: []*SynatxTreeNode{
+		{Name: "fields", Value: "*"},
+		{Name: "from", Value: table},
+	}}
+	if where != nil {
+		sel.Child = append(sel.Child, where)
+	}
+	lp, err := db.logicalSelect(sel)
+	if err != nil {
+		return nil, nil, err
+	}
+	op, err := db.physicalSelect(lp)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 只有一个表时去掉投影之后就是表中的行
+	return lp.sources[0].table, op.(*project).input, nil
+}
+
+// 读出所有要修改的行，修改的过程中不再扫描表
+func collectRows(op operator) ([]catalog.Row, error) {
+	rows := make([]catalog.Row, 0)
+	err := op.run(func(row []interface{}) (bool, error) {
+		rows = append(rows, catalog.Row(row))
+		return true, nil
+	})
+	return rows, err
+}
+
+// 先计算、检查所有的新行再修改，修改失败时恢复已经修改的行；不能修改主键
+func (db *DB) updateSet(root *SynatxTreeNode) (*Result, error) {
+	t, op, err := db.matchRows(root.Value.(string), childNode(root, "where"))
+	if err != nil {
+		return nil, fmt.Errorf("update: %w", err)
+	}
+	sc := tableScope(t, t.Name)
+	type assign struct {
+		col  int
+		expr *SynatxTreeNode
+	}
+	var sets []assign
+	seen := make(map[int]bool)
+	for _, c := range root.Child {
+		if c.Name != "set" {
+			continue
+		}
+		i, ok := t.Column(c.Value.(string))
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("update %s: unknown column %s", t.Name, c.Value)
+		case seen[i]:
+			return nil, fmt.Errorf("update %s: duplicate column %s", t.Name, c.Value)
+		case i == t.PK:
+			return nil, fmt.Errorf("update %s: primary key %s can not be updated", t.Name, c.Value)
+		case hasAggregate(c.Child[0]):
+			return nil, fmt.Errorf("update %s: aggregate functions are not allowed in set", t.Name)
+		}
+		if err := sc.check(c.Child[0]); err != nil {
+			return nil, fmt.Errorf("update %s: %w", t.Name, err)
+		}
+		seen[i] = true
+		sets = append(sets, assign{col: i, expr: c.Child[0]})
+	}
+	olds, err := collectRows(op)
+	if err != nil {
+		return nil, fmt.Errorf("update %s: %w", t.Name, err)
+	}
+	ctx := &evalContext{bt: db.bt, now: time.Now(), scope: sc}
+	rows := make([]catalog.Row, len(olds))
+	for n, old := range olds {
+		ctx.row = old
+		row := append(catalog.Row(nil), old...)
+		for _, s := range sets {
+			if row[s.col], err = ctx.eval(s.expr); err != nil {
+				return nil, fmt.Errorf("update %s: %w", t.Name, err)
+			}
+		}
+		if rows[n], err = t.Validate(row); err != nil {
+			return nil, fmt.Errorf("update %s: %w", t.Name, err)
+		}
+	}
+	ret := &Result{Statement: root.Name, Keys: make([]interface{}, 0, len(rows))}
+	for n, row := range rows {
+		if err := t.Update(row); err != nil {
+			for _, old := range olds[:n] {
+				t.Update(old)
+			}
+			return nil, fmt.Errorf("update %s: %w", t.Name, err)
+		}
+		ret.Keys = append(ret.Keys, row[t.PK])
+	}
+	if db.tx != nil {
+		for _, old := range olds {
+			old := old
+			db.tx.record(func() error { return t.Update(old) })
+		}
+	}
+	return ret, nil
+}
+
+// 先找出所有要删除的行再删除，删除失败时恢复已经删除的行
+func (db *DB) deleteFrom(root *SynatxTreeNode) (*Result, error) {
+	t, op, err := db.matchRows(root.Value.(string), childNode(root, "where"))
+	if err != nil {
+		return nil, fmt.Errorf("delete from: %w", err)
+	}
+	rows, err := collectRows(op)
+	if err != nil {
+		return nil, fmt.Errorf("delete from %s: %w", t.Name, err)
+	}
+	ret := &Result{Statement: root.Name, Keys: make([]interface{}, 0, len(rows))}
+	for n, row := range rows {
+		if err := t.Delete(row[t.PK]); err != nil {
+			for _, old := range rows[:n] {
+				t.Insert(old)
+			}
+			return nil, fmt.Errorf("delete from %s: %w", t.Name, err)
+		}
+		ret.Keys = append(ret.Keys, row[t.PK])
+	}
+	if db.tx != nil {
+		for _, row := range rows {
+			row := row
+			db.tx.record(func() error { return t.Insert(row) })
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,255 @@
+package sql
+
+import (
+	"HwyDB/catalog"
+	"HwyDB/index"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExec_CreateTable(t *testing.T) {
+	db := Open(index.New(3))
+	if _, err := Exec(db, "create table Users (id int primary key, name string, age INT, score float, vip boolean)"); err != nil {
+		t.Fatal(err)
+	}
+	users, err := db.Catalog().Table("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := users.Schema.String(); got != "users (id int primary key, name string, age int, score float, vip bool)" {
+		t.Fatal(got)
+	}
+	if _, err := Exec(db, "create table users (id int primary key)"); !errors.Is(err, catalog.ErrTableExist) {
+		t.Fatalf("create twice: %v", err)
+	}
+	cases := []string{
+		"create table t",
+		"create table t ()",
+		"create table t (id int)",
+		"create table t (id blob primary key)",
+		"create table t (id int primary key, k int primary key)",
+		"create table t (id int primary, name string)",
+		"create table t (id int primary key name string)",
+		"create t (id int primary key)",
+		"create table 't' (id int primary key)",
+	}
+	for _, sql := range cases {
+		_, err := Exec(db, sql)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Fatalf("%s: got %v, want SyntaxError", sql, err)
+		}
+	}
+	if _, err := Exec(db, "create table t (id int primary key, ID string)"); err == nil || !strings.Contains(err.Error(), "duplicate column id") {
+		t.Fatalf("duplicate column: %v", err)
+	}
+	if _, err := Exec(db, "create table b (x bool primary key, y int)"); err == nil || !strings.Contains(err.Error(), "primary key x can not be bool") {
+		t.Fatalf("bool primary key: %v", err)
+	}
+	if _, err := Exec(db, "drop table users; drop table users"); !errors.Is(err, catalog.ErrTableNotExist) {
+		t.Fatalf("drop twice: %v", err)
+	}
+	if len(db.Catalog().Tables()) != 0 {
+		t.Fatal("table should be dropped")
+	}
+}
+
+func TestExec_InsertInto(t *testing.T) {
+	db := Open(index.New(3))
+	Exec(db, "insert bonus 5")
+	if _, err := Exec(db, "create table users (id int primary key, name string, age int, score float)"); err != nil {
+		t.Fatal(err)
+	}
+	ret, err := Exec(db, "insert into users values (1, 'hwy', 29, 1.5), (2, 'wu', 30 + bonus, 2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.Statement != "insert into" || !reflect.DeepEqual(ret.Keys, []interface{}{int64(1), int64(2)}) {
+		t.Fatalf("got %+v", ret)
+	}
+	if _, err := Exec(db, "insert into USERS (name, id) values (upper('li'), 3)"); err != nil {
+		t.Fatal(err)
+	}
+	users, _ := db.Catalog().Table("users")
+	want := map[int]catalog.Row{
+		1: {int64(1), "hwy", int64(29), 1.5},
+		2: {int64(2), "wu", int64(35), 2.0},
+		3: {int64(3), "LI", nil, nil},
+	}
+	for id, row := range want {
+		got, ok, err := users.Get(id)
+		if err != nil || !ok || !reflect.DeepEqual(got, row) {
+			t.Fatalf("%d: got %#v, want %#v", id, got, row)
+		}
+	}
+	errCases := []struct {
+		sql string
+		msg string
+	}{
+		{"insert into orders values (1)", "table does not exist"},
+		{"insert into users values (4, 'x', 1)", "row 1 has 3 values, want 4"},
+		{"insert into users (id, nick) values (4, 'x')", "unknown column nick"},
+		{"insert into users (id, id) values (4, 4)", "duplicate column id"},
+		{"insert into users values (4, 'x', '1', 1)", "column age: want int"},
+		{"insert into users (name) values ('x')", "primary key id can not be null"},
+		{"insert into users values (4, 'x', 1, 1), (1, 'dup', 1, 1)", "key is exist"},
+		{"insert into users values (5, 'x', 1, 1), (5, 'dup', 1, 1)", "key is exist"},
+		{"insert into users values (6, 'x', 1 / 0, 1)", "division by zero"},
+	}
+	for _, c := range errCases {
+		if _, err := Exec(db, c.sql); err == nil || !strings.Contains(err.Error(), c.msg) {
+			t.Fatalf("%s: got %v, want %q", c.sql, err, c.msg)
+		}
+	}
+	// 失败的语句不会留下插入了一半的行
+	for _, id := range []int{4, 5, 6} {
+		if _, ok, _ := users.Get(id); ok {
+			t.Fatalf("row %d should be rolled back", id)
+		}
+	}
+	for _, sql := range []string{"insert into users values", "insert into users values (1, 2", "insert into users (id values (1)", "insert into users values (1),"} {
+		_, err := Exec(db, sql)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Fatalf("%s: got %v, want SyntaxError", sql, err)
+		}
+	}
+}
+
+func TestExec_UpdateDelete(t *testing.T) {
+	db := selectDB(t)
+	if _, err := Exec(db, "create index idx_age on users (age); insert bonus 6"); err != nil {
+		t.Fatal(err)
+	}
+	ret, err := Exec(db, "update users set age = age + 5, NAME = upper(name) where age = 30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.Statement != "update set" || !reflect.DeepEqual(ret.Keys, []interface{}{int64(1), int64(3)}) {
+		t.Fatalf("got %+v", ret)
+	}
+	ret, err = Exec(db, "delete from users where score < 1 or score is null")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.Statement != "delete from" || !reflect.DeepEqual(ret.Keys, []interface{}{int64(2), int64(4)}) {
+		t.Fatalf("got %+v", ret)
+	}
+	// 索引也修改了
+	ret, err = Exec(db, "select id, name, age from users where age >= 30")
+	want := [][]interface{}{{int64(1), "ANN", int64(35)}, {int64(3), "CAT", int64(35)}}
+	if err != nil || !reflect.DeepEqual(ret.Rows, want) {
+		t.Fatalf("select: %v, %v", ret, err)
+	}
+	if ret, err := Exec(db, "update users set score = 0"); err != nil || len(ret.Keys) != 3 {
+		t.Fatalf("update all: %v, %v", ret, err)
+	}
+	errCases := []struct {
+		sql string
+		msg string
+	}{
+		{"update orders set a = 1", "update: orders: table does not exist"},
+		{"delete from orders", "delete from: orders: table does not exist"},
+		{"update users set nick = 'x'", "update users: unknown column nick"},
+		{"update users set age = 1, AGE = 2", "update users: duplicate column age"},
+		{"update users set id = id + 10", "update users: primary key id can not be updated"},
+		{"update users set age = count(*)", "aggregate functions are not allowed in set"},
+		{"update users set age = nope", "unknown column nope"},
+		{"update users set age = 'x' where id = 1", "column age: want int"},
+		{"update users set age = 10 / (id - 3)", "division by zero"},
+		{"delete from users where nope", "unknown column nope"},
+	}
+	for _, c := range errCases {
+		if _, err := Exec(db, c.sql); err == nil || !strings.Contains(err.Error(), c.msg) {
+			t.Fatalf("%s: got %v, want %q", c.sql, err, c.msg)
+		}
+	}
+	// 出错的update没有修改任何一行
+	ret, _ = Exec(db, "select age from users")
+	if !reflect.DeepEqual(ret.Rows, [][]interface{}{{int64(35)}, {int64(35)}, {nil}}) {
+		t.Fatalf("after failed update: %v", ret.Rows)
+	}
+	if ret, err := Exec(db, "delete from users"); err != nil || len(ret.Keys) != 3 {
+		t.Fatalf("delete all: %v, %v", ret, err)
+	}
+	// 不是表的语句仍然是关键字上的update、delete
+	if ret, err := Exec(db, "update bonus 7; find bonus"); err != nil || ret.Value != int64(7) {
+		t.Fatalf("update key: %v, %v", ret, err)
+	}
+	if _, err := Exec(db, "insert from 1; delete from"); err != nil {
+		t.Fatalf("delete key from: %v", err)
+	}
+	for _, sql := range []string{"update users set", "update users set age", "update users set age = ", "update users set age = 1,", "update users set age = 1 where", "delete from users where"} {
+		_, err := Exec(db, sql)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Fatalf("%s: got %v, want SyntaxError", sql, err)
+		}
+	}
+}
+
+func TestExec_CreateIndex(t *testing.T) {
+	db := selectDB(t)
+	if _, err := Exec(db, "create index IDX_AGE on users (Age)"); err != nil {
+		t.Fatal(err)
+	}
+	users, _ := db.Catalog().Table("users")
+	if ix := users.Indexes(); len(ix) != 1 || ix[0].String() != "idx_age on users (age)" {
+		t.Fatalf("indexes: %v", ix)
+	}
+	errs := map[string]error{
+		"create index idx_age on users (name)": catalog.ErrIndexExist,
+		"create index idx_x on t (name)":       catalog.ErrTableNotExist,
+		"drop index idx_x":                     catalog.ErrIndexNotExist,
+	}
+	for sql, want := range errs {
+		if _, err := Exec(db, sql); !errors.Is(err, want) {
+			t.Fatalf("%s: got %v, want %v", sql, err, want)
+		}
+	}
+	if _, err := Exec(db, "create index idx_x on users (nick)"); err == nil || err.Error() != "create index: users: unknown column nick" {
+		t.Fatalf("unknown column: %v", err)
+	}
+	for _, sql := range []string{
+		"create index on users (age)",
+		"create index idx_x users (age)",
+		"create index idx_x on users age",
+		"create index idx_x on users (age, name)",
+		"drop idx_age",
+	} {
+		var se *SyntaxError
+		if _, err := Exec(db, sql); !errors.As(err, &se) {
+			t.Fatalf("%s: got %v, want SyntaxError", sql, err)
+		}
+	}
+	// 插入、修改、删除之后通过索引查询
+	Exec(db, "insert into users values (6, 'fay', 30, null)")
+	users.Update(catalog.Row{1, "ann", 31, 1.5})
+	users.Delete(3)
+	ret, err := Exec(db, "select name from users where age = 30")
+	if err != nil || !reflect.DeepEqual(ret.Rows, [][]interface{}{{"fay"}}) {
+		t.Fatalf("select: %v, %v", ret, err)
+	}
+	ret, err = Exec(db, "select name from users where age >= 30 order by age desc")
+	if err != nil || !reflect.DeepEqual(ret.Rows, [][]interface{}{{"dan"}, {"ann"}, {"fay"}}) {
+		t.Fatalf("select: %v, %v", ret, err)
+	}
+	// 小数的范围换成整数的范围之后通过索引查询
+	for sql, want := range map[string][][]interface{}{
+		"select name from users where age > 29.5 and age < 30.5":   {{"fay"}},
+		"select name from users where age >= 30.5 and age <= 40.5": {{"ann"}, {"dan"}},
+		"select name from users where age = 30.0":                  {{"fay"}},
+		"select name from users where age = 30.5":                  {},
+		"select name from users where age < 1e30 and age > -1e30":  {{"ann"}, {"bob"}, {"dan"}, {"fay"}},
+	} {
+		ret, err := Exec(db, sql)
+		if err != nil || !reflect.DeepEqual(ret.Rows, want) {
+			t.Fatalf("%s: %v, %v", sql, ret, err)
+		}
+	}
+	if _, err := Exec(db, "drop index idx_age"); err != nil || len(users.Indexes()) != 0 {
+		t.Fatalf("drop index: %v", err)
+	}
+}